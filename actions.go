@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const defaultActionTimeout = 10 * time.Second
+
+// shellWaitDelay is how long a timed-out command's output is waited for once its
+// process group was killed, in case something outside the group holds it open
+const shellWaitDelay = 2 * time.Second
+
+// ExternalAction is a user-defined transformation that pipes an item's content
+// through an external command and stores the command's output as a new item
+type ExternalAction struct {
+	Name    string `json:"name"`    // Name shown in the row menu and used by the CLI
+	Command string `json:"command"` // Shell command, run with "sh -c"
+	Timeout int    `json:"timeout"` // Timeout in seconds (0 uses the default)
+}
+
+// runShellCommand runs command with "sh -c", feeding input on stdin, and returns its
+// stdout. env adds "KEY=value" variables to the command's environment. The shell
+// runs in its own process group, which is killed as a whole on timeout so that
+// children left running can't keep the command's output open.
+func runShellCommand(name, command string, timeout time.Duration, input string, env ...string) (string, error) {
+	if timeout <= 0 {
+		timeout = defaultActionTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = strings.NewReader(input)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = shellWaitDelay
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("%q timed out after %s", name, timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%q failed: %w: %s", name, err, msg)
+		}
+		return "", fmt.Errorf("%q failed: %w", name, err)
+	}
+
+	return stdout.String(), nil
+}
+
+// runExternalAction runs the action's command with input on stdin and returns its stdout
+func runExternalAction(action ExternalAction, input string) (string, error) {
+	output, err := runShellCommand(action.Name, action.Command,
+		time.Duration(action.Timeout)*time.Second, input)
+	if err != nil {
+		return "", fmt.Errorf("action %w", err)
+	}
+
+	// Most scripts end their output with a newline that isn't part of the content
+	output = strings.TrimSuffix(output, "\n")
+	if output == "" {
+		return "", fmt.Errorf("action %q produced no output", action.Name)
+	}
+
+	return output, nil
+}
+
+// findAction looks up a configured action by name
+func (cm *ClipboardManager) findAction(name string) (ExternalAction, bool) {
+	for _, action := range cm.actions {
+		if action.Name == name {
+			return action, true
+		}
+	}
+	return ExternalAction{}, false
+}
+
+// applyAction runs the named action on the item at index and adds the result to the history
+func (cm *ClipboardManager) applyAction(name string, index int) (string, error) {
+	cm.mu.Lock()
+	if index < 0 || index >= cm.history.Len() {
+		cm.mu.Unlock()
+		return "", fmt.Errorf("no item at index %d", index)
+	}
+	content := cm.history.At(index).Content
+	cm.mu.Unlock()
+
+	return cm.transformContent(name, content)
+}
+
+// transformContent runs the named action on content and adds the result to the history.
+// The result is inserted without running content rules so transforms cannot loop.
+func (cm *ClipboardManager) transformContent(name, content string) (string, error) {
+	action, ok := cm.findAction(name)
+	if !ok {
+		return "", fmt.Errorf("unknown action %q", name)
+	}
+
+	output, err := runExternalAction(action, content)
+	if err != nil {
+		return "", err
+	}
+
+	cm.mu.Lock()
+	cm.insertItem(output)
+	cm.mu.Unlock()
+	return output, nil
+}
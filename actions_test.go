@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunShellCommandTimeoutKillsChildren(t *testing.T) {
+	// The background sleep keeps stdout open after the shell itself is killed
+	start := time.Now()
+	_, err := runShellCommand("slow", "sleep 30 & sleep 30", 500*time.Millisecond, "")
+	if err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("command returned after %s, want it killed on timeout", elapsed)
+	}
+}
+
+func TestRunShellCommandOutput(t *testing.T) {
+	output, err := runShellCommand("upper", `tr a-z A-Z; echo "$EXTRA"`, 0, "abc\n", "EXTRA=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "ABC\nx\n" {
+		t.Errorf("output = %q", output)
+	}
+}
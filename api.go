@@ -0,0 +1,454 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"NoteBoard/core"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const defaultAPIPort = 7755
+
+// Largest request body, enough for an item of maxItemSize
+const apiMaxBodySize = maxItemSize + 1<<10
+
+// APISettings configures the local HTTP API
+type APISettings struct {
+	Enabled bool   `json:"enabled"`
+	Port    int    `json:"port"`
+	Token   string `json:"token"` // Sent as "Authorization: Bearer <token>"
+}
+
+// apiItem is the JSON form of an item in API responses
+type apiItem struct {
+	Index int `json:"index"`
+	core.Item
+}
+
+// apiServer is the running HTTP API and its event stream subscribers
+type apiServer struct {
+	server *http.Server
+
+	mu          sync.Mutex
+	subscribers map[chan []byte]bool
+}
+
+// newAPIToken returns a random access token
+func newAPIToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startAPI serves the API on localhost if it is enabled. Callers hold cm.mu.
+func (cm *ClipboardManager) startAPI() {
+	if !cm.api.Enabled || cm.apiServer != nil {
+		return
+	}
+	if cm.api.Port == 0 || cm.api.Token == "" {
+		// Enabled by editing the config file, store the generated defaults and start with them
+		cm.updateAPI(cm.api)
+		return
+	}
+
+	// Only local processes may connect
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(cm.api.Port))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		slog.Warn("could not start HTTP API", "err", err)
+		return
+	}
+
+	api := &apiServer{subscribers: make(map[chan []byte]bool)}
+	api.server = &http.Server{Handler: cm.apiHandler(), ReadHeaderTimeout: 10 * time.Second}
+	cm.apiServer = api
+
+	go func() {
+		if err := api.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Warn("HTTP API stopped", "err", err)
+		}
+	}()
+}
+
+// stopAPI shuts the API down, ending all event streams. Callers hold cm.mu.
+func (cm *ClipboardManager) stopAPI() {
+	if cm.apiServer == nil {
+		return
+	}
+	cm.apiServer.server.Close()
+	cm.apiServer = nil
+}
+
+// setAPI stores the API settings and restarts the server
+func (cm *ClipboardManager) setAPI(settings APISettings) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.updateAPI(settings)
+}
+
+// updateAPI stores the API settings, generating a missing port or token, and
+// restarts the server. Callers hold cm.mu.
+func (cm *ClipboardManager) updateAPI(settings APISettings) {
+	if settings.Port == 0 {
+		settings.Port = defaultAPIPort
+	}
+	if settings.Token == "" {
+		settings.Token = newAPIToken()
+	}
+
+	cm.stopAPI()
+	cm.api = settings
+
+	config := loadConfig()
+	config.API = settings
+	saveConfig(config)
+
+	cm.startAPI()
+}
+
+// apiHandler routes the API endpoints:
+//
+//	GET    /items?q=<text>&tag=<tag>  list or search the history
+//	POST   /items                     add {"content": "..."}
+//	DELETE /items                     clear all but pinned items
+//	GET    /items/{id}                get one item
+//	DELETE /items/{id}                delete an item
+//	POST   /items/{id}/pin            pin, or unpin with {"pinned": false}
+//	POST   /items/{id}/copy           put an item on the clipboard
+//	GET    /events                    Server-Sent Events stream of new items
+func (cm *ClipboardManager) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items", cm.apiListItems)
+	mux.HandleFunc("POST /items", cm.apiAddItem)
+	mux.HandleFunc("DELETE /items", cm.apiClearItems)
+	mux.HandleFunc("GET /items/{id}", cm.apiGetItem)
+	mux.HandleFunc("DELETE /items/{id}", cm.apiDeleteItem)
+	mux.HandleFunc("POST /items/{id}/pin", cm.apiPinItem)
+	mux.HandleFunc("POST /items/{id}/copy", cm.apiCopyItem)
+	mux.HandleFunc("GET /events", cm.apiEvents)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := cm.apiAuthorize(r); err != nil {
+			apiError(w, http.StatusUnauthorized, err)
+			return
+		}
+		if cm.isLocked() {
+			apiError(w, http.StatusLocked, errors.New("history is locked, unlock it in the NoteBoard window"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// apiAuthorize checks the token and that the request was meant for localhost,
+// which keeps websites from reaching the API through DNS rebinding
+func (cm *ClipboardManager) apiAuthorize(r *http.Request) error {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if host != "127.0.0.1" && host != "localhost" {
+		return errors.New("invalid host")
+	}
+
+	// EventSource can't set headers, so the event stream also takes the token as a parameter
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	cm.mu.Lock()
+	expected := cm.api.Token
+	cm.mu.Unlock()
+	if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+// apiJSON writes a JSON response
+func apiJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// apiError writes a JSON error response
+func apiError(w http.ResponseWriter, status int, err error) {
+	apiJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// apiBodyTooLarge writes a 413 if err is from a body over apiMaxBodySize
+func apiBodyTooLarge(w http.ResponseWriter, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	apiError(w, http.StatusRequestEntityTooLarge, err)
+	return true
+}
+
+// apiItemAt returns the API form of the item at index. Callers hold cm.mu.
+func (cm *ClipboardManager) apiItemAt(index int) apiItem {
+	return apiItem{Index: index, Item: cm.history.At(index)}
+}
+
+// apiLookup finds the item named in the request path, writing a 404 if there is
+// none. Callers hold cm.mu.
+func (cm *ClipboardManager) apiLookup(w http.ResponseWriter, r *http.Request) (int, bool) {
+	index := cm.history.IndexOfID(r.PathValue("id"))
+	if index < 0 {
+		apiError(w, http.StatusNotFound, errors.New("no such item"))
+		return -1, false
+	}
+	return index, true
+}
+
+// apiListItems lists the history, optionally filtered by ?q=<text> and ?tag=<tag>
+func (cm *ClipboardManager) apiListItems(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	tags := make(map[string]bool)
+	for _, tag := range query["tag"] {
+		tags[tag] = true
+	}
+	cm.mu.Lock()
+	items := []apiItem{}
+	for _, i := range cm.history.Search(query.Get("q"), tags) {
+		items = append(items, cm.apiItemAt(i))
+	}
+	cm.mu.Unlock()
+	apiJSON(w, http.StatusOK, items)
+}
+
+// apiGetItem returns one item
+func (cm *ClipboardManager) apiGetItem(w http.ResponseWriter, r *http.Request) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if index, ok := cm.apiLookup(w, r); ok {
+		apiJSON(w, http.StatusOK, cm.apiItemAt(index))
+	}
+}
+
+// apiAddItem adds {"content": "..."} to the history like a copied item
+func (cm *ClipboardManager) apiAddItem(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Content string `json:"content"`
+	}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, apiMaxBodySize)).Decode(&body)
+	if apiBodyTooLarge(w, err) {
+		return
+	}
+	if err != nil || body.Content == "" {
+		apiError(w, http.StatusBadRequest, errors.New(`expected {"content": "..."}`))
+		return
+	}
+
+	cm.addItem(body.Content)
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	index := cm.history.IndexOfContent(body.Content)
+	if index < 0 {
+		apiError(w, http.StatusInternalServerError, errors.New("item was not added"))
+		return
+	}
+	apiJSON(w, http.StatusCreated, cm.apiItemAt(index))
+}
+
+// apiDeleteItem removes one item
+func (cm *ClipboardManager) apiDeleteItem(w http.ResponseWriter, r *http.Request) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if index, ok := cm.apiLookup(w, r); ok {
+		cm.removeItemLocked(index)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// apiClearItems clears the history except for pinned items
+func (cm *ClipboardManager) apiClearItems(w http.ResponseWriter, r *http.Request) {
+	cm.clearItems()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiPinItem pins an item, or unpins it with {"pinned": false}
+func (cm *ClipboardManager) apiPinItem(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		Pinned bool `json:"pinned"`
+	}{Pinned: true}
+	// An empty body pins, whether it was sent with a length of zero or chunked
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, apiMaxBodySize)).Decode(&body)
+	if apiBodyTooLarge(w, err) {
+		return
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		apiError(w, http.StatusBadRequest, errors.New(`expected {"pinned": true|false}`))
+		return
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	index, ok := cm.apiLookup(w, r)
+	if !ok {
+		return
+	}
+	cm.setPinnedLocked(index, body.Pinned)
+	apiJSON(w, http.StatusOK, cm.apiItemAt(index))
+}
+
+// apiCopyItem puts an item on the clipboard
+func (cm *ClipboardManager) apiCopyItem(w http.ResponseWriter, r *http.Request) {
+	cm.mu.Lock()
+	index, ok := cm.apiLookup(w, r)
+	var item core.Item
+	if ok {
+		item = cm.history.At(index)
+	}
+	cm.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if err := cm.copyItem(item); err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiEvents streams new items as Server-Sent Events until the client disconnects
+func (cm *ClipboardManager) apiEvents(w http.ResponseWriter, r *http.Request) {
+	cm.mu.Lock()
+	api := cm.apiServer
+	cm.mu.Unlock()
+	flusher, ok := w.(http.Flusher)
+	if api == nil || !ok {
+		apiError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+
+	events := make(chan []byte, 16)
+	api.mu.Lock()
+	api.subscribers[events] = true
+	api.mu.Unlock()
+	defer func() {
+		api.mu.Lock()
+		delete(api.subscribers, events)
+		api.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case data := <-events:
+			fmt.Fprintf(w, "event: item\ndata: %s\n\n", data)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// publishItem sends a new item to the event stream subscribers. Slow
+// subscribers miss events rather than holding up the history. Callers hold cm.mu.
+func (cm *ClipboardManager) publishItem(index int) {
+	api := cm.apiServer
+	if api == nil {
+		return
+	}
+
+	data, err := json.Marshal(cm.apiItemAt(index))
+	if err != nil {
+		return
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	for events := range api.subscribers {
+		select {
+		case events <- data:
+		default:
+		}
+	}
+}
+
+// createAPISettings builds the HTTP API section of the settings window
+func (cm *ClipboardManager) createAPISettings(settingsWindow fyne.Window) fyne.CanvasObject {
+	tokenEntry := widget.NewEntry()
+	tokenEntry.SetText(cm.api.Token)
+	tokenEntry.Disable()
+
+	portEntry := widget.NewEntry()
+	portEntry.SetText(strconv.Itoa(cm.api.Port))
+	if cm.api.Port == 0 {
+		portEntry.SetText(strconv.Itoa(defaultAPIPort))
+	}
+
+	apiToggle := widget.NewCheck("Enable local HTTP API", nil)
+	apiToggle.SetChecked(cm.api.Enabled)
+	apiToggle.OnChanged = func(checked bool) {
+		settings := cm.api
+		settings.Enabled = checked
+		cm.setAPI(settings)
+		tokenEntry.SetText(cm.api.Token)
+	}
+
+	portEntry.OnSubmitted = func(text string) {
+		port, err := strconv.Atoi(text)
+		if err != nil || port <= 0 || port > 65535 {
+			dialog.ShowError(fmt.Errorf("invalid port %q", text), settingsWindow)
+			return
+		}
+		settings := cm.api
+		settings.Port = port
+		cm.setAPI(settings)
+	}
+
+	copyTokenButton := widget.NewButton("Copy token", func() {
+		if err := cm.copySecret(cm.api.Token); err != nil {
+			dialog.ShowError(err, settingsWindow)
+		}
+	})
+	newTokenButton := widget.NewButton("New token", func() {
+		settings := cm.api
+		settings.Token = newAPIToken()
+		cm.setAPI(settings)
+		tokenEntry.SetText(cm.api.Token)
+	})
+
+	return container.NewVBox(
+		apiToggle,
+		widget.NewForm(
+			widget.NewFormItem("Port", portEntry),
+			widget.NewFormItem("Token", tokenEntry),
+		),
+		container.NewHBox(copyTokenButton, newTokenButton),
+	)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"NoteBoard/core"
+)
+
+// apiRequest sends a request for /items to the API handler and returns the status
+func apiRequest(cm *ClipboardManager, authorization string) int {
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+	r.Host = "127.0.0.1:7755"
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	cm.apiHandler().ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestAPIRequiresToken(t *testing.T) {
+	cm := newTestManager(t)
+
+	// Without a configured token nothing is accepted
+	for _, authorization := range []string{"", "Bearer ", "Bearer x"} {
+		if code := apiRequest(cm, authorization); code != http.StatusUnauthorized {
+			t.Errorf("%q without a token got %d, want 401", authorization, code)
+		}
+	}
+
+	cm.api = APISettings{Enabled: true, Port: defaultAPIPort, Token: "secret"}
+	for authorization, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer ":       http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		if code := apiRequest(cm, authorization); code != want {
+			t.Errorf("%q got %d, want %d", authorization, code, want)
+		}
+	}
+}
+
+func TestAPIPinBody(t *testing.T) {
+	cm := newTestManager(t)
+	cm.api = APISettings{Enabled: true, Port: defaultAPIPort, Token: "secret"}
+	cm.history.Add("hello", "text")
+	id := cm.history.At(0).ID
+
+	for _, tc := range []struct {
+		name string
+		body io.Reader
+		want int
+	}{
+		// A reader of unknown length is sent chunked
+		{"chunked empty", io.MultiReader(), http.StatusOK},
+		{"unpin", strings.NewReader(`{"pinned": false}`), http.StatusOK},
+		{"too large", io.MultiReader(strings.NewReader(`{"pinned": "`), strings.NewReader(strings.Repeat("x", apiMaxBodySize))), http.StatusRequestEntityTooLarge},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/items/"+id+"/pin", tc.body)
+		r.Host = "127.0.0.1:7755"
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		cm.apiHandler().ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Errorf("%s got %d, want %d", tc.name, w.Code, tc.want)
+		}
+	}
+	if cm.history.At(0).Pinned {
+		t.Error("item is still pinned")
+	}
+}
+
+func TestStartAPIGeneratesToken(t *testing.T) {
+	cm := newTestManager(t)
+	// A free port rather than the default one, which may be in use
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	cm.api = APISettings{Enabled: true, Port: port}
+
+	cm.mu.Lock()
+	cm.startAPI()
+	cm.stopAPI()
+	settings := cm.api
+	cm.mu.Unlock()
+
+	if settings.Token == "" || settings.Port != port {
+		t.Errorf("started with %+v", settings)
+	}
+	if saved := loadConfig().API; saved != settings {
+		t.Errorf("saved %+v, want %+v", saved, settings)
+	}
+}
+
+func TestCopySecretIsNotRecorded(t *testing.T) {
+	cm := newTestManager(t)
+	clipboard := cm.clipboard.(*core.MemoryClipboard)
+	cm.monitorClipboard()
+	defer close(cm.clipboardStop)
+
+	if err := cm.copySecret("token"); err != nil {
+		t.Fatal(err)
+	}
+	if hint, _ := clipboard.Read(passwordManagerHint); string(hint) != "secret" {
+		t.Errorf("got password manager hint %q", hint)
+	}
+	core.WriteText(clipboard, "hello")
+	waitForItems(t, cm, 1)
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if got := cm.history.At(0).Content; got != "hello" {
+		t.Errorf("recorded %q", got)
+	}
+}
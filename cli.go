@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// socketRequest is a single command sent by the CLI to the running instance
+type socketRequest struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Data    []byte   `json:"data,omitempty"`
+}
+
+// socketResponse is the running instance's answer to a socketRequest
+type socketResponse struct {
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+const cliUsage = `Usage: noteboard [--verbose] [command]
+
+Without a command NoteBoard starts the clipboard manager. It logs to
+noteboard.log in the config directory; --verbose adds debug messages,
+e.g. every external command run.
+
+  doctor                 Check the session and the external tools NoteBoard uses
+
+Commands (require a running instance):
+  show                   Show the history picker, e.g. from a compositor shortcut.
+                         On wlroots compositors it can be an overlay, see settings.
+  list                   Print the clipboard history
+  actions                Print the configured actions
+  action <name> [index]  Run an action on an item (default: newest item)
+  export [--markdown] [file]
+                         Export history and pins as JSON or Markdown (default: stdout)
+  import [--from <format>] [--pin] [file]
+                         Merge a history file into the history. Formats: noteboard
+                         (JSON export, default), klipper, copyq, gpaste. The file
+                         defaults to the Klipper or GPaste history of this user.
+                         Imports never evict items; imported items beyond the
+                         history limit are left out unless --pin pins them all.
+`
+
+// runCLI handles command line invocations and returns the process exit code
+func runCLI(args []string) int {
+	switch args[0] {
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return 0
+	case "doctor":
+		return runDoctor()
+	}
+
+	req := socketRequest{Command: args[0], Args: args[1:]}
+
+	// Files are read and written by the CLI so relative paths work as expected
+	var outputFile string
+	switch args[0] {
+	case "export":
+		req.Args = nil
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, "--") {
+				req.Args = append(req.Args, arg)
+			} else {
+				outputFile = arg
+			}
+		}
+
+	case "import":
+		format, file, pin := importNoteBoard, "", false
+		for i := 1; i < len(args); i++ {
+			if args[i] == "--from" && i+1 < len(args) {
+				i++
+				format = args[i]
+			} else if args[i] == "--pin" {
+				pin = true
+			} else {
+				file = args[i]
+			}
+		}
+		if file == "" {
+			file = defaultImportPath(format)
+		}
+		if file == "" {
+			fmt.Fprintln(os.Stderr, "noteboard: usage: noteboard import [--from <format>] [--pin] <file>")
+			return 1
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "noteboard: %v\n", err)
+			return 1
+		}
+		req.Args = []string{format}
+		if pin {
+			req.Args = append(req.Args, "--pin")
+		}
+		req.Data = data
+	}
+
+	resp, err := sendSocketRequest(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "noteboard: %v\n", err)
+		return 1
+	}
+
+	if outputFile != "" && resp.Error == "" {
+		if err := os.WriteFile(outputFile, []byte(resp.Output), 0600); err != nil {
+			fmt.Fprintf(os.Stderr, "noteboard: %v\n", err)
+			return 1
+		}
+	} else if resp.Output != "" {
+		fmt.Println(resp.Output)
+	}
+	if resp.Error != "" {
+		fmt.Fprintf(os.Stderr, "noteboard: %s\n", resp.Error)
+		return 1
+	}
+
+	return 0
+}
+
+// sendSocketRequest sends a request to the running instance and waits for the response
+func sendSocketRequest(req socketRequest) (socketResponse, error) {
+	var resp socketResponse
+
+	socketPath, err := getSocketPath()
+	if err != nil {
+		return resp, err
+	}
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return resp, fmt.Errorf("NoteBoard is not running: %w", err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return resp, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return resp, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return resp, nil
+}
+
+// serveSocket accepts CLI connections on the control socket
+func (cm *ClipboardManager) serveSocket(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			continue
+		}
+		go cm.handleSocketConn(conn)
+	}
+}
+
+// handleSocketConn reads one request from the connection and writes the response
+func (cm *ClipboardManager) handleSocketConn(conn net.Conn) {
+	defer conn.Close()
+
+	var req socketRequest
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		// Instance detection connects without sending anything
+		return
+	}
+
+	json.NewEncoder(conn).Encode(cm.handleCommand(req))
+}
+
+// handleCommand executes a CLI command against the clipboard history
+func (cm *ClipboardManager) handleCommand(req socketRequest) socketResponse {
+	// Showing the picker works while locked, the window asks to unlock
+	if req.Command == "show" {
+		cm.showPicker()
+		return socketResponse{}
+	}
+
+	// Don't hand out an encrypted history before the user unlocked it
+	if cm.isLocked() {
+		return socketResponse{Error: "history is locked, unlock it in the NoteBoard window"}
+	}
+
+	switch req.Command {
+	case "list":
+		cm.mu.Lock()
+		var lines []string
+		for i, item := range cm.history.Items() {
+			firstLine := strings.SplitN(item.Content, "\n", 2)[0]
+			lines = append(lines, fmt.Sprintf("%d\t%s\t%s", i, item.Timestamp.Format("15:04:05"), firstLine))
+		}
+		cm.mu.Unlock()
+		return socketResponse{Output: strings.Join(lines, "\n")}
+
+	case "actions":
+		var names []string
+		for _, action := range cm.actions {
+			names = append(names, action.Name)
+		}
+		return socketResponse{Output: strings.Join(names, "\n")}
+
+	case "action":
+		if len(req.Args) == 0 {
+			return socketResponse{Error: "usage: noteboard action <name> [index]"}
+		}
+
+		index := 0
+		if len(req.Args) > 1 {
+			var err error
+			index, err = strconv.Atoi(req.Args[1])
+			if err != nil {
+				return socketResponse{Error: fmt.Sprintf("invalid index %q", req.Args[1])}
+			}
+		}
+
+		output, err := cm.applyAction(req.Args[0], index)
+		if err != nil {
+			return socketResponse{Error: err.Error()}
+		}
+		return socketResponse{Output: output}
+
+	case "export":
+		return cm.handleExportCommand(req.Args)
+
+	case "import":
+		format := importNoteBoard
+		if len(req.Args) > 0 {
+			format = req.Args[0]
+		}
+		return cm.handleImportCommand(format, containsKey(req.Args, "--pin"), req.Data)
+	}
+
+	return socketResponse{Error: fmt.Sprintf("unknown command %q (see noteboard help)", req.Command)}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"NoteBoard/core"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/go-vgo/robotgo"
+)
+
+// Clipboard backends selectable in the config
+const (
+	clipboardAuto    = "auto"
+	clipboardWayland = "wayland"
+	clipboardX11     = "x11"
+	clipboardXclip   = "xclip"
+	clipboardXsel    = "xsel"
+	clipboardMemory  = "memory"
+
+	// How often backends without change notifications are polled
+	clipboardPollInterval = 500 * time.Millisecond
+)
+
+var clipboardBackends = []string{clipboardAuto, clipboardWayland, clipboardX11, clipboardXclip, clipboardXsel, clipboardMemory}
+
+// passwordManagerHint marks secret clipboard content, which Klipper and other
+// clipboard managers don't record
+const passwordManagerHint = "x-kde-passwordManagerHint"
+
+// newClipboardBackend returns the named clipboard backend, detecting one for "auto" or unknown names
+func newClipboardBackend(name string, isWayland bool) core.ClipboardBackend {
+	switch name {
+	case clipboardWayland:
+		return waylandClipboard{}
+	case clipboardX11:
+		return x11Clipboard{}
+	case clipboardXclip:
+		return xclipClipboard{}
+	case clipboardXsel:
+		return xselClipboard{}
+	case clipboardMemory:
+		return &core.MemoryClipboard{}
+	}
+	return detectClipboardBackend(isWayland)
+}
+
+// detectClipboardBackend picks the best backend whose tools are installed.
+// robotgo needs no tools but only handles text.
+func detectClipboardBackend(isWayland bool) core.ClipboardBackend {
+	if isWayland && hasCommand("wl-paste") && hasCommand("wl-copy") {
+		return waylandClipboard{}
+	}
+	if hasCommand("xclip") {
+		return xclipClipboard{}
+	}
+	if hasCommand("xsel") {
+		return xselClipboard{}
+	}
+	return x11Clipboard{}
+}
+
+// hasCommand reports whether a program is on the PATH
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// readClipboardTool runs a clipboard tool and returns its output. Errors include
+// the tool's message, e.g. wl-paste's "Nothing is copied".
+func readClipboardTool(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return output, nil
+}
+
+// writeClipboardTool runs a clipboard tool with data on its stdin
+func writeClipboardTool(data []byte, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(data)
+
+	// Don't capture output: the tools fork a child that keeps serving the selection
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// waylandClipboard uses wl-clipboard
+type waylandClipboard struct{}
+
+func (waylandClipboard) Name() string { return clipboardWayland }
+
+func (waylandClipboard) Targets() ([]string, error) {
+	output, err := readClipboardTool("wl-paste", "--list-types")
+	return strings.Fields(string(output)), err
+}
+
+func (waylandClipboard) Read(target string) ([]byte, error) {
+	if target == core.TextTarget {
+		return readClipboardTool("wl-paste", "-n")
+	}
+	return readClipboardTool("wl-paste", "-n", "-t", target)
+}
+
+func (waylandClipboard) Write(target string, data []byte) error {
+	if target == core.TextTarget {
+		return writeClipboardTool(data, "wl-copy")
+	}
+	return writeClipboardTool(data, "wl-copy", "--type", target)
+}
+
+// Watch is notified by wl-paste --watch. Compositors without the data control
+// protocol make it exit, so the clipboard is polled instead.
+func (waylandClipboard) Watch(stop <-chan struct{}, changed func()) error {
+	cmd := exec.Command("wl-paste", "--watch", "echo")
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		slog.Warn("could not watch the clipboard, polling instead", "err", err)
+		return core.PollWatch(clipboardPollInterval, stop, changed)
+	}
+
+	exited := make(chan struct{})
+	defer close(exited)
+	go func() {
+		select {
+		case <-stop:
+			cmd.Process.Kill()
+		case <-exited:
+		}
+	}()
+
+	// wl-paste runs echo once at startup and after every change
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		changed()
+	}
+	err = cmd.Wait()
+
+	select {
+	case <-stop:
+		return nil
+	default:
+	}
+	slog.Warn("wl-paste --watch exited, polling the clipboard instead", "err", err)
+	return core.PollWatch(clipboardPollInterval, stop, changed)
+}
+
+// x11Clipboard uses robotgo, which only handles text
+type x11Clipboard struct{}
+
+func (x11Clipboard) Name() string { return clipboardX11 }
+
+func (x11Clipboard) Targets() ([]string, error) {
+	return []string{core.TextTarget}, nil
+}
+
+func (x11Clipboard) Read(target string) ([]byte, error) {
+	if target != core.TextTarget {
+		return nil, fmt.Errorf("%w: %s", core.ErrTargetUnavailable, target)
+	}
+	text, err := robotgo.ReadAll()
+	return []byte(text), err
+}
+
+func (x11Clipboard) Write(target string, data []byte) error {
+	if target != core.TextTarget {
+		return fmt.Errorf("%w: %s", core.ErrTargetUnavailable, target)
+	}
+	return robotgo.WriteAll(string(data))
+}
+
+// WriteTargets serves the targets from NoteBoard itself, see x11selection.go
+func (x11Clipboard) WriteTargets(targets map[string][]byte) error {
+	return serveX11Clipboard(targets)
+}
+
+func (x11Clipboard) Watch(stop <-chan struct{}, changed func()) error {
+	return core.PollWatch(clipboardPollInterval, stop, changed)
+}
+
+// xclipClipboard uses xclip on the CLIPBOARD selection
+type xclipClipboard struct{}
+
+func (xclipClipboard) Name() string { return clipboardXclip }
+
+func (xclipClipboard) Targets() ([]string, error) {
+	output, err := readClipboardTool("xclip", "-selection", "clipboard", "-t", "TARGETS", "-o")
+	return strings.Fields(string(output)), err
+}
+
+func (xclipClipboard) Read(target string) ([]byte, error) {
+	if target == core.TextTarget {
+		return readClipboardTool("xclip", "-selection", "clipboard", "-o")
+	}
+	return readClipboardTool("xclip", "-selection", "clipboard", "-t", target, "-o")
+}
+
+func (xclipClipboard) Write(target string, data []byte) error {
+	if target == core.TextTarget {
+		return writeClipboardTool(data, "xclip", "-selection", "clipboard", "-i")
+	}
+	return writeClipboardTool(data, "xclip", "-selection", "clipboard", "-t", target, "-i")
+}
+
+// WriteTargets serves the targets from NoteBoard itself, see x11selection.go
+func (xclipClipboard) WriteTargets(targets map[string][]byte) error {
+	return serveX11Clipboard(targets)
+}
+
+func (xclipClipboard) Watch(stop <-chan struct{}, changed func()) error {
+	return core.PollWatch(clipboardPollInterval, stop, changed)
+}
+
+// xselClipboard uses xsel, which only handles text
+type xselClipboard struct{}
+
+func (xselClipboard) Name() string { return clipboardXsel }
+
+func (xselClipboard) Targets() ([]string, error) {
+	return []string{core.TextTarget}, nil
+}
+
+func (xselClipboard) Read(target string) ([]byte, error) {
+	if target != core.TextTarget {
+		return nil, fmt.Errorf("%w: %s", core.ErrTargetUnavailable, target)
+	}
+	return readClipboardTool("xsel", "--clipboard", "--output")
+}
+
+func (xselClipboard) Write(target string, data []byte) error {
+	if target != core.TextTarget {
+		return fmt.Errorf("%w: %s", core.ErrTargetUnavailable, target)
+	}
+	return writeClipboardTool(data, "xsel", "--clipboard", "--input")
+}
+
+// WriteTargets serves the targets from NoteBoard itself, see x11selection.go
+func (xselClipboard) WriteTargets(targets map[string][]byte) error {
+	return serveX11Clipboard(targets)
+}
+
+func (xselClipboard) Watch(stop <-chan struct{}, changed func()) error {
+	return core.PollWatch(clipboardPollInterval, stop, changed)
+}
+
+// readClipboardContent turns the clipboard into an item's content. Copied files
+// are read as a uri-list rather than as a path string.
+func readClipboardContent(backend core.ClipboardBackend) (string, error) {
+	if files, ok := readClipboardFiles(backend); ok {
+		return files, nil
+	}
+	return core.ReadText(backend)
+}
+
+// currentClipboard returns the clipboard backend in use
+func (cm *ClipboardManager) currentClipboard() core.ClipboardBackend {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.clipboard
+}
+
+// monitorClipboard adds new clipboard content to the history until the backend is switched
+func (cm *ClipboardManager) monitorClipboard() {
+	cm.mu.Lock()
+	backend := cm.clipboard
+	stop := make(chan struct{})
+	cm.clipboardStop = stop
+	cm.mu.Unlock()
+
+	go func() {
+		if err := core.Monitor(backend, readClipboardContent, stop, cm.addClipboardContent); err != nil {
+			slog.Error("could not watch the clipboard", "backend", backend.Name(), "err", err)
+		}
+	}()
+}
+
+// addClipboardContent adds copied content to the history, unless NoteBoard copied
+// it with copySecret
+func (cm *ClipboardManager) addClipboardContent(content string) {
+	cm.mu.Lock()
+	secret := cm.secretContent != "" && content == cm.secretContent
+	cm.mu.Unlock()
+
+	if !secret {
+		cm.addItem(content)
+	}
+}
+
+// copySecret puts text on the clipboard without adding it to the history. Where
+// the backend can offer several targets, other clipboard managers are asked not to
+// record it either.
+func (cm *ClipboardManager) copySecret(text string) error {
+	cm.mu.Lock()
+	cm.secretContent = text
+	backend := cm.clipboard
+	cm.mu.Unlock()
+
+	if writer, ok := backend.(core.TargetsWriter); ok {
+		return writer.WriteTargets(map[string][]byte{
+			core.TextTarget:     []byte(text),
+			passwordManagerHint: []byte("secret"),
+		})
+	}
+	return core.WriteText(backend, text)
+}
+
+// setClipboardBackend switches to another clipboard backend and saves the choice
+func (cm *ClipboardManager) setClipboardBackend(name string) {
+	backend := newClipboardBackend(name, cm.isWayland)
+
+	cm.mu.Lock()
+	if cm.clipboardStop != nil {
+		close(cm.clipboardStop)
+		cm.clipboardStop = nil
+	}
+	cm.clipboard = backend
+
+	config := loadConfig()
+	config.Clipboard = name
+	saveConfig(config)
+	cm.mu.Unlock()
+
+	cm.monitorClipboard()
+}
+
+// createClipboardSettings creates the clipboard backend selection for the settings window
+func (cm *ClipboardManager) createClipboardSettings() fyne.CanvasObject {
+	current := widget.NewLabel("")
+	showCurrent := func() {
+		current.SetText("In use: " + cm.currentClipboard().Name())
+	}
+	showCurrent()
+
+	backendSelect := widget.NewSelect(clipboardBackends, nil)
+	backendSelect.SetSelected(loadConfig().Clipboard)
+	backendSelect.OnChanged = func(name string) {
+		cm.setClipboardBackend(name)
+		showCurrent()
+	}
+
+	return container.NewVBox(
+		container.NewBorder(nil, nil, widget.NewLabel("Clipboard backend:"), nil, backendSelect),
+		current,
+	)
+}
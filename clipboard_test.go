@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"NoteBoard/core"
+)
+
+// waitForItems waits until the history holds n items
+func waitForItems(t *testing.T, cm *ClipboardManager, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cm.mu.Lock()
+		count := cm.history.Len()
+		cm.mu.Unlock()
+		if count == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d items, want %d", count, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMonitorAddsClipboardContent(t *testing.T) {
+	cm := newTestManager(t)
+	clipboard := cm.clipboard.(*core.MemoryClipboard)
+	cm.monitorClipboard()
+	defer close(cm.clipboardStop)
+
+	core.WriteText(clipboard, "hello")
+	waitForItems(t, cm, 1)
+
+	clipboard.WriteTargets(map[string][]byte{
+		uriListTarget:   []byte("file:///tmp/a%20b\r\nfile:///tmp/c\r\n"),
+		core.TextTarget: []byte("/tmp/a b\n/tmp/c"),
+	})
+	waitForItems(t, cm, 2)
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	item := cm.history.At(0)
+	if item.Type != typeFiles || item.Content != "file:///tmp/a%20b\nfile:///tmp/c" {
+		t.Errorf("copied files were added as %s %q", item.Type, item.Content)
+	}
+}
+
+func TestCopyItemWritesTargets(t *testing.T) {
+	t.Setenv("XDG_CURRENT_DESKTOP", "KDE")
+	cm := newTestManager(t)
+	clipboard := cm.clipboard.(*core.MemoryClipboard)
+
+	if err := cm.copyItem(core.Item{Content: "hello", Type: "text"}); err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := core.ReadText(clipboard); text != "hello" {
+		t.Errorf("clipboard holds %q", text)
+	}
+
+	files := core.Item{Content: "file:///tmp/a\nfile:///tmp/b", Type: typeFiles}
+	if err := cm.copyItem(files); err != nil {
+		t.Fatal(err)
+	}
+	data, err := clipboard.Read(uriListTarget)
+	if err != nil || string(data) != "file:///tmp/a\r\nfile:///tmp/b\r\n" {
+		t.Errorf("clipboard holds uri-list %q, %v", data, err)
+	}
+}
+
+func TestCopyFilesOffersAllTargets(t *testing.T) {
+	cm := newTestManager(t)
+	clipboard := cm.clipboard.(*core.MemoryClipboard)
+
+	files := core.Item{Content: "file:///tmp/a%20b\nfile:///tmp/c", Type: typeFiles}
+	if err := cm.copyItem(files); err != nil {
+		t.Fatal(err)
+	}
+	for target, want := range map[string]string{
+		uriListTarget:    "file:///tmp/a%20b\r\nfile:///tmp/c\r\n",
+		gnomeFilesTarget: "copy\nfile:///tmp/a%20b\nfile:///tmp/c",
+		core.TextTarget:  "/tmp/a b\n/tmp/c",
+	} {
+		if data, err := clipboard.Read(target); err != nil || string(data) != want {
+			t.Errorf("clipboard holds %s %q, %v; want %q", target, data, err, want)
+		}
+	}
+}
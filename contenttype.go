@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+)
+
+// Content types detected for clipboard items
+const (
+	typeText   = "text"
+	typeURL    = "url"
+	typePath   = "path"
+	typeEmail  = "email"
+	typeColor  = "color"
+	typeJSON   = "json"
+	typeCode   = "code"
+	typeNumber = "number"
+)
+
+var (
+	urlPattern      = regexp.MustCompile(`^(https?|ftp)://\S+$`)
+	emailPattern    = regexp.MustCompile(`^(mailto:)?[^\s@]+@[^\s@]+\.[^\s@]+$`)
+	hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+	rgbColorPattern = regexp.MustCompile(`^rgba?\(\s*(\d{1,3})\s*,\s*(\d{1,3})\s*,\s*(\d{1,3})\s*(?:,\s*([\d.]+)\s*)?\)$`)
+	numberPattern   = regexp.MustCompile(`^[-+]?(0x[0-9a-fA-F]+|[0-9][0-9,_]*(\.[0-9]+)?([eE][-+]?[0-9]+)?)$`)
+	pathPattern     = regexp.MustCompile(`^(/|~/|file://)[^\n]*$`)
+
+	// codeLinePattern matches lines that look like source code rather than prose
+	codeLinePattern = regexp.MustCompile(`(^\s*(func|def|class|import|package|return|if|for|while|const|let|var|fn|pub|#include|using|public|private)\b)|([;{}]\s*$)|(=>|:=|\)\s*\{)`)
+)
+
+// detectContentType classifies clipboard content into one of the content types
+func detectContentType(content string) string {
+	trimmed := strings.TrimSpace(content)
+
+	switch {
+	case trimmed == "":
+		return typeText
+	case isURIList(trimmed):
+		return typeFiles
+	case urlPattern.MatchString(trimmed):
+		return typeURL
+	case emailPattern.MatchString(trimmed):
+		return typeEmail
+	case hexColorPattern.MatchString(trimmed) || rgbColorPattern.MatchString(trimmed):
+		return typeColor
+	case (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)):
+		return typeJSON
+	case numberPattern.MatchString(trimmed):
+		return typeNumber
+	case pathPattern.MatchString(trimmed):
+		return typePath
+	case looksLikeCode(trimmed):
+		return typeCode
+	}
+
+	return typeText
+}
+
+// looksLikeCode reports whether most non-empty lines of a multi-line text look like code
+func looksLikeCode(content string) bool {
+	lines := strings.Split(content, "\n")
+	if len(lines) < 2 {
+		return false
+	}
+
+	nonEmpty, matches := 0, 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		nonEmpty++
+		if codeLinePattern.MatchString(line) {
+			matches++
+		}
+	}
+
+	return nonEmpty > 0 && matches*2 >= nonEmpty
+}
+
+// contentTypeIcon returns the icon shown in the row for a content type
+func contentTypeIcon(itemType string) fyne.Resource {
+	switch itemType {
+	case typeURL:
+		return theme.ComputerIcon()
+	case typePath:
+		return theme.FolderIcon()
+	case typeEmail:
+		return theme.MailComposeIcon()
+	case typeColor:
+		return theme.ColorPaletteIcon()
+	case typeJSON:
+		return theme.ListIcon()
+	case typeCode:
+		return theme.FileApplicationIcon()
+	case typeNumber:
+		return theme.GridIcon()
+	case typeFiles:
+		return theme.FileIcon()
+	}
+
+	return theme.FileTextIcon()
+}
+
+// parseColor parses hex (#rgb, #rgba, #rrggbb, #rrggbbaa) and rgb()/rgba() colours
+func parseColor(content string) (color.Color, bool) {
+	s := strings.TrimSpace(content)
+
+	if m := rgbColorPattern.FindStringSubmatch(s); m != nil {
+		var c [3]uint8
+		for i := range c {
+			v, err := strconv.Atoi(m[i+1])
+			if err != nil || v > 255 {
+				return nil, false
+			}
+			c[i] = uint8(v)
+		}
+
+		alpha := uint8(255)
+		if m[4] != "" {
+			a, err := strconv.ParseFloat(m[4], 64)
+			if err != nil || a < 0 || a > 1 {
+				return nil, false
+			}
+			alpha = uint8(a * 255)
+		}
+
+		return color.NRGBA{R: c[0], G: c[1], B: c[2], A: alpha}, true
+	}
+
+	if !hexColorPattern.MatchString(s) {
+		return nil, false
+	}
+
+	hex := s[1:]
+	if len(hex) <= 4 {
+		// Expand the short form, e.g. #f0a -> #ff00aa
+		var expanded strings.Builder
+		for _, r := range hex {
+			expanded.WriteRune(r)
+			expanded.WriteRune(r)
+		}
+		hex = expanded.String()
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, false
+	}
+
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, true
+}
@@ -0,0 +1,169 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// TextTarget is the MIME type of plain text clipboard content
+const TextTarget = "text/plain;charset=utf-8"
+
+// ErrTargetUnavailable is returned when the clipboard doesn't hold or can't serve a target
+var ErrTargetUnavailable = errors.New("clipboard target unavailable")
+
+// ClipboardBackend reads and writes the system clipboard. Content is offered in
+// targets, which are MIME types such as TextTarget or text/uri-list.
+type ClipboardBackend interface {
+	// Name identifies the backend in the config and diagnostics
+	Name() string
+	// Targets lists the targets the current clipboard content is offered in
+	Targets() ([]string, error)
+	// Read returns the clipboard content in a target
+	Read(target string) ([]byte, error)
+	// Write replaces the clipboard content with data offered in a target
+	Write(target string, data []byte) error
+	// Watch calls changed whenever the clipboard may have changed, until stop is
+	// closed. It returns an error if the clipboard can't be watched at all.
+	Watch(stop <-chan struct{}, changed func()) error
+}
+
+// TargetsWriter is implemented by backends that can offer content in several
+// targets at once, like an application offering both a file list and its text form
+type TargetsWriter interface {
+	// WriteTargets replaces the clipboard content with data offered in each target
+	WriteTargets(targets map[string][]byte) error
+}
+
+// ReadText reads the clipboard as text
+func ReadText(backend ClipboardBackend) (string, error) {
+	data, err := backend.Read(TextTarget)
+	return string(data), err
+}
+
+// WriteText puts text on the clipboard
+func WriteText(backend ClipboardBackend, text string) error {
+	return backend.Write(TextTarget, []byte(text))
+}
+
+// PollWatch implements Watch for backends without change notifications by
+// calling changed every interval until stop is closed
+func PollWatch(interval time.Duration, stop <-chan struct{}, changed func()) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		changed()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// Monitor calls changed with new, non-empty clipboard content until stop is closed.
+// read turns the clipboard into an item's content, e.g. ReadText.
+func Monitor(backend ClipboardBackend, read func(ClipboardBackend) (string, error), stop <-chan struct{}, changed func(content string)) error {
+	last := ""
+	return backend.Watch(stop, func() {
+		if content, err := read(backend); err == nil && content != "" && content != last {
+			last = content
+			changed(content)
+		}
+	})
+}
+
+// MemoryClipboard is an in-memory clipboard, e.g. for running without a display
+// or in tests. The zero value is an empty clipboard.
+type MemoryClipboard struct {
+	mu       sync.Mutex
+	targets  map[string][]byte
+	watchers []chan struct{}
+}
+
+// Name returns "memory"
+func (c *MemoryClipboard) Name() string {
+	return "memory"
+}
+
+// Targets lists the targets written last
+func (c *MemoryClipboard) Targets() ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var targets []string
+	for target := range c.targets {
+		targets = append(targets, target)
+	}
+	slices.Sort(targets)
+	return targets, nil
+}
+
+// Read returns the data written for a target
+func (c *MemoryClipboard) Read(target string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, ok := c.targets[target]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTargetUnavailable, target)
+	}
+	return slices.Clone(data), nil
+}
+
+// Write replaces the clipboard content and notifies the watchers
+func (c *MemoryClipboard) Write(target string, data []byte) error {
+	return c.WriteTargets(map[string][]byte{target: data})
+}
+
+// WriteTargets replaces the clipboard content with data offered in several targets
+func (c *MemoryClipboard) WriteTargets(targets map[string][]byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.targets = make(map[string][]byte, len(targets))
+	for target, data := range targets {
+		c.targets[target] = slices.Clone(data)
+	}
+	for _, watcher := range c.watchers {
+		select {
+		case watcher <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Watch calls changed once, then after every write until stop is closed
+func (c *MemoryClipboard) Watch(stop <-chan struct{}, changed func()) error {
+	notify := make(chan struct{}, 1)
+	c.mu.Lock()
+	c.watchers = append(c.watchers, notify)
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.watchers = slices.DeleteFunc(c.watchers, func(w chan struct{}) bool { return w == notify })
+		c.mu.Unlock()
+	}()
+
+	for {
+		changed()
+
+		select {
+		case <-notify:
+		case <-stop:
+			return nil
+		}
+	}
+}
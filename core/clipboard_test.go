@@ -0,0 +1,90 @@
+package core
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMemoryClipboard(t *testing.T) {
+	var clipboard MemoryClipboard
+	if _, err := clipboard.Read(TextTarget); !errors.Is(err, ErrTargetUnavailable) {
+		t.Errorf("reading an empty clipboard returned %v", err)
+	}
+
+	clipboard.WriteTargets(map[string][]byte{
+		"text/uri-list": []byte("file:///tmp/a\r\n"),
+		TextTarget:      []byte("/tmp/a"),
+	})
+	targets, _ := clipboard.Targets()
+	if want := []string{TextTarget, "text/uri-list"}; !slices.Equal(targets, want) {
+		t.Errorf("got targets %q, want %q", targets, want)
+	}
+
+	// A write replaces all targets
+	WriteText(&clipboard, "hello")
+	if text, err := ReadText(&clipboard); err != nil || text != "hello" {
+		t.Errorf("ReadText returned %q, %v", text, err)
+	}
+	if _, err := clipboard.Read("text/uri-list"); err == nil {
+		t.Error("old target survived a write")
+	}
+}
+
+func TestMonitorReportsChanges(t *testing.T) {
+	var clipboard MemoryClipboard
+	changes := make(chan string, 10)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- Monitor(&clipboard, ReadText, stop, func(content string) {
+			changes <- content
+		})
+	}()
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-changes:
+			if got != want {
+				t.Errorf("got change %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no change reported, want %q", want)
+		}
+	}
+
+	WriteText(&clipboard, "a")
+	expect("a")
+	WriteText(&clipboard, "a")
+	WriteText(&clipboard, "")
+	WriteText(&clipboard, "b")
+	expect("b")
+	WriteText(&clipboard, "a")
+	expect("a")
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Errorf("Monitor returned %v", err)
+	}
+	select {
+	case content := <-changes:
+		t.Errorf("unexpected change %q", content)
+	default:
+	}
+}
+
+func TestPollWatch(t *testing.T) {
+	stop := make(chan struct{})
+	calls := 0
+	err := PollWatch(time.Millisecond, stop, func() {
+		calls++
+		if calls == 3 {
+			close(stop)
+		}
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("PollWatch returned %v after %d calls", err, calls)
+	}
+}
@@ -0,0 +1,356 @@
+// Package core manages NoteBoard's clipboard history independently of any user
+// interface: adding with deduplication, pinning, eviction, tags and search. The
+// history is persisted through a Storage and fed from a ClipboardBackend.
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Item is one entry of the clipboard history. It is also the history's on-disk form.
+type Item struct {
+	ID        string    `json:"id,omitempty"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+	Type      string    `json:"type"`
+	Pinned    bool      `json:"pinned,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Edited    time.Time `json:"edited,omitzero"` // When the content was last edited, zero if never
+}
+
+// NewItemID returns a random ID for a new item
+func NewItemID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// History is the clipboard history, newest item first. It is not safe for
+// concurrent use; callers synchronise access themselves.
+type History struct {
+	items []Item
+	limit int
+}
+
+// NewHistory returns an empty history holding up to limit unpinned items and any number of pinned ones
+func NewHistory(limit int) *History {
+	return &History{items: make([]Item, 0, limit), limit: limit}
+}
+
+// Len returns the number of items
+func (h *History) Len() int {
+	return len(h.items)
+}
+
+// At returns the item at index, which must be in range
+func (h *History) At(index int) Item {
+	return h.items[index]
+}
+
+// Items returns a copy of all items, newest first
+func (h *History) Items() []Item {
+	return slices.Clone(h.items)
+}
+
+// IndexOfID returns the index of the item with the given ID, or -1
+func (h *History) IndexOfID(id string) int {
+	return slices.IndexFunc(h.items, func(item Item) bool { return item.ID == id })
+}
+
+// IndexOfContent returns the index of the item with the given content, or -1
+func (h *History) IndexOfContent(content string) int {
+	return slices.IndexFunc(h.items, func(item Item) bool { return item.Content == content })
+}
+
+// Add puts content at the top of the history. Content that is already in the
+// history moves to the top, keeping its ID, pin and tags. When the history is
+// full the oldest unpinned item is evicted. Add returns false if content is
+// empty or already the most recent item.
+func (h *History) Add(content, itemType string) bool {
+	if content == "" || (len(h.items) > 0 && h.items[0].Content == content) {
+		return false
+	}
+
+	item := Item{
+		ID:        NewItemID(),
+		Content:   content,
+		Timestamp: time.Now(),
+		Type:      itemType,
+	}
+	if i := h.IndexOfContent(content); i >= 0 {
+		item.ID = h.items[i].ID
+		item.Pinned = h.items[i].Pinned
+		item.Tags = h.items[i].Tags
+		h.items = slices.Delete(h.items, i, i+1)
+	}
+
+	h.items = slices.Insert(h.items, 0, item)
+	h.evict()
+	return true
+}
+
+// Remove removes the item at index and returns it
+func (h *History) Remove(index int) (Item, bool) {
+	if index < 0 || index >= len(h.items) {
+		return Item{}, false
+	}
+	item := h.items[index]
+	h.items = slices.Delete(h.items, index, index+1)
+	return item, true
+}
+
+// SetPinned pins or unpins the item at index. Pinned items survive Clear and eviction.
+func (h *History) SetPinned(index int, pinned bool) bool {
+	if index < 0 || index >= len(h.items) {
+		return false
+	}
+	h.items[index].Pinned = pinned
+	return true
+}
+
+// SetTags replaces the tags of the item at index
+func (h *History) SetTags(index int, tags []string) bool {
+	if index < 0 || index >= len(h.items) {
+		return false
+	}
+	h.items[index].Tags = tags
+	return true
+}
+
+// SetContent replaces the content of the item at index, keeping its ID, timestamp,
+// pin and tags, and marks it as edited. Another item with the same content is
+// folded into it, combining pins and tags, so the history stays free of
+// duplicates; its ID is returned so the removal can be passed on. SetContent
+// returns false if content is empty or unchanged.
+func (h *History) SetContent(index int, content, itemType string) (folded string, ok bool) {
+	if index < 0 || index >= len(h.items) || content == "" || h.items[index].Content == content {
+		return "", false
+	}
+
+	item := h.items[index]
+	item.Content = content
+	item.Type = itemType
+	item.Edited = time.Now()
+
+	if i := h.IndexOfContent(content); i >= 0 {
+		item.Pinned = item.Pinned || h.items[i].Pinned
+		for _, tag := range h.items[i].Tags {
+			if !slices.Contains(item.Tags, tag) {
+				item.Tags = append(item.Tags, tag)
+			}
+		}
+		folded = h.items[i].ID
+		h.items = slices.Delete(h.items, i, i+1)
+		if i < index {
+			index--
+		}
+	}
+
+	h.items[index] = item
+	return folded, true
+}
+
+// Clear removes all unpinned items and returns them
+func (h *History) Clear() []Item {
+	var kept, removed []Item
+	for _, item := range h.items {
+		if item.Pinned {
+			kept = append(kept, item)
+		} else {
+			removed = append(removed, item)
+		}
+	}
+	h.items = append(h.items[:0], kept...)
+	return removed
+}
+
+// Replace replaces the history with items, sorted newest first. Items without an
+// ID, e.g. saved by older versions, are given one.
+func (h *History) Replace(items []Item) {
+	h.items = sortedWithIDs(items)
+	h.evict()
+}
+
+// Merge adds items to the history, deduplicating by content. Duplicates keep the
+// newer timestamp and combine pins and tags. Merging never evicts items already in
+// the history: new unpinned items only fill the room left under the limit, newest
+// first, while new pinned ones are always kept. Merge returns the number of new
+// items kept and the number left out for lack of room.
+func (h *History) Merge(items []Item) (added, dropped int) {
+	merged := h.Items()
+	byContent := make(map[string]int, len(merged))
+	room := h.limit
+	for i, item := range merged {
+		byContent[item.Content] = i
+		if !item.Pinned {
+			room--
+		}
+	}
+
+	var fresh []Item
+	for _, item := range items {
+		if item.Content == "" {
+			continue
+		}
+
+		i, exists := byContent[item.Content]
+		if !exists {
+			byContent[item.Content] = len(merged) + len(fresh)
+			fresh = append(fresh, item)
+			continue
+		}
+
+		existing := &merged[i]
+		if i >= len(merged) {
+			existing = &fresh[i-len(merged)]
+		}
+		existing.Pinned = existing.Pinned || item.Pinned
+		if item.Timestamp.After(existing.Timestamp) {
+			existing.Timestamp = item.Timestamp
+		}
+		for _, tag := range item.Tags {
+			if !slices.Contains(existing.Tags, tag) {
+				// Clipped so the history's own slice isn't appended to
+				existing.Tags = append(slices.Clip(existing.Tags), tag)
+			}
+		}
+	}
+
+	for _, item := range sortedWithIDs(fresh) {
+		switch {
+		case item.Pinned:
+		case room > 0:
+			room--
+		default:
+			dropped++
+			continue
+		}
+		merged = append(merged, item)
+		added++
+	}
+
+	h.items = sortedWithIDs(merged)
+	return added, dropped
+}
+
+// sortedWithIDs returns a copy of items sorted newest first, giving items without
+// an ID one
+func sortedWithIDs(items []Item) []Item {
+	items = slices.Clone(items)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Timestamp.After(items[j].Timestamp)
+	})
+	for i := range items {
+		if items[i].ID == "" {
+			items[i].ID = NewItemID()
+		}
+	}
+	return items
+}
+
+// FoldDuplicates folds items with equal content into the newest of them, which
+// keeps its ID and gains the others' pins and tags. Ties go to the greater ID, so
+// devices folding the same items agree on which one is kept. It returns the kept
+// items, newest first, and the IDs of the folded ones.
+func FoldDuplicates(items []Item) (kept []Item, folded []string) {
+	items = slices.Clone(items)
+	slices.SortStableFunc(items, func(a, b Item) int {
+		if c := b.Timestamp.Compare(a.Timestamp); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+
+	byContent := make(map[string]int)
+	for _, item := range items {
+		i, seen := byContent[item.Content]
+		if !seen {
+			byContent[item.Content] = len(kept)
+			kept = append(kept, item)
+			continue
+		}
+
+		kept[i].Pinned = kept[i].Pinned || item.Pinned
+		for _, tag := range item.Tags {
+			if !slices.Contains(kept[i].Tags, tag) {
+				kept[i].Tags = append(slices.Clip(kept[i].Tags), tag)
+			}
+		}
+		folded = append(folded, item.ID)
+	}
+	return kept, folded
+}
+
+// evict drops the oldest unpinned items while there are more than the limit.
+// Pinned items don't count towards the limit and are never evicted, so pinning
+// doesn't take room from new items and a history of pinned items isn't cut down.
+func (h *History) evict() {
+	unpinned := 0
+	for _, item := range h.items {
+		if !item.Pinned {
+			unpinned++
+		}
+	}
+
+	for i := len(h.items) - 1; i >= 0 && unpinned > h.limit; i-- {
+		if !h.items[i].Pinned {
+			h.items = slices.Delete(h.items, i, i+1)
+			unpinned--
+		}
+	}
+}
+
+// Tags returns the sorted tags used by any item
+func (h *History) Tags() []string {
+	var tags []string
+	for _, item := range h.items {
+		for _, tag := range item.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Search returns the indices of the items matching a search text and tags, see Matches
+func (h *History) Search(searchText string, tags map[string]bool) []int {
+	searchText = strings.ToLower(searchText)
+
+	var indices []int
+	for i, item := range h.items {
+		if Matches(item, searchText, tags) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// Matches reports whether an item has all the given tags and contains the
+// lower-case search text in its content or tags
+func Matches(item Item, searchText string, tags map[string]bool) bool {
+	for tag := range tags {
+		if !slices.Contains(item.Tags, tag) {
+			return false
+		}
+	}
+
+	if searchText == "" {
+		return true
+	}
+	if strings.Contains(strings.ToLower(item.Content), searchText) {
+		return true
+	}
+	for _, tag := range item.Tags {
+		if strings.Contains(strings.ToLower(tag), searchText) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,404 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// contents returns the content of every item, newest first
+func contents(h *History) []string {
+	var result []string
+	for _, item := range h.Items() {
+		result = append(result, item.Content)
+	}
+	return result
+}
+
+// historyOf returns a history with the given content added in order
+func historyOf(limit int, content ...string) *History {
+	h := NewHistory(limit)
+	for _, c := range content {
+		h.Add(c, "text")
+	}
+	return h
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial []string
+		add     string
+		added   bool
+		want    []string
+	}{
+		{"first item", nil, "a", true, []string{"a"}},
+		{"newest first", []string{"a"}, "b", true, []string{"b", "a"}},
+		{"empty content", []string{"a"}, "", false, []string{"a"}},
+		{"same as most recent", []string{"a", "b"}, "b", false, []string{"b", "a"}},
+		{"duplicate moves to top", []string{"a", "b", "c"}, "a", true, []string{"a", "c", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := historyOf(10, tt.initial...)
+			if added := h.Add(tt.add, "text"); added != tt.added {
+				t.Errorf("Add returned %v, want %v", added, tt.added)
+			}
+			if got := contents(h); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddDuplicateKeepsIdentity(t *testing.T) {
+	h := historyOf(10, "a", "b")
+	h.SetPinned(1, true)
+	h.SetTags(1, []string{"work"})
+	before := h.At(1)
+
+	h.Add("a", "text")
+
+	after := h.At(0)
+	if after.ID != before.ID || !after.Pinned || !slices.Equal(after.Tags, []string{"work"}) {
+		t.Errorf("duplicate lost its ID, pin or tags: before %+v, after %+v", before, after)
+	}
+	if h.Len() != 2 {
+		t.Errorf("got %d items, want 2", h.Len())
+	}
+}
+
+func TestAddAssignsIDs(t *testing.T) {
+	h := historyOf(10, "a", "b")
+	if h.At(0).ID == "" || h.At(0).ID == h.At(1).ID {
+		t.Errorf("items need distinct IDs, got %q and %q", h.At(0).ID, h.At(1).ID)
+	}
+	if i := h.IndexOfID(h.At(1).ID); i != 1 {
+		t.Errorf("IndexOfID returned %d, want 1", i)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	h := historyOf(3, "a", "b", "c")
+	h.Add("d", "text")
+	if got, want := contents(h), []string{"d", "c", "b"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The oldest unpinned item goes first; the pinned one doesn't count
+	h.SetPinned(2, true)
+	h.Add("e", "text")
+	h.Add("f", "text")
+	if got, want := contents(h), []string{"f", "e", "d", "b"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEvictionKeepsPinnedItems(t *testing.T) {
+	h := historyOf(2, "a", "b")
+	h.SetPinned(0, true)
+	h.SetPinned(1, true)
+
+	// Pinned items don't count towards the limit
+	h.Add("c", "text")
+	h.Add("d", "text")
+	if got, want := contents(h), []string{"d", "c", "b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Beyond the limit, the oldest unpinned item goes and no pinned one does
+	h.Add("e", "text")
+	if got, want := contents(h), []string{"e", "d", "b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for i := 2; i < h.Len(); i++ {
+		if !h.At(i).Pinned {
+			t.Errorf("item %d lost its pin", i)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	h := historyOf(10, "a", "b", "c")
+	h.SetPinned(0, true)
+
+	item, ok := h.Remove(1)
+	if !ok || item.Content != "b" {
+		t.Fatalf("Remove returned %q, %v", item.Content, ok)
+	}
+	if got, want := contents(h), []string{"c", "a"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if !h.At(0).Pinned || h.At(1).Pinned {
+		t.Error("pins moved to other items")
+	}
+
+	for _, index := range []int{-1, 2} {
+		if _, ok := h.Remove(index); ok {
+			t.Errorf("Remove(%d) succeeded out of range", index)
+		}
+	}
+}
+
+func TestClear(t *testing.T) {
+	h := historyOf(10, "a", "b", "c", "d")
+	h.SetPinned(1, true)
+	h.SetPinned(3, true)
+
+	removed := h.Clear()
+
+	if got, want := contents(h), []string{"c", "a"}; !slices.Equal(got, want) {
+		t.Errorf("kept %q, want %q", got, want)
+	}
+	if len(removed) != 2 || removed[0].Content != "d" || removed[1].Content != "b" {
+		t.Errorf("removed %+v", removed)
+	}
+	for _, item := range h.Items() {
+		if !item.Pinned {
+			t.Errorf("%q lost its pin", item.Content)
+		}
+	}
+}
+
+func TestSetContent(t *testing.T) {
+	h := historyOf(10, "a", "b", "c")
+	h.SetPinned(1, true)
+	h.SetTags(1, []string{"cmd"})
+	before := h.At(1)
+
+	folded, ok := h.SetContent(1, "b2", "code")
+	if !ok || folded != "" {
+		t.Fatalf("SetContent returned %q, %v", folded, ok)
+	}
+	edited := h.At(1)
+	if edited.Content != "b2" || edited.Type != "code" || edited.Edited.IsZero() {
+		t.Errorf("got %+v", edited)
+	}
+	if edited.ID != before.ID || !edited.Timestamp.Equal(before.Timestamp) || !edited.Pinned ||
+		!slices.Equal(edited.Tags, before.Tags) {
+		t.Errorf("edit lost the item's identity: %+v, was %+v", edited, before)
+	}
+
+	for _, content := range []string{"", "b2"} {
+		if _, ok := h.SetContent(1, content, "text"); ok {
+			t.Errorf("SetContent(%q) succeeded", content)
+		}
+	}
+	if _, ok := h.SetContent(3, "d", "text"); ok {
+		t.Error("SetContent succeeded out of range")
+	}
+}
+
+func TestSetContentFoldsDuplicates(t *testing.T) {
+	h := historyOf(10, "a", "b", "c")
+	h.SetTags(0, []string{"x"})
+	h.SetPinned(2, true)
+	id, duplicate := h.At(2).ID, h.At(0).ID
+
+	// Editing "a" into "c" folds the newer "c" into it
+	folded, ok := h.SetContent(2, "c", "text")
+	if !ok || folded != duplicate {
+		t.Fatalf("SetContent returned %q, %v; want the duplicate's ID %q", folded, ok, duplicate)
+	}
+	if got, want := contents(h), []string{"b", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	item := h.At(1)
+	if item.ID != id || !item.Pinned || !slices.Equal(item.Tags, []string{"x"}) {
+		t.Errorf("got %+v", item)
+	}
+	if h.IndexOfID(duplicate) >= 0 {
+		t.Error("the folded duplicate is still in the history")
+	}
+}
+
+func TestSetPinnedOutOfRange(t *testing.T) {
+	h := historyOf(10, "a")
+	if h.SetPinned(1, true) || h.SetPinned(-1, true) {
+		t.Error("SetPinned succeeded out of range")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	h := historyOf(10, "Hello world", "foo bar", "HELLO again")
+	h.SetTags(1, []string{"work"})
+	h.SetTags(2, []string{"work", "greeting"})
+
+	tests := []struct {
+		name string
+		text string
+		tags []string
+		want []int
+	}{
+		{"everything", "", nil, []int{0, 1, 2}},
+		{"case-insensitive", "hello", nil, []int{0, 2}},
+		{"matches tags", "greet", nil, []int{2}},
+		{"tag filter", "", []string{"work"}, []int{1, 2}},
+		{"all tags required", "", []string{"work", "greeting"}, []int{2}},
+		{"text and tag", "world", []string{"work"}, []int{2}},
+		{"no match", "missing", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags := make(map[string]bool)
+			for _, tag := range tt.tags {
+				tags[tag] = true
+			}
+			if got := h.Search(tt.text, tags); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got, want := h.Tags(), []string{"greeting", "work"}; !slices.Equal(got, want) {
+		t.Errorf("Tags returned %q, want %q", got, want)
+	}
+}
+
+func TestReplaceKeepsPinnedItems(t *testing.T) {
+	now := time.Now()
+	var items []Item
+	for i := range 5 {
+		items = append(items, Item{
+			Content:   fmt.Sprint(i),
+			Timestamp: now.Add(time.Duration(i) * time.Minute),
+			Pinned:    i == 0,
+		})
+	}
+
+	h := NewHistory(3)
+	h.Replace(items)
+
+	if got, want := contents(h), []string{"4", "3", "2", "0"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, item := range h.Items() {
+		if item.ID == "" {
+			t.Errorf("%q has no ID", item.Content)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	h := historyOf(10, "a", "b")
+	old := h.At(1).Timestamp.Add(-time.Hour)
+
+	added, dropped := h.Merge([]Item{
+		{Content: "a", Timestamp: old, Pinned: true, Tags: []string{"imported"}},
+		{Content: "c", Timestamp: old},
+		{Content: ""},
+	})
+
+	if added != 1 || dropped != 0 {
+		t.Errorf("Merge added %d and dropped %d items, want 1 and 0", added, dropped)
+	}
+	i := h.IndexOfContent("a")
+	if i < 0 || !h.At(i).Pinned || !slices.Equal(h.At(i).Tags, []string{"imported"}) || h.At(i).Timestamp.Equal(old) {
+		t.Errorf("duplicate was not merged: %+v", h.At(i))
+	}
+	if got, want := contents(h), []string{"b", "a", "c"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMergeBeyondLimit(t *testing.T) {
+	h := historyOf(4, "a", "b")
+	local := h.Items()
+
+	now := time.Now()
+	var imported []Item
+	for i := range 6 {
+		imported = append(imported, Item{
+			Content:   fmt.Sprint(i),
+			Timestamp: now.Add(time.Duration(i) * time.Minute),
+			Pinned:    i == 0,
+		})
+	}
+
+	// The pinned item and the two newest unpinned ones fit; no local item is evicted
+	added, dropped := h.Merge(imported)
+	if added != 3 || dropped != 3 {
+		t.Errorf("Merge added %d and dropped %d items, want 3 and 3", added, dropped)
+	}
+	if got, want := contents(h), []string{"5", "4", "0", "b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, item := range local {
+		if h.IndexOfID(item.ID) < 0 {
+			t.Errorf("local item %q was evicted", item.Content)
+		}
+	}
+
+	// Pinned items are kept however many there are
+	for i := range imported {
+		imported[i].Content += "-pinned"
+		imported[i].Pinned = true
+	}
+	if added, dropped := h.Merge(imported); added != 6 || dropped != 0 {
+		t.Errorf("Merge added %d and dropped %d pinned items, want 6 and 0", added, dropped)
+	}
+	if h.Len() != 11 {
+		t.Errorf("got %d items, want 11", h.Len())
+	}
+}
+
+func TestFoldDuplicates(t *testing.T) {
+	now := time.Now()
+	items := []Item{
+		{ID: "old", Content: "a", Timestamp: now.Add(-time.Hour), Pinned: true, Tags: []string{"x"}},
+		{ID: "new", Content: "a", Timestamp: now, Tags: []string{"y"}},
+		{ID: "b", Content: "b", Timestamp: now.Add(-time.Minute)},
+		{ID: "tie1", Content: "c", Timestamp: now.Add(-2 * time.Minute)},
+		{ID: "tie2", Content: "c", Timestamp: now.Add(-2 * time.Minute)},
+	}
+
+	kept, folded := FoldDuplicates(items)
+	var ids []string
+	for _, item := range kept {
+		ids = append(ids, item.ID)
+	}
+	if want := []string{"new", "b", "tie2"}; !slices.Equal(ids, want) {
+		t.Errorf("kept %q, want %q", ids, want)
+	}
+	if want := []string{"old", "tie1"}; !slices.Equal(slices.Sorted(slices.Values(folded)), want) {
+		t.Errorf("folded %q, want %q", folded, want)
+	}
+	if !kept[0].Pinned || !slices.Equal(kept[0].Tags, []string{"y", "x"}) {
+		t.Errorf("the kept item lost the pin or tags: %+v", kept[0])
+	}
+	if items[1].Pinned || len(items[1].Tags) != 1 {
+		t.Errorf("the input was modified: %+v", items[1])
+	}
+}
+
+func TestFileStorage(t *testing.T) {
+	storage := FileStorage{Path: filepath.Join(t.TempDir(), "history.json")}
+
+	items, err := storage.Load()
+	if err != nil || items != nil {
+		t.Fatalf("Load without a file returned %v, %v", items, err)
+	}
+
+	h := historyOf(10, "a", "b")
+	h.SetPinned(1, true)
+	if err := storage.Save(h.Items()); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewHistory(10)
+	items, err = storage.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.Replace(items)
+	if got, want := contents(loaded), contents(h); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if loaded.At(1).ID != h.At(1).ID || !loaded.At(1).Pinned {
+		t.Errorf("item did not survive saving: %+v", loaded.At(1))
+	}
+}
@@ -0,0 +1,30 @@
+package core
+
+import "strings"
+
+// JoinItems joins the content of items in the given order, separated by separator
+func JoinItems(items []Item, separator string) string {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = item.Content
+	}
+	return strings.Join(parts, separator)
+}
+
+// SplitContent splits content at every delimiter, dropping parts that are empty
+// or only whitespace. Parts keep their whitespace, such as indentation, except
+// for the \r of CRLF line ends when splitting by line.
+func SplitContent(content, delimiter string) []string {
+	if delimiter == "" {
+		return nil
+	}
+
+	var parts []string
+	for _, part := range strings.Split(content, delimiter) {
+		part = strings.TrimSuffix(part, "\r")
+		if strings.TrimSpace(part) != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestJoinItems(t *testing.T) {
+	items := []Item{{Content: "a"}, {Content: "b c"}, {Content: "d"}}
+	for separator, want := range map[string]string{
+		"\n": "a\nb c\nd",
+		" ":  "a b c d",
+		", ": "a, b c, d",
+	} {
+		if got := JoinItems(items, separator); got != want {
+			t.Errorf("JoinItems(%q) = %q, want %q", separator, got, want)
+		}
+	}
+	if got := JoinItems(nil, "\n"); got != "" {
+		t.Errorf("JoinItems(nil) = %q", got)
+	}
+}
+
+func TestSplitContent(t *testing.T) {
+	tests := []struct {
+		content   string
+		delimiter string
+		want      []string
+	}{
+		{"a\nb\n\nc\n", "\n", []string{"a", "b", "c"}},
+		{"a\r\n  b  \r\nc", "\n", []string{"a", "  b  ", "c"}},
+		{"x, y,,z", ",", []string{"x", " y", "z"}},
+		{"one -- two", "--", []string{"one ", " two"}},
+		{"a\r\n\r\n \t\r\nb\r\n", "\n", []string{"a", "b"}},
+		{"no delimiter", ";", []string{"no delimiter"}},
+		{" \n \n", "\n", nil},
+		{"a,b", "", nil},
+	}
+	for _, tt := range tests {
+		if got := SplitContent(tt.content, tt.delimiter); !slices.Equal(got, tt.want) {
+			t.Errorf("SplitContent(%q, %q) = %q, want %q", tt.content, tt.delimiter, got, tt.want)
+		}
+	}
+}
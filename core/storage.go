@@ -0,0 +1,78 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"sync"
+)
+
+// Storage persists the history
+type Storage interface {
+	// Load returns the saved items, or none if nothing was saved yet
+	Load() ([]Item, error)
+	// Save replaces the saved items
+	Save(items []Item) error
+}
+
+// Encode encodes items in the history file format
+func Encode(items []Item) ([]byte, error) {
+	return json.MarshalIndent(items, "", "  ")
+}
+
+// Decode decodes items in the history file format
+func Decode(data []byte) ([]Item, error) {
+	var items []Item
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// FileStorage stores the history as JSON in a file only the user can read
+type FileStorage struct {
+	Path string
+}
+
+// Load reads the history file
+func (s FileStorage) Load() ([]Item, error) {
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// Save writes the history file. History may contain sensitive data, so the file
+// is private to the user.
+func (s FileStorage) Save(items []Item) error {
+	data, err := Encode(items)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path, data, 0600)
+}
+
+// MemoryStorage keeps the history in memory
+type MemoryStorage struct {
+	mu    sync.Mutex
+	items []Item
+}
+
+// Load returns the saved items
+func (s *MemoryStorage) Load() ([]Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.items), nil
+}
+
+// Save replaces the saved items
+func (s *MemoryStorage) Save(items []Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = slices.Clone(items)
+	return nil
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Distribution families with a known package manager
+const (
+	distroArch   = "arch"
+	distroDebian = "debian"
+	distroFedora = "fedora"
+	distroSUSE   = "suse"
+)
+
+// installCommands are the package manager invocations of each distribution family
+var installCommands = map[string]string{
+	distroArch:   "sudo pacman -S",
+	distroDebian: "sudo apt install",
+	distroFedora: "sudo dnf install",
+	distroSUSE:   "sudo zypper install",
+}
+
+// sessionInfo describes the desktop session NoteBoard runs in
+type sessionInfo struct {
+	Type       string // wayland, x11 or tty
+	Desktop    string // XDG_CURRENT_DESKTOP
+	Compositor string
+	KDE        bool
+	Plasma     int    // Plasma major version, 0 outside KDE
+	Distro     string // Human-readable distribution name
+	Family     string // One of the distro constants, or empty if unknown
+}
+
+// externalTool is a program NoteBoard calls for features the toolkit lacks
+type externalTool struct {
+	Name     string
+	Purpose  string
+	Packages map[string]string // Package providing the tool, by distribution family
+	Required func(session sessionInfo) bool
+}
+
+// toolStatus is the probe result of one external tool
+type toolStatus struct {
+	externalTool
+	Path   string // Empty if the tool is not installed
+	Needed bool   // Whether this session uses the tool
+}
+
+// diagnosticsReport is what the settings status page and "noteboard doctor" show
+type diagnosticsReport struct {
+	Session   sessionInfo
+	Clipboard string // Name of the clipboard backend in use
+	Tools     []toolStatus
+}
+
+func onWayland(s sessionInfo) bool { return s.Type == "wayland" }
+func onX11(s sessionInfo) bool     { return s.Type == "x11" }
+
+// onCompositor returns a Required function matching a compositor
+func onCompositor(name string) func(sessionInfo) bool {
+	return func(s sessionInfo) bool { return s.Compositor == name }
+}
+
+// onPlasma returns a Required function matching a Plasma major version
+func onPlasma(version int) func(sessionInfo) bool {
+	return func(s sessionInfo) bool { return s.Plasma == version }
+}
+
+// externalTools lists every program NoteBoard may call
+var externalTools = []externalTool{
+	{
+		Name:     "wl-paste",
+		Purpose:  "reads and watches the clipboard on Wayland",
+		Packages: samePackage("wl-clipboard"),
+		Required: onWayland,
+	},
+	{
+		Name:     "wl-copy",
+		Purpose:  "copies items to the clipboard on Wayland",
+		Packages: samePackage("wl-clipboard"),
+		Required: onWayland,
+	},
+	{
+		Name:     "xclip",
+		Purpose:  "copies files and reads the clipboard on X11",
+		Packages: samePackage("xclip"),
+		Required: func(sessionInfo) bool { return false },
+	},
+	{
+		Name:     "xsel",
+		Purpose:  "reads the clipboard on X11 when xclip is missing",
+		Packages: samePackage("xsel"),
+		Required: func(sessionInfo) bool { return false },
+	},
+	{
+		Name:     "xdotool",
+		Purpose:  "moves the window to the cursor on X11",
+		Packages: samePackage("xdotool"),
+		Required: onX11,
+	},
+	{
+		Name:    "xrandr",
+		Purpose: "finds the monitor under the cursor on X11",
+		Packages: map[string]string{
+			distroArch:   "xorg-xrandr",
+			distroDebian: "x11-xserver-utils",
+			distroFedora: "xrandr",
+			distroSUSE:   "xrandr",
+		},
+		Required: onX11,
+	},
+	{
+		Name:     "wlr-randr",
+		Purpose:  "finds the monitor under the cursor on wlroots compositors",
+		Packages: samePackage("wlr-randr"),
+		Required: func(sessionInfo) bool { return false },
+	},
+	{
+		Name:     "hyprctl",
+		Purpose:  "finds the monitor and pointer position on Hyprland",
+		Packages: samePackage("hyprland"),
+		Required: onCompositor("Hyprland"),
+	},
+	{
+		Name:     "swaymsg",
+		Purpose:  "finds the monitor under the cursor on Sway",
+		Packages: samePackage("sway"),
+		Required: onCompositor("Sway"),
+	},
+	{
+		Name:    "kwriteconfig5",
+		Purpose: "registers the global shortcut on Plasma 5",
+		Packages: map[string]string{
+			distroArch:   "kconfig5",
+			distroDebian: "libkf5config-bin",
+			distroFedora: "kf5-kconfig-core",
+			distroSUSE:   "kconfig-tools",
+		},
+		Required: onPlasma(5),
+	},
+	{
+		Name:    "kwriteconfig6",
+		Purpose: "registers the global shortcut on Plasma 6",
+		Packages: map[string]string{
+			distroArch:   "kconfig",
+			distroDebian: "kf6-kconfig-bin",
+			distroFedora: "kf6-kconfig",
+			distroSUSE:   "kf6-kconfig-tools",
+		},
+		Required: onPlasma(6),
+	},
+}
+
+// samePackage returns a package map for tools packaged under the same name everywhere
+func samePackage(name string) map[string]string {
+	return map[string]string{distroArch: name, distroDebian: name, distroFedora: name, distroSUSE: name}
+}
+
+// detectSession probes the session type, desktop, compositor and distribution
+func detectSession() sessionInfo {
+	session := sessionInfo{
+		Type:    os.Getenv("XDG_SESSION_TYPE"),
+		Desktop: os.Getenv("XDG_CURRENT_DESKTOP"),
+		KDE:     isKDEPlasma(),
+	}
+	if session.Type == "" {
+		switch {
+		case os.Getenv("WAYLAND_DISPLAY") != "":
+			session.Type = "wayland"
+		case os.Getenv("DISPLAY") != "":
+			session.Type = "x11"
+		default:
+			session.Type = "tty"
+		}
+	}
+
+	desktop := strings.ToLower(session.Desktop)
+	switch {
+	case os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "":
+		session.Compositor = "Hyprland"
+	case os.Getenv("SWAYSOCK") != "":
+		session.Compositor = "Sway"
+	case session.KDE:
+		session.Compositor = fmt.Sprintf("KWin (Plasma %d)", getKDETools().version)
+	case strings.Contains(desktop, "gnome"):
+		session.Compositor = "Mutter"
+	case session.Desktop != "":
+		session.Compositor = session.Desktop
+	default:
+		session.Compositor = "unknown"
+	}
+
+	if session.KDE {
+		session.Plasma = getKDETools().version
+	}
+
+	session.Distro, session.Family = detectDistro("/etc/os-release")
+	return session
+}
+
+// detectDistro reads the distribution name and family from an os-release file
+func detectDistro(path string) (name, family string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "unknown", ""
+	}
+	defer file.Close()
+
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if found {
+			fields[key] = strings.Trim(value, `"'`)
+		}
+	}
+
+	name = fields["PRETTY_NAME"]
+	if name == "" {
+		name = fields["ID"]
+	}
+
+	// Derivatives name their parent in ID_LIKE, e.g. Manjaro is "arch"
+	for _, id := range append([]string{fields["ID"]}, strings.Fields(fields["ID_LIKE"])...) {
+		switch {
+		case id == "arch" || id == "manjaro" || id == "endeavouros":
+			return name, distroArch
+		case id == "debian" || id == "ubuntu":
+			return name, distroDebian
+		case id == "fedora" || id == "rhel":
+			return name, distroFedora
+		case strings.HasPrefix(id, "opensuse") || id == "suse":
+			return name, distroSUSE
+		}
+	}
+	return name, ""
+}
+
+// runDiagnostics probes the session and every external tool
+func runDiagnostics(clipboard string) diagnosticsReport {
+	report := diagnosticsReport{Session: detectSession(), Clipboard: clipboard}
+	for _, tool := range externalTools {
+		status := toolStatus{externalTool: tool, Needed: tool.Required(report.Session)}
+		status.Path, _ = exec.LookPath(tool.Name)
+		report.Tools = append(report.Tools, status)
+	}
+	return report
+}
+
+// missingTools returns the tools this session needs but that aren't installed
+func (r diagnosticsReport) missingTools() []toolStatus {
+	var missing []toolStatus
+	for _, tool := range r.Tools {
+		if tool.Needed && tool.Path == "" {
+			missing = append(missing, tool)
+		}
+	}
+	return missing
+}
+
+// toolsNamed returns the status of the named tools
+func (r diagnosticsReport) toolsNamed(names ...string) []toolStatus {
+	var tools []toolStatus
+	for _, tool := range r.Tools {
+		if slices.Contains(names, tool.Name) {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+// installHint returns the command installing the given tools on this distribution,
+// or the packages to look for if the package manager is unknown
+func (r diagnosticsReport) installHint(tools []toolStatus) string {
+	var packages, names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+		if pkg := tool.Packages[r.Session.Family]; pkg != "" && !slices.Contains(packages, pkg) {
+			packages = append(packages, pkg)
+		}
+	}
+
+	if command, ok := installCommands[r.Session.Family]; ok && len(packages) > 0 {
+		return command + " " + strings.Join(packages, " ")
+	}
+	return "Install the packages providing " + strings.Join(names, ", ") + " with your package manager"
+}
+
+// String formats the report for the terminal
+func (r diagnosticsReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Session:    %s\n", r.Session.Type)
+	fmt.Fprintf(&b, "Desktop:    %s\n", valueOr(r.Session.Desktop, "unknown"))
+	fmt.Fprintf(&b, "Compositor: %s\n", r.Session.Compositor)
+	fmt.Fprintf(&b, "Distro:     %s\n", r.Session.Distro)
+	fmt.Fprintf(&b, "Clipboard:  %s\n\n", r.Clipboard)
+
+	for _, tool := range r.Tools {
+		fmt.Fprintf(&b, "%-8s %-14s %s\n", toolState(tool), tool.Name, tool.Purpose)
+	}
+
+	if missing := r.missingTools(); len(missing) > 0 {
+		fmt.Fprintf(&b, "\nSome features won't work. To fix this, run:\n  %s\n", r.installHint(missing))
+	} else {
+		b.WriteString("\nEverything this session needs is installed.\n")
+	}
+	return b.String()
+}
+
+// toolState returns "ok", "missing" or "optional" for a tool
+func toolState(tool toolStatus) string {
+	switch {
+	case tool.Path != "":
+		return "ok"
+	case tool.Needed:
+		return "missing"
+	}
+	return "optional"
+}
+
+// valueOr returns value, or fallback if value is empty
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// warnMissingTools prints a warning for every tool this session needs but lacks
+func warnMissingTools(report diagnosticsReport) {
+	missing := report.missingTools()
+	for _, tool := range missing {
+		slog.Warn("tool not installed", "tool", tool.Name, "purpose", tool.Purpose)
+	}
+	if len(missing) > 0 {
+		slog.Warn("install the missing tools", "hint", report.installHint(missing))
+	}
+}
+
+// runDoctor prints the diagnostics report for "noteboard doctor". It exits with
+// 1 if a tool this session needs is missing.
+func runDoctor() int {
+	backend := newClipboardBackend(loadConfig().Clipboard, isWaylandSession())
+	report := runDiagnostics(backend.Name())
+	fmt.Print(report)
+
+	if len(report.missingTools()) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// createDiagnosticsSettings creates the button opening the status page
+func (cm *ClipboardManager) createDiagnosticsSettings(parent fyne.Window) fyne.CanvasObject {
+	return widget.NewButtonWithIcon("System status", theme.InfoIcon(), func() {
+		cm.showDiagnostics(parent)
+	})
+}
+
+// showDiagnostics shows the session and the status of each external tool with install hints
+func (cm *ClipboardManager) showDiagnostics(parent fyne.Window) {
+	report := runDiagnostics(cm.currentClipboard().Name())
+
+	session := widget.NewForm(
+		widget.NewFormItem("Session", widget.NewLabel(report.Session.Type)),
+		widget.NewFormItem("Desktop", widget.NewLabel(valueOr(report.Session.Desktop, "unknown"))),
+		widget.NewFormItem("Compositor", widget.NewLabel(report.Session.Compositor)),
+		widget.NewFormItem("Distro", widget.NewLabel(report.Session.Distro)),
+		widget.NewFormItem("Clipboard", widget.NewLabel(report.Clipboard)),
+	)
+
+	tools := container.NewVBox()
+	for _, tool := range report.Tools {
+		icon := theme.ConfirmIcon()
+		switch toolState(tool) {
+		case "missing":
+			icon = theme.ErrorIcon()
+		case "optional":
+			icon = theme.QuestionIcon()
+		}
+
+		detail := tool.Path
+		if detail == "" {
+			detail = report.installHint([]toolStatus{tool})
+		}
+		label := widget.NewLabel(fmt.Sprintf("%s: %s\n%s", tool.Name, tool.Purpose, detail))
+		label.Wrapping = fyne.TextWrapWord
+		tools.Add(container.NewBorder(nil, nil, widget.NewIcon(icon), nil, label))
+	}
+
+	content := container.NewVBox(session, widget.NewSeparator(), tools)
+	if missing := report.missingTools(); len(missing) > 0 {
+		hint := report.installHint(missing)
+		content.Add(widget.NewLabel("Some features won't work. To fix this, run:"))
+		content.Add(container.NewBorder(nil, nil, nil,
+			widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
+				parent.Clipboard().SetContent(hint)
+			}),
+			widget.NewLabelWithStyle(hint, fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})))
+	}
+
+	scroll := container.NewVScroll(content)
+	scroll.SetMinSize(fyne.NewSize(450, 400))
+	dialog.ShowCustom("System status", "Close", scroll, parent)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+
+	"NoteBoard/core"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// editItem saves edited content for the item with the given ID and returns the
+// item as saved. Unchanged content leaves the item as it is.
+func (cm *ClipboardManager) editItem(id, content string) (core.Item, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.touch()
+	index := cm.history.IndexOfID(id)
+	if index < 0 {
+		return core.Item{}, errors.New("the item was removed while editing")
+	}
+	if content == "" {
+		return core.Item{}, errors.New("the content can't be empty")
+	}
+
+	// Folding in a duplicate deletes it, so its hooks run while it is still there
+	if i := cm.history.IndexOfContent(content); i >= 0 && i != index {
+		cm.runHooks(hookDeleted, i)
+	}
+
+	if folded, ok := cm.history.SetContent(index, content, detectContentType(content)); ok {
+		if folded != "" {
+			cm.noteDeleted(folded)
+		}
+		// Folding in a duplicate may have moved the item
+		index = cm.history.IndexOfID(id)
+		cm.historyChanged()
+		cm.runHooks(hookEdited, index)
+	}
+	return cm.history.At(index), nil
+}
+
+// setCopyAfterEdit saves whether edited items are copied to the clipboard
+func (cm *ClipboardManager) setCopyAfterEdit(enabled bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.copyAfterEdit = enabled
+
+	config := loadConfig()
+	config.CopyAfterEdit = enabled
+	saveConfig(config)
+}
+
+// showEditDialog lets the user edit the content of the item with the given ID
+func (cm *ClipboardManager) showEditDialog(id string) {
+	item, ok := cm.itemByID(id)
+	if !ok {
+		return
+	}
+	cm.mu.Lock()
+	copyAfter := cm.copyAfterEdit
+	cm.mu.Unlock()
+
+	entry := widget.NewMultiLineEntry()
+	entry.Wrapping = fyne.TextWrapWord
+	entry.SetText(item.Content)
+	entry.SetMinRowsVisible(12)
+
+	copyCheck := widget.NewCheck("Copy after saving", nil)
+	copyCheck.SetChecked(copyAfter)
+
+	editor := dialog.NewCustomConfirm("Edit Item", "Save", "Cancel",
+		container.NewBorder(nil, copyCheck, nil, nil, entry),
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			if copyCheck.Checked != copyAfter {
+				cm.setCopyAfterEdit(copyCheck.Checked)
+			}
+
+			// The item is looked up by ID as the history may have changed while the dialog was open
+			saved, err := cm.editItem(item.ID, entry.Text)
+			if err != nil {
+				dialog.ShowError(err, cm.window)
+				return
+			}
+			if copyCheck.Checked {
+				cm.copyAndHide(saved)
+			}
+		}, cm.window)
+	editor.Resize(fyne.NewSize(cm.window.Canvas().Size().Width*0.9, editor.MinSize().Height))
+	editor.Show()
+	cm.window.Canvas().Focus(entry)
+}
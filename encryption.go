@@ -0,0 +1,593 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"NoteBoard/core"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	encryptedHistoryFileName = "clipboard_history.enc"
+
+	keySourcePassphrase    = "passphrase"
+	keySourceSecretService = "secret-service"
+
+	// pbkdf2Iterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256
+	pbkdf2Iterations = 600000
+	encryptionKeyLen = 32
+)
+
+var errWrongPassphrase = errors.New("wrong passphrase or corrupted history file")
+
+// EncryptionSettings configures encryption of the history at rest
+type EncryptionSettings struct {
+	Enabled         bool   `json:"enabled"`
+	KeySource       string `json:"keySource"`       // "passphrase" or "secret-service"
+	AutoLockMinutes int    `json:"autoLockMinutes"` // Lock after this many idle minutes, 0 disables
+}
+
+// encryptedHistoryFile is the on-disk envelope of the encrypted history
+type encryptedHistoryFile struct {
+	Version    int    `json:"version"`
+	KeySource  string `json:"keySource"`
+	Salt       []byte `json:"salt,omitempty"` // PBKDF2 salt, passphrase keys only
+	Iterations int    `json:"iterations,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"` // AES-256-GCM sealed history JSON
+}
+
+// getEncryptedHistoryPath returns the path of the encrypted history file
+func getEncryptedHistoryPath() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), encryptedHistoryFileName)
+}
+
+// deriveKey derives the history key from a passphrase
+func deriveKey(passphrase string, salt []byte, iterations int) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, iterations, encryptionKeyLen)
+}
+
+// sealData encrypts plaintext with AES-256-GCM under a fresh nonce
+func sealData(key, plaintext []byte) (nonce, ciphertext []byte, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	return nonce, gcm.Seal(nil, nonce, plaintext, nil), nil
+}
+
+// openData decrypts data sealed by sealData
+func openData(key, nonce, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errWrongPassphrase
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+// readEncryptedHistory reads the encrypted history envelope, returning nil if there is none
+func readEncryptedHistory() (*encryptedHistoryFile, error) {
+	data, err := os.ReadFile(getEncryptedHistoryPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file encryptedHistoryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("could not parse encrypted history: %w", err)
+	}
+	return &file, nil
+}
+
+// writeEncryptedHistory encrypts the encoded history with the unlocked key and writes it.
+// Callers hold cm.mu.
+func (cm *ClipboardManager) writeEncryptedHistory(data []byte) error {
+	if cm.historyKey == nil {
+		return errors.New("history is locked")
+	}
+
+	nonce, sealed, err := sealData(cm.historyKey, data)
+	if err != nil {
+		return err
+	}
+
+	file := encryptedHistoryFile{
+		Version:   1,
+		KeySource: cm.encryption.KeySource,
+		Nonce:     nonce,
+		Data:      sealed,
+	}
+	if cm.encryption.KeySource == keySourcePassphrase {
+		file.Salt = cm.historySalt
+		file.Iterations = pbkdf2Iterations
+	}
+
+	encoded, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash cannot leave a truncated history
+	path := getEncryptedHistoryPath()
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, encoded, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// decryptHistory decrypts the history in an encrypted history envelope, which may be nil
+func decryptHistory(file *encryptedHistoryFile, key []byte) ([]core.Item, error) {
+	if file == nil {
+		return nil, nil
+	}
+
+	data, err := openData(key, file.Nonce, file.Data)
+	if err != nil {
+		return nil, err
+	}
+	items, err := core.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse history: %w", err)
+	}
+	return items, nil
+}
+
+// encryptedStorage saves the history encrypted with the unlocked key. Callers hold cm.mu.
+type encryptedStorage struct {
+	cm *ClipboardManager
+}
+
+// Load decrypts the saved history
+func (s encryptedStorage) Load() ([]core.Item, error) {
+	if s.cm.historyKey == nil {
+		return nil, errors.New("history is locked")
+	}
+	file, err := readEncryptedHistory()
+	if err != nil {
+		return nil, err
+	}
+	return decryptHistory(file, s.cm.historyKey)
+}
+
+// Save encrypts and writes the history
+func (s encryptedStorage) Save(items []core.Item) error {
+	data, err := core.Encode(items)
+	if err != nil {
+		return err
+	}
+	return s.cm.writeEncryptedHistory(data)
+}
+
+// unlockHistory decrypts the stored history with the key from the configured
+// key source. Items copied while locked are kept on top of the restored history.
+func (cm *ClipboardManager) unlockHistory(passphrase string) error {
+	file, err := readEncryptedHistory()
+	if err != nil {
+		return err
+	}
+
+	cm.mu.Lock()
+	keySource := cm.encryption.KeySource
+	cm.mu.Unlock()
+
+	// Deriving the key takes a while, so the history stays usable meanwhile
+	var key, salt []byte
+	switch keySource {
+	case keySourceSecretService:
+		key, err = secretServiceKey(true)
+	default:
+		salt = make([]byte, 16)
+		iterations := pbkdf2Iterations
+		if file != nil {
+			salt, iterations = file.Salt, file.Iterations
+		} else if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		key, err = deriveKey(passphrase, salt, iterations)
+	}
+	if err != nil {
+		return err
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if !cm.locked {
+		return nil
+	}
+
+	items, err := decryptHistory(file, key)
+	if err != nil {
+		return err
+	}
+
+	// Keep what was copied while the history was locked
+	pending := cm.history.Items()
+	cm.history.Replace(items)
+
+	cm.historyKey, cm.historySalt = key, salt
+	cm.locked = false
+	cm.touch()
+
+	for i := len(pending) - 1; i >= 0; i-- {
+		cm.insertItem(pending[i].Content)
+	}
+	cm.historyChanged()
+	cm.openSyncKey()
+	cm.startSync()
+
+	return nil
+}
+
+// lockHistory forgets the key and the decrypted items until the next unlock
+func (cm *ClipboardManager) lockHistory() {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if !cm.encryption.Enabled || cm.locked {
+		return
+	}
+
+	cm.saveHistory()
+	cm.locked = true
+	cm.historyKey = nil
+	cm.syncKey = nil
+	cm.history.Replace(nil)
+	cm.updateFilter()
+	cm.refreshUI()
+}
+
+// isLocked reports whether the history is locked
+func (cm *ClipboardManager) isLocked() bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.locked
+}
+
+// touch records user activity for the auto-lock timer. Callers hold cm.mu.
+func (cm *ClipboardManager) touch() {
+	cm.lastActivity = time.Now()
+}
+
+// noteActivity records user activity for the auto-lock timer
+func (cm *ClipboardManager) noteActivity() {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.touch()
+}
+
+// watchIdle locks the history after the configured idle time
+func (cm *ClipboardManager) watchIdle() {
+	go func() {
+		for range time.Tick(30 * time.Second) {
+			cm.mu.Lock()
+			minutes := cm.encryption.AutoLockMinutes
+			idle := !cm.locked && minutes > 0 &&
+				time.Since(cm.lastActivity) > time.Duration(minutes)*time.Minute
+			cm.mu.Unlock()
+
+			if idle {
+				cm.lockHistory()
+			}
+		}
+	}()
+}
+
+// createLockedView creates the placeholder shown instead of the list while locked
+func (cm *ClipboardManager) createLockedView() fyne.CanvasObject {
+	unlockButton := widget.NewButtonWithIcon("Unlock", theme.VisibilityIcon(), cm.promptUnlock)
+
+	cm.lockedView = container.NewVBox(
+		layout.NewSpacer(),
+		widget.NewLabelWithStyle("History is locked", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		container.NewCenter(unlockButton),
+		layout.NewSpacer(),
+	)
+	cm.refreshUI()
+
+	return cm.lockedView
+}
+
+// updateLockedView shows the locked placeholder or the list depending on the lock
+// state. It runs on the main thread.
+func (cm *ClipboardManager) updateLockedView(locked bool) {
+	if cm.lockedView == nil || cm.list == nil {
+		return
+	}
+
+	if locked {
+		cm.lockedView.Show()
+		cm.list.Hide()
+	} else {
+		cm.lockedView.Hide()
+		cm.list.Show()
+	}
+}
+
+// promptUnlock asks for the passphrase, or queries the Secret Service, to unlock the history
+func (cm *ClipboardManager) promptUnlock() {
+	cm.mu.Lock()
+	if !cm.locked || cm.unlocking {
+		cm.mu.Unlock()
+		return
+	}
+	cm.unlocking = true
+	keySource := cm.encryption.KeySource
+	cm.mu.Unlock()
+
+	doneUnlocking := func() {
+		cm.mu.Lock()
+		cm.unlocking = false
+		cm.mu.Unlock()
+	}
+
+	if keySource == keySourceSecretService {
+		go func() {
+			defer doneUnlocking()
+			if err := cm.unlockHistory(""); err != nil {
+				cm.showError(fmt.Errorf("failed to unlock history: %w", err), cm.window)
+			}
+		}()
+		return
+	}
+
+	file, _ := readEncryptedHistory()
+	title, confirm := "Unlock History", "Unlock"
+	if file == nil {
+		// No history has been encrypted yet, this passphrase becomes the key
+		title, confirm = "Set History Passphrase", "Set"
+	}
+
+	passphrase := widget.NewPasswordEntry()
+	cm.runUI(func() {
+		dialog.ShowForm(title, confirm, "Cancel",
+			[]*widget.FormItem{widget.NewFormItem("Passphrase", passphrase)},
+			func(ok bool) {
+				doneUnlocking()
+				if !ok {
+					return
+				}
+				// Deriving the key is slow, keep it off the event loop
+				go func() {
+					if err := cm.unlockHistory(passphrase.Text); err != nil {
+						cm.showError(fmt.Errorf("failed to unlock history: %w", err), cm.window)
+					}
+				}()
+			}, cm.window)
+	})
+}
+
+// showWindow shows the main window, asking to unlock the history if needed.
+// It can be called from any goroutine.
+func (cm *ClipboardManager) showWindow() {
+	cm.runUI(func() {
+		cm.window.Show()
+		cm.window.RequestFocus()
+	})
+
+	cm.mu.Lock()
+	cm.touch()
+	locked := cm.locked
+	cm.mu.Unlock()
+
+	if locked {
+		cm.promptUnlock()
+	}
+}
+
+// setEncryption switches the history store between plain and encrypted. Enabling
+// with a passphrase asks for it; the current (unlocked) history is re-saved either way.
+func (cm *ClipboardManager) setEncryption(settings EncryptionSettings, passphrase string) error {
+	cm.mu.Lock()
+	locked := cm.locked
+	cm.mu.Unlock()
+	if locked {
+		return errors.New("unlock the history before changing encryption")
+	}
+
+	var key, salt []byte
+	if settings.Enabled {
+		var err error
+		if settings.KeySource == keySourceSecretService {
+			key, err = secretServiceKey(true)
+		} else {
+			if passphrase == "" {
+				return errors.New("a passphrase is required")
+			}
+			salt = make([]byte, 16)
+			if _, err := rand.Read(salt); err != nil {
+				return err
+			}
+			key, err = deriveKey(passphrase, salt, pbkdf2Iterations)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.historyKey, cm.historySalt = key, salt
+	cm.encryption = settings
+	config := loadConfig()
+	config.Encryption = settings
+	cm.resealSyncKey(&config)
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+
+	// Encrypted logs need the sync passphrase, plain ones are rewritten in plaintext
+	cm.stopSync()
+	cm.startSync()
+
+	// Re-save in the new format and drop the other one
+	cm.saveHistory()
+	if settings.Enabled {
+		os.Remove(getHistoryPath())
+	} else {
+		os.Remove(getEncryptedHistoryPath())
+	}
+
+	return nil
+}
+
+// createEncryptionSettings builds the encryption section of the settings window
+func (cm *ClipboardManager) createEncryptionSettings(settingsWindow fyne.Window) fyne.CanvasObject {
+	keySource := widget.NewRadioGroup([]string{"Passphrase", "Secret Service (KWallet / GNOME Keyring)"}, nil)
+	if cm.encryption.KeySource == keySourceSecretService {
+		keySource.SetSelected(keySource.Options[1])
+	} else {
+		keySource.SetSelected(keySource.Options[0])
+	}
+
+	autoLock := widget.NewSelect([]string{"Never", "5", "15", "30", "60"}, nil)
+	if cm.encryption.AutoLockMinutes > 0 {
+		autoLock.SetSelected(fmt.Sprint(cm.encryption.AutoLockMinutes))
+	} else {
+		autoLock.SetSelected("Never")
+	}
+
+	currentSettings := func(enabled bool) EncryptionSettings {
+		settings := EncryptionSettings{Enabled: enabled, KeySource: keySourcePassphrase}
+		if keySource.Selected == keySource.Options[1] {
+			settings.KeySource = keySourceSecretService
+		}
+		fmt.Sscan(autoLock.Selected, &settings.AutoLockMinutes)
+		return settings
+	}
+
+	// The key source can only be changed while encryption is off
+	updateKeySource := func() {
+		if cm.encryption.Enabled {
+			keySource.Disable()
+		} else {
+			keySource.Enable()
+		}
+	}
+	updateKeySource()
+
+	enableCheck := widget.NewCheck("Encrypt history at rest", nil)
+	enableCheck.SetChecked(cm.encryption.Enabled)
+	status := widget.NewLabel("")
+	status.Hide()
+
+	// Deriving the key takes a moment and the Secret Service may prompt, so the
+	// switch runs in the background with the check disabled until it is done
+	apply := func(settings EncryptionSettings, passphrase string) {
+		enableCheck.Disable()
+		keySource.Disable()
+		if settings.Enabled {
+			status.SetText("Encrypting the history…")
+		} else {
+			status.SetText("Decrypting the history…")
+		}
+		status.Show()
+
+		go func() {
+			err := cm.setEncryption(settings, passphrase)
+			cm.runUI(func() {
+				status.Hide()
+				enableCheck.Enable()
+				if err != nil {
+					dialog.ShowError(err, settingsWindow)
+				}
+				enableCheck.SetChecked(cm.encryption.Enabled)
+				updateKeySource()
+			})
+		}()
+	}
+
+	enableCheck.OnChanged = func(checked bool) {
+		if checked == cm.encryption.Enabled {
+			return
+		}
+
+		settings := currentSettings(checked)
+		if !checked || settings.KeySource == keySourceSecretService {
+			apply(settings, "")
+			return
+		}
+
+		passphrase := widget.NewPasswordEntry()
+		confirmation := widget.NewPasswordEntry()
+		dialog.ShowForm("Set History Passphrase", "Encrypt", "Cancel",
+			[]*widget.FormItem{
+				widget.NewFormItem("Passphrase", passphrase),
+				widget.NewFormItem("Confirm", confirmation),
+			},
+			func(ok bool) {
+				if ok && passphrase.Text != confirmation.Text {
+					dialog.ShowError(errors.New("passphrases do not match"), settingsWindow)
+					ok = false
+				}
+				if !ok {
+					enableCheck.SetChecked(cm.encryption.Enabled)
+					return
+				}
+				apply(settings, passphrase.Text)
+			}, settingsWindow)
+	}
+
+	autoLock.OnChanged = func(string) {
+		cm.mu.Lock()
+		defer cm.mu.Unlock()
+
+		cm.encryption.AutoLockMinutes = currentSettings(cm.encryption.Enabled).AutoLockMinutes
+		config := loadConfig()
+		config.Encryption = cm.encryption
+		saveConfig(config)
+	}
+
+	lockButton := widget.NewButton("Lock now", cm.lockHistory)
+
+	return container.NewVBox(
+		widget.NewLabel("History Encryption"),
+		enableCheck,
+		status,
+		keySource,
+		container.NewHBox(widget.NewLabel("Auto-lock after (minutes):"), autoLock),
+		lockButton,
+	)
+}
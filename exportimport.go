@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"NoteBoard/core"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// exportFile is the JSON export format of the history, including pin state and tags
+type exportFile struct {
+	Version  int         `json:"version"`
+	Exported time.Time   `json:"exported"`
+	Items    []core.Item `json:"items"`
+}
+
+// exportJSON exports the history as JSON
+func (cm *ClipboardManager) exportJSON() ([]byte, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	return json.MarshalIndent(exportFile{
+		Version:  1,
+		Exported: time.Now(),
+		Items:    cm.history.Items(),
+	}, "", "  ")
+}
+
+// exportMarkdown exports the history as Markdown, pinned items first
+func (cm *ClipboardManager) exportMarkdown() string {
+	cm.mu.Lock()
+	stored := cm.history.Items()
+	cm.mu.Unlock()
+
+	var pinned, others []core.Item
+	for _, item := range stored {
+		if item.Pinned {
+			pinned = append(pinned, item)
+		} else {
+			others = append(others, item)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s export\n\nExported %s\n", appName, time.Now().Format("2006-01-02 15:04"))
+
+	writeSection := func(title string, items []core.Item) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n## %s\n", title)
+		for _, item := range items {
+			fmt.Fprintf(&b, "\n### %s", item.Timestamp.Format("2006-01-02 15:04:05"))
+			for _, tag := range item.Tags {
+				fmt.Fprintf(&b, " #%s", tag)
+			}
+			b.WriteString("\n\n")
+
+			// Use a fence longer than any backtick run in the content
+			fence := "```"
+			for strings.Contains(item.Content, fence) {
+				fence += "`"
+			}
+			fmt.Fprintf(&b, "%s\n%s\n%s\n", fence, item.Content, fence)
+		}
+	}
+
+	writeSection("Pinned", pinned)
+	writeSection("History", others)
+
+	return b.String()
+}
+
+// parseImport reads a JSON export. The history file format (a bare item array) is accepted too.
+func parseImport(data []byte) ([]core.Item, error) {
+	var file exportFile
+	if err := json.Unmarshal(data, &file); err == nil {
+		return file.Items, nil
+	}
+
+	var items []core.Item
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("not a %s export: %w", appName, err)
+	}
+	return items, nil
+}
+
+// importItems merges items into the history, deduplicating by content. Duplicates
+// keep the newer timestamp and combine pins and tags. Imports don't evict local
+// items, so new unpinned items only fill the room left under the history limit;
+// pin keeps every imported item by pinning them. It returns the number of new
+// items kept and the number left out.
+func (cm *ClipboardManager) importItems(imported []core.Item, pin bool) (added, dropped int) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	for i := range imported {
+		if imported[i].Type == "" {
+			imported[i].Type = detectContentType(imported[i].Content)
+		}
+		imported[i].Pinned = imported[i].Pinned || pin
+	}
+
+	added, dropped = cm.history.Merge(imported)
+	cm.historyChanged()
+
+	return added, dropped
+}
+
+// handleExportCommand answers "noteboard export [--markdown]" with the exported data
+func (cm *ClipboardManager) handleExportCommand(args []string) socketResponse {
+	if containsKey(args, "--markdown") {
+		return socketResponse{Output: cm.exportMarkdown()}
+	}
+
+	data, err := cm.exportJSON()
+	if err != nil {
+		return socketResponse{Error: err.Error()}
+	}
+	return socketResponse{Output: string(data)}
+}
+
+// handleImportCommand merges the history file sent by "noteboard import [--from <format>] [--pin] <file>"
+func (cm *ClipboardManager) handleImportCommand(format string, pin bool, data []byte) socketResponse {
+	if len(data) == 0 {
+		return socketResponse{Error: "nothing to import"}
+	}
+
+	items, err := parseImportFrom(format, data)
+	if err != nil {
+		return socketResponse{Error: err.Error()}
+	}
+
+	added, dropped := cm.importItems(items, pin)
+	output := fmt.Sprintf("Imported %d new of %d items", added, len(items))
+	if dropped > 0 {
+		output += fmt.Sprintf("; %d older items didn't fit the history limit of %d. Import them pinned to keep them all.",
+			dropped, maxClipboardItems)
+	}
+	return socketResponse{Output: output}
+}
+
+// createExportImportSettings builds the export/import section of the settings window
+func (cm *ClipboardManager) createExportImportSettings(settingsWindow fyne.Window) fyne.CanvasObject {
+	export := func(extension string, encode func() ([]byte, error)) {
+		if cm.isLocked() {
+			dialog.ShowError(errors.New("unlock the history before exporting"), settingsWindow)
+			return
+		}
+
+		save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil || writer == nil {
+				return
+			}
+			defer writer.Close()
+
+			data, err := encode()
+			if err == nil {
+				_, err = writer.Write(data)
+			}
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("export failed: %w", err), settingsWindow)
+			}
+		}, settingsWindow)
+		save.SetFileName("noteboard-export" + extension)
+		save.SetFilter(storage.NewExtensionFileFilter([]string{extension}))
+		save.Show()
+	}
+
+	exportJSONButton := widget.NewButton("Export JSON…", func() {
+		export(".json", cm.exportJSON)
+	})
+	exportMarkdownButton := widget.NewButton("Export Markdown…", func() {
+		export(".md", func() ([]byte, error) {
+			return []byte(cm.exportMarkdown()), nil
+		})
+	})
+
+	var formatOptions []string
+	formatByLabel := make(map[string]string)
+	for _, format := range importFormats {
+		formatOptions = append(formatOptions, importFormatLabels[format])
+		formatByLabel[importFormatLabels[format]] = format
+	}
+	formatSelect := widget.NewSelect(formatOptions, nil)
+	formatSelect.SetSelectedIndex(0)
+
+	// Imported items beyond the history limit are only kept when pinned
+	pinCheck := widget.NewCheck("Pin imported items", nil)
+
+	importButton := widget.NewButton("Import…", func() {
+		if cm.isLocked() {
+			dialog.ShowError(errors.New("unlock the history before importing"), settingsWindow)
+			return
+		}
+		format := formatByLabel[formatSelect.Selected]
+
+		open := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil || reader == nil {
+				return
+			}
+			defer reader.Close()
+
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				dialog.ShowError(err, settingsWindow)
+				return
+			}
+
+			resp := cm.handleImportCommand(format, pinCheck.Checked, data)
+			if resp.Error != "" {
+				dialog.ShowError(errors.New(resp.Error), settingsWindow)
+				return
+			}
+			dialog.ShowInformation("Import", resp.Output, settingsWindow)
+		}, settingsWindow)
+
+		// Start where the other clipboard manager keeps its history
+		if path := defaultImportPath(format); path != "" {
+			if location, err := storage.ListerForURI(storage.NewFileURI(filepath.Dir(path))); err == nil {
+				open.SetLocation(location)
+			}
+		}
+		if format == importNoteBoard {
+			open.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+		}
+		open.Show()
+	})
+
+	return container.NewVBox(
+		widget.NewLabel("Export / Import"),
+		container.NewHBox(exportJSONButton, exportMarkdownButton),
+		container.NewBorder(nil, nil, nil, importButton, formatSelect),
+		pinCheck,
+	)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"NoteBoard/core"
+)
+
+const (
+	typeFiles = "files"
+
+	uriListTarget    = "text/uri-list"
+	gnomeFilesTarget = "x-special/gnome-copied-files"
+)
+
+// fileStatTTL is how long looked up files are reused before checking them again,
+// so files moved or deleted meanwhile are flagged soon after
+const fileStatTTL = 5 * time.Second
+
+// clipboardFile describes one file of a file list item
+type clipboardFile struct {
+	path    string
+	name    string
+	size    int64
+	isDir   bool
+	missing bool
+}
+
+// isURIList reports whether every non-comment line of content is a file:// URI
+func isURIList(content string) bool {
+	found := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "file://") {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// parseURIList returns the local paths of a text/uri-list (RFC 2483)
+func parseURIList(content string) []string {
+	var paths []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		u, err := url.Parse(line)
+		if err != nil || u.Scheme != "file" {
+			continue
+		}
+		paths = append(paths, u.Path)
+	}
+	return paths
+}
+
+// formatURIList encodes paths as file:// URIs, one per line. The result is
+// used both as the item's content and, with CRLF line ends, as the clipboard data.
+func formatURIList(paths []string, lineEnd string) string {
+	var lines []string
+	for _, path := range paths {
+		u := url.URL{Scheme: "file", Path: path}
+		lines = append(lines, u.String())
+	}
+	return strings.Join(lines, lineEnd) + lineEnd
+}
+
+// formatGnomeCopiedFiles encodes paths in the format Nautilus and other GTK file managers paste
+func formatGnomeCopiedFiles(paths []string) string {
+	return "copy\n" + strings.TrimSuffix(formatURIList(paths, "\n"), "\n")
+}
+
+// statFiles looks up the files of a file list item, flagging the ones that no longer exist
+func statFiles(content string) []clipboardFile {
+	var files []clipboardFile
+	for _, path := range parseURIList(content) {
+		file := clipboardFile{path: path, name: filepath.Base(path)}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			file.missing = true
+		} else {
+			file.size = info.Size()
+			file.isDir = info.IsDir()
+		}
+
+		files = append(files, file)
+	}
+	return files
+}
+
+// fileStatCache keeps the looked up files of file list items by item ID, so
+// binding rows doesn't stat every file each time the list scrolls or refreshes.
+// The zero value is an empty cache.
+type fileStatCache struct {
+	mu      sync.Mutex
+	entries map[string]fileStatEntry
+}
+
+type fileStatEntry struct {
+	content string
+	files   []clipboardFile
+	checked time.Time
+}
+
+// get returns the files of a file list item, looking them up again once the
+// entry is older than fileStatTTL or the item's content changed
+func (c *fileStatCache) get(item core.Item) []clipboardFile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := c.entries[item.ID]; ok && entry.content == item.Content && now.Sub(entry.checked) < fileStatTTL {
+		return entry.files
+	}
+
+	if c.entries == nil {
+		c.entries = make(map[string]fileStatEntry)
+	}
+	// Drop stale entries, which include those of removed items
+	for id, entry := range c.entries {
+		if now.Sub(entry.checked) >= fileStatTTL {
+			delete(c.entries, id)
+		}
+	}
+
+	files := statFiles(item.Content)
+	c.entries[item.ID] = fileStatEntry{content: item.Content, files: files, checked: now}
+	return files
+}
+
+// describeFile returns "name (size)", "name/" for folders or "name (missing)"
+func describeFile(file clipboardFile) string {
+	switch {
+	case file.missing:
+		return file.name + " (missing)"
+	case file.isDir:
+		return file.name + "/"
+	}
+	return fmt.Sprintf("%s (%s)", file.name, formatSize(file.size))
+}
+
+// formatSize formats a byte count for display
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// fileListSummary returns the row text of a file list item
+func fileListSummary(files []clipboardFile) string {
+	var names []string
+	for _, file := range files {
+		names = append(names, describeFile(file))
+	}
+
+	if len(names) > 3 {
+		return fmt.Sprintf("%s\n… and %d more", strings.Join(names[:3], ", "), len(names)-3)
+	}
+	return strings.Join(names, ", ")
+}
+
+// hasMissingFiles reports whether any file of the list no longer exists
+func hasMissingFiles(files []clipboardFile) bool {
+	for _, file := range files {
+		if file.missing {
+			return true
+		}
+	}
+	return false
+}
+
+// readClipboardFiles returns the clipboard's file list as a uri-list, if it holds one.
+// Dolphin and most file managers offer text/uri-list, GTK ones may only offer
+// x-special/gnome-copied-files.
+func readClipboardFiles(backend core.ClipboardBackend) (string, bool) {
+	targets, err := backend.Targets()
+	if err != nil {
+		return "", false
+	}
+
+	var data []byte
+	switch {
+	case containsKey(targets, uriListTarget):
+		data, err = backend.Read(uriListTarget)
+	case containsKey(targets, gnomeFilesTarget):
+		data, err = backend.Read(gnomeFilesTarget)
+		// Drop the leading "copy" or "cut" line
+		if _, rest, found := bytes.Cut(data, []byte("\n")); found {
+			data = rest
+		}
+	default:
+		return "", false
+	}
+
+	if err != nil || !isURIList(string(data)) {
+		return "", false
+	}
+
+	// Normalise so the same files always produce the same content
+	return strings.TrimSuffix(formatURIList(parseURIList(string(data)), "\n"), "\n"), true
+}
+
+// writeClipboardFiles offers paths on the clipboard so file managers paste the files.
+// Backends that can serve several targets offer both text/uri-list and GNOME's
+// format, plus the paths as text. The command line tools serve a single target, so
+// with them GNOME-based desktops get their own format and everything else gets
+// text/uri-list.
+func writeClipboardFiles(backend core.ClipboardBackend, paths []string) error {
+	targets := map[string][]byte{
+		uriListTarget:    []byte(formatURIList(paths, "\r\n")),
+		gnomeFilesTarget: []byte(formatGnomeCopiedFiles(paths)),
+		core.TextTarget:  []byte(strings.Join(paths, "\n")),
+	}
+
+	var err error
+	if writer, ok := backend.(core.TargetsWriter); ok {
+		err = writer.WriteTargets(targets)
+	} else {
+		target := uriListTarget
+		if strings.Contains(strings.ToLower(os.Getenv("XDG_CURRENT_DESKTOP")), "gnome") {
+			target = gnomeFilesTarget
+		}
+		err = backend.Write(target, targets[target])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy files: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// codeKeywords are highlighted in code previews. The set covers the common
+// keywords of the languages people usually copy around (Go, JS, Python, shell).
+var codeKeywords = map[string]bool{
+	"break": true, "case": true, "class": true, "const": true, "continue": true,
+	"def": true, "default": true, "defer": true, "do": true, "done": true,
+	"elif": true, "else": true, "esac": true, "export": true, "false": true,
+	"fi": true, "fn": true, "for": true, "from": true, "func": true,
+	"function": true, "go": true, "if": true, "import": true, "in": true,
+	"interface": true, "let": true, "map": true, "new": true, "nil": true,
+	"None": true, "null": true, "package": true, "pub": true, "return": true,
+	"self": true, "struct": true, "switch": true, "then": true, "this": true,
+	"true": true, "True": true, "False": true, "type": true, "var": true,
+	"while": true, "with": true, "yield": true,
+}
+
+// highlightedPreview returns monospace, syntax highlighted segments for JSON and code.
+// JSON is pretty-printed first; anything else is returned as plain monospace text.
+func highlightedPreview(content, itemType string) []widget.RichTextSegment {
+	switch itemType {
+	case typeJSON:
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, []byte(strings.TrimSpace(content)), "", "  "); err == nil {
+			content = pretty.String()
+		}
+		return highlightSource(content, false)
+	case typeCode:
+		return highlightSource(content, true)
+	}
+
+	return []widget.RichTextSegment{codeSegment(content, theme.ColorNameForeground)}
+}
+
+// highlightSource splits source text into coloured segments. Strings, numbers and
+// keywords are recognised in both modes; comments only in code mode.
+func highlightSource(src string, code bool) []widget.RichTextSegment {
+	var segments []widget.RichTextSegment
+	runes := []rune(src)
+
+	emit := func(text string, colorName fyne.ThemeColorName) {
+		if text != "" {
+			segments = append(segments, codeSegment(text, colorName))
+		}
+	}
+
+	plainStart := 0
+	flushPlain := func(end int) {
+		emit(string(runes[plainStart:end]), theme.ColorNameForeground)
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case code && (r == '#' || (r == '/' && i+1 < len(runes) && runes[i+1] == '/')):
+			// Line comment
+			end := i
+			for end < len(runes) && runes[end] != '\n' {
+				end++
+			}
+			flushPlain(i)
+			emit(string(runes[i:end]), theme.ColorNamePlaceHolder)
+			i, plainStart = end, end
+
+		case r == '"' || (code && (r == '\'' || r == '`')):
+			// String literal, honouring backslash escapes
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				if runes[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(runes) {
+				end++
+			}
+			if end > len(runes) {
+				end = len(runes)
+			}
+
+			colorName := theme.ColorNameSuccess
+			if !code && isJSONKey(runes, end) {
+				colorName = theme.ColorNamePrimary
+			}
+
+			flushPlain(i)
+			emit(string(runes[i:end]), colorName)
+			i, plainStart = end, end
+
+		case unicode.IsDigit(r) || (r == '-' && !code && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			end := i + 1
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || strings.ContainsRune(".eE+-xabcdefABCDEF_", runes[end])) {
+				end++
+			}
+			flushPlain(i)
+			emit(string(runes[i:end]), theme.ColorNameWarning)
+			i, plainStart = end, end
+
+		case isIdentRune(r):
+			end := i + 1
+			for end < len(runes) && isIdentRune(runes[end]) {
+				end++
+			}
+			word := string(runes[i:end])
+			if codeKeywords[word] && (code || word == "true" || word == "false" || word == "null") {
+				flushPlain(i)
+				emit(word, theme.ColorNamePrimary)
+				plainStart = end
+			}
+			i = end
+
+		default:
+			i++
+		}
+	}
+	flushPlain(len(runes))
+
+	return segments
+}
+
+// isJSONKey reports whether the string literal ending at end is followed by a colon
+func isJSONKey(runes []rune, end int) bool {
+	for end < len(runes) && unicode.IsSpace(runes[end]) {
+		end++
+	}
+	return end < len(runes) && runes[end] == ':'
+}
+
+// isIdentRune reports whether r can be part of an identifier
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// codeSegment creates an inline monospace text segment in the given colour
+func codeSegment(text string, colorName fyne.ThemeColorName) *widget.TextSegment {
+	return &widget.TextSegment{
+		Text: text,
+		Style: widget.RichTextStyle{
+			Inline:    true,
+			ColorName: colorName,
+			TextStyle: fyne.TextStyle{Monospace: true},
+		},
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"NoteBoard/core"
+)
+
+const historyFileName = "clipboard_history.json"
+
+// getHistoryPath returns the path of the history file, next to the config file
+func getHistoryPath() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), historyFileName)
+}
+
+// loadHistory restores the saved clipboard history, if any. An encrypted
+// history stays on disk until the user unlocks it. Callers hold cm.mu.
+func (cm *ClipboardManager) loadHistory() {
+	if cm.encryption.Enabled {
+		cm.locked = true
+		return
+	}
+
+	items, err := cm.historyStorage().Load()
+	if err != nil {
+		slog.Warn("could not read history file", "err", err)
+		return
+	}
+	cm.history.Replace(items)
+}
+
+// historyStorage returns where the history is saved: the history file, or the
+// encrypted history file while encryption is on. Callers hold cm.mu.
+func (cm *ClipboardManager) historyStorage() core.Storage {
+	if cm.encryption.Enabled {
+		return encryptedStorage{cm}
+	}
+	return core.FileStorage{Path: getHistoryPath()}
+}
+
+// saveHistory writes the clipboard history to disk if saving history is enabled.
+// Callers hold cm.mu.
+func (cm *ClipboardManager) saveHistory() {
+	// While locked the history only holds the items copied since locking
+	if !cm.saveHistoryEnabled || cm.locked {
+		return
+	}
+
+	if err := cm.historyStorage().Save(cm.history.Items()); err != nil {
+		slog.Error("could not save history", "err", err)
+	}
+}
+
+// setSaveHistory enables or disables saving history; disabling removes the saved file
+func (cm *ClipboardManager) setSaveHistory(enabled bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.saveHistoryEnabled = enabled
+
+	config := loadConfig()
+	config.SaveHistory = enabled
+	saveConfig(config)
+
+	if enabled {
+		cm.saveHistory()
+	} else {
+		os.Remove(getHistoryPath())
+		os.Remove(getEncryptedHistoryPath())
+	}
+}
+
+// historyChanged updates the view and persists the history after a modification.
+// Callers hold cm.mu.
+func (cm *ClipboardManager) historyChanged() {
+	cm.updateFilter()
+	cm.refreshUI()
+	cm.saveHistory()
+	cm.syncChanges()
+}
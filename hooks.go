@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// Events hook scripts can be run on
+const (
+	hookAdded    = "added"
+	hookEdited   = "edited"
+	hookCopied   = "copied"
+	hookPinned   = "pinned"
+	hookUnpinned = "unpinned"
+	hookDeleted  = "deleted"
+	hookCleared  = "cleared"
+
+	// hookAll matches every event
+	hookAll = "*"
+)
+
+// HookScript is a user-defined command run when something happens to the history.
+// It receives the item as JSON on stdin (a list of the removed items for "cleared")
+// and the event in NOTEBOARD_EVENT.
+type HookScript struct {
+	Event   string `json:"event"`   // added, edited, copied, pinned, unpinned, deleted, cleared or "*"
+	Command string `json:"command"` // Shell command, run with "sh -c"
+	Timeout int    `json:"timeout"` // Timeout in seconds (0 uses the default)
+}
+
+// runHooks runs the hooks for an event on the item at index. Hooks run in the
+// background, so a slow hook never holds up the history. Callers hold cm.mu.
+func (cm *ClipboardManager) runHooks(event string, index int) {
+	if index < 0 || index >= cm.history.Len() || !cm.hasHooks(event) {
+		return
+	}
+
+	item := apiItem{Index: index, Item: cm.history.At(index)}
+	input, err := json.Marshal(item)
+	if err != nil {
+		return
+	}
+
+	cm.startHooks(event, string(input),
+		"NOTEBOARD_ITEM_ID="+item.ID,
+		"NOTEBOARD_ITEM_TYPE="+item.Type,
+		"NOTEBOARD_ITEM_INDEX="+strconv.Itoa(index),
+	)
+}
+
+// runClearedHooks runs the hooks for clearing the history on the items about to be
+// removed. Callers hold cm.mu.
+func (cm *ClipboardManager) runClearedHooks() {
+	if !cm.hasHooks(hookCleared) {
+		return
+	}
+
+	removed := []apiItem{}
+	for i, item := range cm.history.Items() {
+		if !item.Pinned {
+			removed = append(removed, apiItem{Index: i, Item: item})
+		}
+	}
+	input, err := json.Marshal(removed)
+	if err != nil {
+		return
+	}
+
+	cm.startHooks(hookCleared, string(input), "NOTEBOARD_ITEM_COUNT="+strconv.Itoa(len(removed)))
+}
+
+// hasHooks reports whether any hook is configured for the event
+func (cm *ClipboardManager) hasHooks(event string) bool {
+	for _, hook := range cm.hooks {
+		if hook.Event == event || hook.Event == hookAll {
+			return true
+		}
+	}
+	return false
+}
+
+// startHooks starts the hooks for the event with input on stdin
+func (cm *ClipboardManager) startHooks(event, input string, env ...string) {
+	env = append(env, "NOTEBOARD_EVENT="+event)
+
+	for _, hook := range cm.hooks {
+		if hook.Event != event && hook.Event != hookAll {
+			continue
+		}
+
+		go func() {
+			name := event + " hook"
+			_, err := runShellCommand(name, hook.Command, time.Duration(hook.Timeout)*time.Second, input, env...)
+			if err != nil {
+				slog.Warn("hook failed", "err", err)
+			}
+		}()
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"image/color"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -296,14 +295,9 @@ func createWaylandHotkeySettings(settingsWindow fyne.Window, cm *ClipboardManage
 	// Use the settingsWindow param for the dialog parent
 	openSettingsButton := widget.NewButton("Open KDE Shortcuts Settings", func() {
 		// Try to open KDE System Settings at the shortcuts page
-		cmd := "kcmshell5 keys || systemsettings5 keys || systemsettings5 shortcuts"
-		go func() {
-			err := exec.Command("sh", "-c", cmd).Start()
-			if err != nil {
-				// Display error dialog using the settingsWindow param
-				dialog.ShowError(fmt.Errorf("failed to open kde settings: %v", err), settingsWindow)
-			}
-		}()
+		if err := getKDETools().openShortcutSettings(); err != nil {
+			dialog.ShowError(fmt.Errorf("failed to open kde settings: %v", err), settingsWindow)
+		}
 	})
 
 	// Use the cm param to get executable path
@@ -450,8 +444,9 @@ func ShowSettingsDialog(a fyne.App, cm *ClipboardManager) {
 	hotkeyDetector := CreateHotkeyDetector(settingsWindow, cm)
 
 	// Other settings
-	historyToggle := widget.NewCheck("Save history", func(bool) {})
-	historyToggle.SetChecked(true)
+	historyToggle := widget.NewCheck("Save history (unencrypted unless history encryption is on)", nil)
+	historyToggle.SetChecked(cm.saveHistoryEnabled)
+	historyToggle.OnChanged = cm.setSaveHistory
 	clearHistoryButton := widget.NewButton("Clear clipboard history", cm.clearItems)
 
 	// Add autostart option
@@ -488,13 +483,13 @@ func ShowSettingsDialog(a fyne.App, cm *ClipboardManager) {
 			go func() {
 				err := cm.setKDEWindowKeepAbove(checked)
 				if err != nil {
-					dialog.ShowError(fmt.Errorf("failed to change keep above setting: %v", err), settingsWindow)
+					cm.showError(fmt.Errorf("failed to change keep above setting: %v", err), settingsWindow)
 				} else {
 					// Apply the change to current window as well
 					if checked {
-						dialog.ShowInformation("Success", "Window will now stay above others. Changes will apply after restart.", settingsWindow)
+						cm.showInformation("Success", "Window will now stay above others. Changes will apply after restart.", settingsWindow)
 					} else {
-						dialog.ShowInformation("Success", "Window will no longer stay above others. Changes will apply after restart.", settingsWindow)
+						cm.showInformation("Success", "Window will no longer stay above others. Changes will apply after restart.", settingsWindow)
 					}
 				}
 			}()
@@ -523,9 +518,11 @@ func ShowSettingsDialog(a fyne.App, cm *ClipboardManager) {
 			wlCopyCheck,
 			widget.NewLabel("Make sure wl-clipboard is installed:"),
 			widget.NewButton("Install wl-clipboard", func() {
-				// Show dialog with install command
+				// Show dialog with the install command of this distribution
+				report := runDiagnostics(cm.currentClipboard().Name())
 				dialog.ShowInformation("Install wl-clipboard",
-					"Run this command in terminal to install wl-clipboard:\n\nsudo pacman -S wl-clipboard",
+					"Run this command in terminal to install wl-clipboard:\n\n"+
+						report.installHint(report.toolsNamed("wl-paste", "wl-copy")),
 					settingsWindow)
 			}),
 		)
@@ -544,6 +541,22 @@ func ShowSettingsDialog(a fyne.App, cm *ClipboardManager) {
 		autostartToggle,
 		historyToggle,
 		clearHistoryButton,
+		cm.createClipboardSettings(),
+		container.NewHBox(cm.createDiagnosticsSettings(settingsWindow), cm.createLogSettings()),
+		widget.NewSeparator(),
+		cm.createEncryptionSettings(settingsWindow),
+		widget.NewSeparator(),
+		cm.createExportImportSettings(settingsWindow),
+		widget.NewSeparator(),
+		cm.createSyncSettings(settingsWindow),
+		widget.NewSeparator(),
+		cm.createLANSettings(settingsWindow),
+		widget.NewSeparator(),
+		cm.createAPISettings(settingsWindow),
+		widget.NewSeparator(),
+		cm.createOverlaySettings(),
+		widget.NewSeparator(),
+		cm.createPlacementSettings(),
 		widget.NewSeparator(),
 		hotkeyContainer,
 	)
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"NoteBoard/core"
+)
+
+// Import formats accepted by "noteboard import --from <format>"
+const (
+	importNoteBoard = "noteboard"
+	importKlipper   = "klipper"
+	importCopyQ     = "copyq"
+	importGPaste    = "gpaste"
+)
+
+var importFormats = []string{importNoteBoard, importKlipper, importCopyQ, importGPaste}
+
+// importFormatLabels are the names shown for the import formats in the settings
+var importFormatLabels = map[string]string{
+	importNoteBoard: "NoteBoard JSON",
+	importKlipper:   "Klipper (history2.lst)",
+	importCopyQ:     "CopyQ (exported tabs)",
+	importGPaste:    "GPaste (history.xml)",
+}
+
+// defaultImportPath returns where the other clipboard manager keeps its history, if known
+func defaultImportPath(format string) string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dataHome = filepath.Join(homeDir, ".local", "share")
+	}
+
+	switch format {
+	case importKlipper:
+		return filepath.Join(dataHome, "klipper", "history2.lst")
+	case importGPaste:
+		return filepath.Join(dataHome, "gpaste", "history.xml")
+	}
+	return ""
+}
+
+// parseImportFrom converts a history file of the given format into stored items
+func parseImportFrom(format string, data []byte) ([]core.Item, error) {
+	var items []core.Item
+	var err error
+
+	switch format {
+	case "", importNoteBoard:
+		return parseImport(data)
+	case importKlipper:
+		items, err = parseKlipperHistory(data)
+	case importCopyQ:
+		items, err = parseCopyQTabs(data)
+	case importGPaste:
+		items, err = parseGPasteHistory(data)
+	default:
+		return nil, fmt.Errorf("unknown import format %q (expected one of %s)", format, strings.Join(importFormats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the original order for items that carry no timestamp, newest first
+	now := time.Now()
+	for i := range items {
+		if items[i].Timestamp.IsZero() {
+			items[i].Timestamp = now.Add(-time.Duration(i) * time.Second)
+		}
+	}
+
+	return items, nil
+}
+
+// qDataStream reads values serialized with Qt's QDataStream (big endian)
+type qDataStream struct {
+	data []byte
+	err  error
+}
+
+var errShortStream = errors.New("unexpected end of data")
+
+// qNullLength marks a null QString or QByteArray
+const qNullLength = math.MaxUint32
+
+func (s *qDataStream) next(n int) []byte {
+	if s.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(s.data) {
+		s.err = errShortStream
+		return nil
+	}
+	b := s.data[:n]
+	s.data = s.data[n:]
+	return b
+}
+
+func (s *qDataStream) done() bool {
+	return s.err != nil || len(s.data) == 0
+}
+
+func (s *qDataStream) uint32() uint32 {
+	b := s.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (s *qDataStream) int32() int32 {
+	return int32(s.uint32())
+}
+
+func (s *qDataStream) bool() bool {
+	b := s.next(1)
+	return b != nil && b[0] != 0
+}
+
+// byteArray reads a QByteArray
+func (s *qDataStream) byteArray() []byte {
+	n := s.uint32()
+	if s.err != nil || n == qNullLength {
+		return nil
+	}
+	return s.next(int(n))
+}
+
+// string reads a QString (UTF-16)
+func (s *qDataStream) string() string {
+	return decodeUTF16(s.byteArray())
+}
+
+// text reads a QString or a UTF-8 QByteArray. Some formats changed between the two
+// over time; a QString of ASCII text is recognizable by its zero high bytes.
+func (s *qDataStream) text() string {
+	b := s.byteArray()
+	if len(b) >= 2 && len(b)%2 == 0 {
+		utf16 := true
+		for i := 0; i < len(b); i += 2 {
+			if b[i] != 0 {
+				utf16 = false
+				break
+			}
+		}
+		if utf16 {
+			return decodeUTF16(b)
+		}
+	}
+	return string(b)
+}
+
+// variant reads a QVariant of the basic types used in exported data
+func (s *qDataStream) variant() (any, error) {
+	typ := s.uint32()
+	s.next(1) // isNull flag
+
+	var value any
+	switch typ {
+	case 1: // Bool
+		value = s.bool()
+	case 2, 3: // Int, UInt
+		value = int64(s.int32())
+	case 4, 5: // LongLong, ULongLong
+		hi := s.uint32()
+		value = int64(hi)<<32 | int64(s.uint32())
+	case 6: // Double
+		hi := s.uint32()
+		value = math.Float64frombits(uint64(hi)<<32 | uint64(s.uint32()))
+	case 8: // QVariantMap
+		return s.variantMap("")
+	case 9: // QVariantList
+		n := s.uint32()
+		var list []any
+		for i := uint32(0); i < n && s.err == nil; i++ {
+			v, err := s.variant()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		value = list
+	case 10: // QString
+		value = s.string()
+	case 11: // QStringList
+		n := s.uint32()
+		var list []string
+		for i := uint32(0); i < n && s.err == nil; i++ {
+			list = append(list, s.string())
+		}
+		value = list
+	case 12: // QByteArray
+		value = s.byteArray()
+	default:
+		return nil, fmt.Errorf("unsupported QVariant type %d", typ)
+	}
+
+	return value, s.err
+}
+
+// variantMap reads a QVariantMap. If stopAt is set, reading stops once that key has
+// been read, so entries of unsupported types after it don't matter.
+func (s *qDataStream) variantMap(stopAt string) (map[string]any, error) {
+	n := s.uint32()
+	m := make(map[string]any)
+	for i := uint32(0); i < n && s.err == nil; i++ {
+		key := s.string()
+		value, err := s.variant()
+		if err != nil {
+			return m, err
+		}
+		m[key] = value
+		if key == stopAt {
+			break
+		}
+	}
+	return m, s.err
+}
+
+// decodeUTF16 decodes big endian UTF-16 text
+func decodeUTF16(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// qUncompress undoes Qt's qCompress: a big endian length followed by a zlib stream
+func qUncompress(b []byte) ([]byte, error) {
+	if len(b) < 4 {
+		return nil, errShortStream
+	}
+	r, err := zlib.NewReader(bytes.NewReader(b[4:]))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// urlsToContent turns a list of URLs into a file list item if they are local files
+func urlsToContent(urls []string) string {
+	var paths []string
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme != "file" {
+			return strings.Join(urls, "\n")
+		}
+		paths = append(paths, u.Path)
+	}
+	if len(paths) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(formatURIList(paths, "\n"), "\n")
+}
+
+// parseKlipperHistory reads Klipper's history2.lst: a CRC32 followed by a QByteArray
+// holding the Klipper version string and the items, newest first. Klipper keeps no
+// timestamps or pins. Images can't be skipped in the stream, so reading stops there.
+func parseKlipperHistory(data []byte) ([]core.Item, error) {
+	s := &qDataStream{data: data}
+	crc := s.uint32()
+	payload := s.byteArray()
+	if s.err != nil {
+		return nil, fmt.Errorf("not a Klipper history: %w", s.err)
+	}
+	if crc32.ChecksumIEEE(payload) != crc {
+		return nil, errors.New("not a Klipper history: checksum mismatch")
+	}
+
+	s = &qDataStream{data: payload}
+	s.string() // Klipper version
+
+	var items []core.Item
+	for !s.done() {
+		var content string
+		switch kind := s.string(); kind {
+		case "string":
+			content = s.string()
+		case "url":
+			n := s.uint32()
+			var urls []string
+			for i := uint32(0); i < n && s.err == nil; i++ {
+				urls = append(urls, string(s.byteArray()))
+			}
+			// Metadata map and the "cut" flag, which Klipper writes as an int
+			n = s.uint32()
+			for i := uint32(0); i < 2*n && s.err == nil; i++ {
+				s.string()
+			}
+			s.int32()
+			content = urlsToContent(urls)
+		default:
+			slog.Warn("stopped Klipper import at unsupported item", "kind", kind)
+			return items, nil
+		}
+
+		if s.err != nil {
+			return items, fmt.Errorf("Klipper history is truncated: %w", s.err)
+		}
+		if content != "" {
+			items = append(items, core.Item{Content: content})
+		}
+	}
+
+	return items, nil
+}
+
+// parseCopyQTabs reads a CopyQ export (.cpq, "CopyQ v4") or a single tab data
+// file (copyq_tab_*.dat). Items of all exported tabs are imported.
+func parseCopyQTabs(data []byte) ([]core.Item, error) {
+	s := &qDataStream{data: data}
+
+	header := s.text()
+	if s.err != nil || !strings.HasPrefix(header, "CopyQ v") {
+		return parseCopyQTab(data)
+	}
+	if header != "CopyQ v4" {
+		return nil, fmt.Errorf("unsupported CopyQ export version %q, export again with a recent CopyQ", header)
+	}
+
+	// Map keys are written in reverse order, so "tabs" comes before settings and commands
+	exported, err := s.variantMap("tabs")
+	tabs, ok := exported["tabs"].([]any)
+	if !ok {
+		if err == nil {
+			err = errors.New("no tabs found")
+		}
+		return nil, fmt.Errorf("could not read CopyQ export: %w", err)
+	}
+
+	var items []core.Item
+	for _, tab := range tabs {
+		tabMap, _ := tab.(map[string]any)
+		tabData, _ := tabMap["data"].([]byte)
+		tabItems, err := parseCopyQTab(tabData)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CopyQ tab %v: %w", tabMap["name"], err)
+		}
+		items = append(items, tabItems...)
+	}
+
+	return items, nil
+}
+
+// parseCopyQTab reads the serialized items of one CopyQ tab, each a map of MIME type to data
+func parseCopyQTab(data []byte) ([]core.Item, error) {
+	s := &qDataStream{data: data}
+	count := s.int32()
+	if s.err != nil || count < 0 {
+		return nil, errors.New("not a CopyQ tab")
+	}
+
+	var items []core.Item
+	for i := int32(0); i < count; i++ {
+		formats := make(map[string][]byte)
+
+		length := s.int32()
+		switch {
+		case length == -2:
+			// Current format: MIME types are abbreviated and data may be compressed
+			size := s.int32()
+			for j := int32(0); j < size && s.err == nil; j++ {
+				mime := s.text()
+				compressed := s.bool()
+				value := s.byteArray()
+				if compressed && s.err == nil {
+					var err error
+					if value, err = qUncompress(value); err != nil {
+						return nil, fmt.Errorf("corrupt item data: %w", err)
+					}
+				}
+				formats[mime] = value
+			}
+		case length >= 0:
+			// Old format: all data is compressed
+			for j := int32(0); j < length && s.err == nil; j++ {
+				mime := s.string()
+				value := s.byteArray()
+				if len(value) > 0 && s.err == nil {
+					var err error
+					if value, err = qUncompress(value); err != nil {
+						return nil, fmt.Errorf("corrupt item data: %w", err)
+					}
+				}
+				formats[mime] = value
+			}
+		default:
+			return nil, fmt.Errorf("unsupported item format %d", length)
+		}
+
+		if s.err != nil {
+			return nil, fmt.Errorf("CopyQ tab is truncated: %w", s.err)
+		}
+		if item, ok := copyQItem(formats); ok {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// copyQItem picks an item's content from its formats. Abbreviated MIME types start
+// with a digit, so formats are matched by suffix.
+func copyQItem(formats map[string][]byte) (core.Item, bool) {
+	var item core.Item
+	var fallback string
+
+	for mime, value := range formats {
+		switch {
+		case strings.HasSuffix(mime, "pinned"):
+			item.Pinned = true
+		case strings.HasSuffix(mime, "uri-list"):
+			if isURIList(string(value)) {
+				item.Content = strings.ReplaceAll(string(value), "\r\n", "\n")
+			}
+		case strings.HasSuffix(mime, "plain") || strings.Contains(mime, "plain;"):
+			if item.Content == "" {
+				fallback = string(value)
+			}
+		case !strings.Contains(mime, "copyq") && !strings.Contains(mime, "image") && fallback == "":
+			if utf8.Valid(value) && len(value) > 0 {
+				fallback = string(value)
+			}
+		}
+	}
+
+	if item.Content == "" {
+		item.Content = fallback
+	}
+	return item, item.Content != ""
+}
+
+// gpasteHistory is GPaste's history.xml. Version 1 keeps the content directly in
+// the item element, version 2 in a value element.
+type gpasteHistory struct {
+	Items []struct {
+		Kind  string `xml:"kind,attr"`
+		Date  string `xml:"date,attr"`
+		Value string `xml:"value"`
+		Text  string `xml:",chardata"`
+	} `xml:"item"`
+}
+
+// parseGPasteHistory reads GPaste's history.xml, newest first. Passwords and images are skipped.
+func parseGPasteHistory(data []byte) ([]core.Item, error) {
+	var history gpasteHistory
+	if err := xml.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("not a GPaste history: %w", err)
+	}
+
+	var items []core.Item
+	for _, entry := range history.Items {
+		content := entry.Value
+		if content == "" {
+			content = entry.Text
+		}
+
+		switch entry.Kind {
+		case "Text", "":
+		case "Uris":
+			var paths []string
+			for _, line := range strings.Split(content, "\n") {
+				line = strings.TrimSpace(line)
+				if u, err := url.Parse(line); err == nil && u.Scheme == "file" {
+					line = u.Path
+				}
+				if line != "" {
+					paths = append(paths, line)
+				}
+			}
+			if len(paths) == 0 {
+				continue
+			}
+			content = strings.TrimSuffix(formatURIList(paths, "\n"), "\n")
+		default:
+			continue
+		}
+
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+
+		item := core.Item{Content: content}
+		if seconds, err := strconv.ParseInt(entry.Date, 10, 64); err == nil {
+			item.Timestamp = time.Unix(seconds, 0)
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
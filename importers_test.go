@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"NoteBoard/core"
+)
+
+// parseFixture parses a history file from testdata in the given import format
+func parseFixture(t *testing.T, format, name string) []core.Item {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := parseImportFrom(format, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+// itemContents returns the content of every item in order
+func itemContents(items []core.Item) []string {
+	var result []string
+	for _, item := range items {
+		result = append(result, item.Content)
+	}
+	return result
+}
+
+func TestParseKlipperHistory(t *testing.T) {
+	items := parseFixture(t, importKlipper, "klipper-history2.lst")
+
+	want := []string{
+		"newest text",
+		"file:///home/user/a%20b.txt\nfile:///home/user/c",
+		"https://example.com/page",
+		"multi\nline ü",
+	}
+	if got := itemContents(items); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Items without timestamps keep the file's order, newest first
+	for i := 1; i < len(items); i++ {
+		if !items[i].Timestamp.Before(items[i-1].Timestamp) {
+			t.Errorf("item %d isn't older than item %d", i, i-1)
+		}
+	}
+}
+
+func TestParseKlipperHistoryRejectsCorruptFiles(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "klipper-history2.lst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if _, err := parseKlipperHistory(data); err == nil {
+		t.Error("a checksum mismatch was accepted")
+	}
+	if _, err := parseKlipperHistory(data[:6]); err == nil {
+		t.Error("a truncated file was accepted")
+	}
+}
+
+func TestParseCopyQ(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"copyq-tab.dat", []string{"copyq text", "pinned text", "file:///tmp/x\nfile:///tmp/y"}},
+		{"copyq-tab-old.dat", []string{"old format text"}},
+		{"copyq-export.cpq", []string{"copyq text", "pinned text", "file:///tmp/x\nfile:///tmp/y", "old format text"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := parseFixture(t, importCopyQ, tt.name)
+			if got := itemContents(items); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			for _, item := range items {
+				if item.Pinned != (item.Content == "pinned text") {
+					t.Errorf("%q has pinned %v", item.Content, item.Pinned)
+				}
+			}
+		})
+	}
+}
+
+func TestParseGPasteHistory(t *testing.T) {
+	items := parseFixture(t, importGPaste, "gpaste-history.xml")
+
+	// Passwords, images and blank items are skipped
+	want := []string{"gpaste text", "file:///home/user/a%20b.txt\nfile:///home/user/c"}
+	if got := itemContents(items); !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if !items[0].Timestamp.Equal(time.Unix(1700000200, 0)) {
+		t.Errorf("timestamp %v wasn't read from the date", items[0].Timestamp)
+	}
+
+	old := parseFixture(t, importGPaste, "gpaste-history-v1.xml")
+	if got := itemContents(old); !slices.Equal(got, []string{"old gpaste text"}) {
+		t.Errorf("version 1 history: got %q", got)
+	}
+}
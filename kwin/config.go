@@ -0,0 +1,278 @@
+// Package kwin reads and writes KWin's window rules in kwinrulesrc without going
+// through kreadconfig and kwriteconfig, so a rule is created, updated or deleted
+// in one atomic write.
+package kwin
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// entry is one line of a group: a key and its raw, escaped value, or a comment
+type entry struct {
+	key     string
+	value   string
+	comment string
+}
+
+// Group is a [section] of a KDE config file
+type Group struct {
+	Name    string
+	entries []entry
+}
+
+// Config is a KDE config file. Groups, keys and comments keep their order so
+// rewriting a file only changes what was modified.
+type Config struct {
+	groups []*Group
+}
+
+// Parse reads a KDE config file. Entries before the first group header belong
+// to the group named "".
+func Parse(r io.Reader) (*Config, error) {
+	c := &Config{}
+	group := &Group{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case text == "":
+			continue
+		case strings.HasPrefix(text, "#"):
+			group.entries = append(group.entries, entry{comment: text})
+		case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+			if group.Name != "" || len(group.entries) > 0 {
+				c.groups = append(c.groups, group)
+			}
+			group = &Group{Name: text[1 : len(text)-1]}
+		default:
+			key, value, found := strings.Cut(text, "=")
+			if !found {
+				return nil, fmt.Errorf("line %d: expected key=value, got %q", line, text)
+			}
+			group.entries = append(group.entries, entry{key: strings.TrimSpace(key), value: strings.TrimSpace(value)})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if group.Name != "" || len(group.entries) > 0 {
+		c.groups = append(c.groups, group)
+	}
+	return c, nil
+}
+
+// Load reads a KDE config file. A missing file is an empty config.
+func Load(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Parse(file)
+}
+
+// Bytes returns the config in KDE's file format
+func (c *Config) Bytes() []byte {
+	var b bytes.Buffer
+	for i, group := range c.groups {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if group.Name != "" || i > 0 {
+			fmt.Fprintf(&b, "[%s]\n", group.Name)
+		}
+		for _, e := range group.entries {
+			if e.comment != "" {
+				b.WriteString(e.comment + "\n")
+			} else {
+				b.WriteString(e.key + "=" + e.value + "\n")
+			}
+		}
+	}
+	return b.Bytes()
+}
+
+// Save writes the config to path atomically: readers such as KWin see either the
+// old or the new file, never a partly written one
+func (c *Config) Save(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Keep the permissions of the existing file
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(c.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Group returns the named group, or nil if there is none
+func (c *Config) Group(name string) *Group {
+	for _, group := range c.groups {
+		if group.Name == name {
+			return group
+		}
+	}
+	return nil
+}
+
+// GroupNames returns the names of all groups in file order
+func (c *Config) GroupNames() []string {
+	var names []string
+	for _, group := range c.groups {
+		names = append(names, group.Name)
+	}
+	return names
+}
+
+// AddGroup returns the named group, appending an empty one if there is none
+func (c *Config) AddGroup(name string) *Group {
+	if group := c.Group(name); group != nil {
+		return group
+	}
+	group := &Group{Name: name}
+	c.groups = append(c.groups, group)
+	return group
+}
+
+// RemoveGroup removes the named group and reports whether it existed
+func (c *Config) RemoveGroup(name string) bool {
+	i := slices.IndexFunc(c.groups, func(group *Group) bool { return group.Name == name })
+	if i < 0 {
+		return false
+	}
+	c.groups = slices.Delete(c.groups, i, i+1)
+	return true
+}
+
+// Get returns the unescaped value of a key
+func (g *Group) Get(key string) (string, bool) {
+	for _, e := range g.entries {
+		if e.comment == "" && e.key == key {
+			return unescape(e.value), true
+		}
+	}
+	return "", false
+}
+
+// Keys returns the keys of the group in file order
+func (g *Group) Keys() []string {
+	var keys []string
+	for _, e := range g.entries {
+		if e.comment == "" {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys
+}
+
+// Set sets a key, keeping its position if it already exists
+func (g *Group) Set(key, value string) {
+	for i, e := range g.entries {
+		if e.comment == "" && e.key == key {
+			g.entries[i].value = escape(value)
+			return
+		}
+	}
+	g.entries = append(g.entries, entry{key: key, value: escape(value)})
+}
+
+// Delete removes a key
+func (g *Group) Delete(key string) {
+	g.entries = slices.DeleteFunc(g.entries, func(e entry) bool {
+		return e.comment == "" && e.key == key
+	})
+}
+
+// Clear removes all keys and comments
+func (g *Group) Clear() {
+	g.entries = nil
+}
+
+// escape encodes a value the way KConfig writes it
+func escape(value string) string {
+	var b strings.Builder
+	for i, r := range value {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == ' ' && (i == 0 || i == len(value)-1):
+			// Leading and trailing spaces would be trimmed when reading
+			b.WriteString(`\s`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// unescape decodes a value written by KConfig
+func unescape(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 's':
+			b.WriteByte(' ')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
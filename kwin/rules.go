@@ -0,0 +1,133 @@
+package kwin
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// generalGroup lists the rules in kwinrulesrc
+const generalGroup = "General"
+
+// DefaultPath returns the current user's kwinrulesrc
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kwinrulesrc"), nil
+}
+
+// NewRuleID returns a random ID for a new rule. KWin names new rules by UUID,
+// which stays valid however other rules are added or removed.
+func NewRuleID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40 // Version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// RuleIDs returns the IDs of the rules in the order KWin applies them. They are
+// the names of the rules' groups, listed in General/rules. Files written before
+// Plasma 5.20 only have General/count and number their rules from 1.
+func (c *Config) RuleIDs() []string {
+	general := c.Group(generalGroup)
+	if general == nil {
+		return nil
+	}
+
+	if rules, ok := general.Get("rules"); ok {
+		var ids []string
+		for _, id := range strings.Split(rules, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+
+	count, _ := general.Get("count")
+	n, _ := strconv.Atoi(count)
+	var ids []string
+	for i := 1; i <= n; i++ {
+		ids = append(ids, strconv.Itoa(i))
+	}
+	return ids
+}
+
+// Rule returns the settings of a rule, e.g. "title" and "titlematch"
+func (c *Config) Rule(id string) (map[string]string, bool) {
+	if !slices.Contains(c.RuleIDs(), id) {
+		return nil, false
+	}
+	group := c.Group(id)
+	if group == nil {
+		return nil, false
+	}
+
+	settings := make(map[string]string)
+	for _, key := range group.Keys() {
+		settings[key], _ = group.Get(key)
+	}
+	return settings, true
+}
+
+// FindRule returns the ID of the first rule with exactly the given description
+func (c *Config) FindRule(description string) (string, bool) {
+	for _, id := range c.RuleIDs() {
+		if group := c.Group(id); group != nil {
+			if value, _ := group.Get("Description"); value == description {
+				return id, true
+			}
+		}
+	}
+	return "", false
+}
+
+// SetRule creates a rule or replaces all settings of an existing one. New rules
+// are added to the end of the rule list.
+func (c *Config) SetRule(id string, settings map[string]string) {
+	group := c.AddGroup(id)
+	group.Clear()
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		group.Set(key, settings[key])
+	}
+
+	ids := c.RuleIDs()
+	if !slices.Contains(ids, id) {
+		ids = append(ids, id)
+	}
+	c.setRuleIDs(ids)
+}
+
+// DeleteRule removes a rule and reports whether it existed
+func (c *Config) DeleteRule(id string) bool {
+	ids := c.RuleIDs()
+	i := slices.Index(ids, id)
+	if i < 0 {
+		return false
+	}
+
+	c.RemoveGroup(id)
+	c.setRuleIDs(slices.Delete(ids, i, i+1))
+	return true
+}
+
+// setRuleIDs writes the rule list, keeping count in sync for older KWin versions
+func (c *Config) setRuleIDs(ids []string) {
+	general := c.AddGroup(generalGroup)
+	general.Set("count", strconv.Itoa(len(ids)))
+	general.Set("rules", strings.Join(ids, ","))
+}
@@ -0,0 +1,179 @@
+package kwin
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// load parses a file from testdata
+func load(t *testing.T, name string) *Config {
+	t.Helper()
+	c, err := Load(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, name := range []string{"plasma6.kwinrulesrc", "plasma5.kwinrulesrc", "legacy.kwinrulesrc"} {
+		t.Run(name, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := load(t, name).Bytes(); string(got) != string(want) {
+				t.Errorf("rewriting changed the file:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestParseRejectsGarbage(t *testing.T) {
+	if _, err := Parse(strings.NewReader("[General]\nnot a key\n")); err == nil {
+		t.Error("Parse accepted a line without =")
+	}
+}
+
+func TestRuleIDs(t *testing.T) {
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"plasma6.kwinrulesrc", []string{"1f9d1f4c-3b1e-4d0a-9f5e-2a7c61b0e8d2", "7c2b6a43-0f52-4e8c-a1a4-6c1b9d3e5f70"}},
+		{"plasma5.kwinrulesrc", []string{"1", "2"}},
+		{"legacy.kwinrulesrc", []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := load(t, tt.file).RuleIDs(); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindRuleMatchesExactly(t *testing.T) {
+	if id, ok := load(t, "plasma6.kwinrulesrc").FindRule("NoteBoard Window Rule"); ok {
+		t.Errorf("found rule %s by a partial description", id)
+	}
+
+	c := load(t, "plasma5.kwinrulesrc")
+	id, ok := c.FindRule("NoteBoard Window Rule")
+	if !ok || id != "2" {
+		t.Fatalf("FindRule returned %q, %v", id, ok)
+	}
+	if settings, _ := c.Rule(id); settings["title"] != "NoteBoard" || settings["above"] != "true" {
+		t.Errorf("got settings %v", settings)
+	}
+}
+
+func TestSetRuleCreates(t *testing.T) {
+	for _, name := range []string{"plasma6.kwinrulesrc", "legacy.kwinrulesrc"} {
+		t.Run(name, func(t *testing.T) {
+			c := load(t, name)
+			before := c.RuleIDs()
+
+			id := NewRuleID()
+			c.SetRule(id, map[string]string{"Description": "NoteBoard Window Rule", "above": "true"})
+
+			// Reparse to check what KWin would read
+			c, err := Parse(strings.NewReader(string(c.Bytes())))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := c.RuleIDs(), append(before, id); !slices.Equal(got, want) {
+				t.Errorf("got rules %q, want %q", got, want)
+			}
+			if count, _ := c.Group("General").Get("count"); count != "3" {
+				t.Errorf("got count %s, want 3", count)
+			}
+			if found, _ := c.FindRule("NoteBoard Window Rule"); found != id {
+				t.Errorf("new rule not found, got %q", found)
+			}
+		})
+	}
+}
+
+func TestSetRuleUpdates(t *testing.T) {
+	c := load(t, "plasma5.kwinrulesrc")
+	c.SetRule("2", map[string]string{"Description": "NoteBoard Window Rule", "above": "false"})
+
+	settings, _ := c.Rule("2")
+	if len(settings) != 2 || settings["above"] != "false" {
+		t.Errorf("settings were not replaced: %v", settings)
+	}
+	if got := c.RuleIDs(); !slices.Equal(got, []string{"1", "2"}) {
+		t.Errorf("rule list changed to %q", got)
+	}
+	if other, _ := c.Rule("1"); other["wmclass"] != "konsole org.kde.konsole" {
+		t.Errorf("other rule changed: %v", other)
+	}
+}
+
+func TestDeleteRule(t *testing.T) {
+	c := load(t, "plasma5.kwinrulesrc")
+	if !c.DeleteRule("1") {
+		t.Fatal("DeleteRule failed")
+	}
+	if c.DeleteRule("1") {
+		t.Error("deleted a rule twice")
+	}
+
+	if got := c.RuleIDs(); !slices.Equal(got, []string{"2"}) {
+		t.Errorf("got rules %q", got)
+	}
+	if c.Group("1") != nil {
+		t.Error("the rule's group survived")
+	}
+	if count, _ := c.Group("General").Get("count"); count != "1" {
+		t.Errorf("got count %s, want 1", count)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	c := &Config{}
+	value := ` leading, back\slash and
+newline `
+	c.AddGroup("g").Set("key", value)
+
+	parsed, err := Parse(strings.NewReader(string(c.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := parsed.Group("g").Get("key"); got != value {
+		t.Errorf("got %q, want %q", got, value)
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kwinrulesrc")
+
+	c, err := Load(path)
+	if err != nil || len(c.RuleIDs()) != 0 {
+		t.Fatalf("Load without a file returned %v, %v", c, err)
+	}
+
+	c.SetRule("a", map[string]string{"Description": "test"})
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(loaded.Bytes()) != string(c.Bytes()) {
+		t.Errorf("saved file differs:\n%s", loaded.Bytes())
+	}
+
+	// Nothing but the config is left behind
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("got %d files, want 1", len(entries))
+	}
+}
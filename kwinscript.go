@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	// kwinScriptName identifies the placement script in KWin
+	kwinScriptName = "noteboard-placement"
+
+	// The placement script reports remembered positions to this object on
+	// NoteBoard's session bus connection
+	kwinPlacementPath      = "/io/github/ekats/noteboard/Placement"
+	kwinPlacementInterface = "io.github.ekats.noteboard.Placement"
+)
+
+// kwinPlacementScript places NoteBoard's main window inside the work area of the
+// output under the cursor, following the placement mode, and keeps it above
+// other windows if asked to. Wayland clients can't position their own windows,
+// so this has to run inside KWin.
+// It supports the Plasma 5 (client) and Plasma 6 (window) scripting API.
+const kwinPlacementScript = `// Generated by NoteBoard, replaced on every start
+const config = CONFIG;
+const plasma6 = typeof workspace.windowList === "function";
+
+// Positions of hidden windows by caption. The main window's is saved by NoteBoard
+// and passed in again when the script is reloaded.
+const remembered = {};
+if (config.position) {
+    remembered[config.main] = config.position;
+}
+
+function clamp(x, y, width, height, area) {
+    return {
+        x: Math.max(area.x, Math.min(x, area.x + area.width - width)),
+        y: Math.max(area.y, Math.min(y, area.y + area.height - height)),
+    };
+}
+
+function place(window) {
+    const caption = window.caption;
+    if (config.placed.indexOf(caption) < 0) {
+        return;
+    }
+
+    // The output under the cursor, not the one with the active window
+    const cursor = workspace.cursorPos;
+    const output = workspace.screenAt ? workspace.screenAt(cursor) : workspace.activeScreen;
+    let area = workspace.clientArea(KWin.PlacementArea, output, workspace.currentDesktop);
+    const geometry = window.frameGeometry;
+    const width = Math.min(geometry.width, area.width);
+    const height = Math.min(geometry.height, area.height);
+    const mode = caption === config.main ? config.mode : "cursor";
+
+    let x, y;
+    if (mode === "remembered" && remembered[caption]) {
+        // The remembered output may be gone, so clamp to the one the position is on
+        x = remembered[caption].x;
+        y = remembered[caption].y;
+        const screen = workspace.screenAt ? workspace.screenAt({x: x, y: y}) : output;
+        area = workspace.clientArea(KWin.PlacementArea, screen, workspace.currentDesktop);
+    } else if (mode === "centered" || mode === "remembered") {
+        x = area.x + (area.width - width) / 2;
+        y = area.y + (area.height - height) / 2;
+    } else {
+        // Below and right of the cursor, flipped to stay on the output
+        x = cursor.x + config.offset;
+        y = cursor.y + config.offset;
+        if (x + width > area.x + area.width) {
+            x = cursor.x - config.offset - width;
+        }
+        if (y + height > area.y + area.height) {
+            y = cursor.y - config.offset - height;
+        }
+    }
+    const position = clamp(Math.round(x), Math.round(y), width, height, area);
+
+    window.frameGeometry = {x: position.x, y: position.y, width: width, height: height};
+    if (config.above.indexOf(caption) >= 0) {
+        window.keepAbove = true;
+    }
+}
+
+function remember(window) {
+    if (window.caption === config.main && config.mode === "remembered") {
+        const position = {x: Math.round(window.frameGeometry.x), y: Math.round(window.frameGeometry.y)};
+        remembered[window.caption] = position;
+        if (config.service) {
+            callDBus(config.service, "PATH", "INTERFACE", "Remember", position.x + "," + position.y);
+        }
+    }
+}
+
+const windows = plasma6 ? workspace.windowList() : workspace.clientList();
+for (let i = 0; i < windows.length; i++) {
+    place(windows[i]);
+}
+(plasma6 ? workspace.windowAdded : workspace.clientAdded).connect(place);
+(plasma6 ? workspace.windowRemoved : workspace.clientRemoved).connect(remember);
+`
+
+// kwinScriptConfig is passed to the placement script as CONFIG
+type kwinScriptConfig struct {
+	Placed []string `json:"placed"` // Captions of the windows the script places
+	Above  []string `json:"above"`  // Captions of the windows kept above others
+	Main   string   `json:"main"`   // Caption of the window placed according to Mode
+	Mode   string   `json:"mode"`   // Placement mode of the main window, see placement.go
+	Offset int      `json:"offset"` // Distance from the cursor
+
+	Position *windowPosition `json:"position"` // Remembered position of the main window
+	Service  string          `json:"service"`  // NoteBoard's bus name, told about remembered positions
+}
+
+// kwinPlacementReceiver saves the positions the placement script remembers
+type kwinPlacementReceiver struct {
+	save func(position *windowPosition)
+}
+
+// Remember is called by the placement script with the main window's position as "x,y"
+func (r kwinPlacementReceiver) Remember(position string) *dbus.Error {
+	var p windowPosition
+	if _, err := fmt.Sscanf(position, "%d,%d", &p.X, &p.Y); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("invalid position %q", position))
+	}
+	r.save(&p)
+	return nil
+}
+
+// loadKWinPlacementScript (re)loads the placement script over KWin's D-Bus
+// scripting interface. keepMainAbove also keeps the main window above others;
+// placement is how the main window is placed. In remembered mode the script
+// hands the position the window was hidden at to save, so it outlives the script.
+func loadKWinPlacementScript(keepMainAbove bool, placement PlacementSettings, save func(*windowPosition)) error {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return fmt.Errorf("could not connect to the session bus: %w", err)
+	}
+	if err := conn.Export(kwinPlacementReceiver{save: save}, kwinPlacementPath, kwinPlacementInterface); err != nil {
+		return fmt.Errorf("could not export placement receiver: %w", err)
+	}
+
+	config := kwinScriptConfig{
+		Placed:   []string{appName},
+		Above:    []string{},
+		Main:     appName,
+		Mode:     placement.Mode,
+		Offset:   placementOffset,
+		Position: placement.Position,
+	}
+	if keepMainAbove {
+		config.Above = append(config.Above, appName)
+	}
+	if names := conn.Names(); len(names) > 0 {
+		config.Service = names[0]
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	// KWin loads scripts from files
+	configDir, err := getConfigDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(configDir, "runtime", kwinScriptName+".js")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	script := strings.NewReplacer(
+		"CONFIG", string(configJSON),
+		`"PATH"`, strconv.Quote(kwinPlacementPath),
+		`"INTERFACE"`, strconv.Quote(kwinPlacementInterface),
+	).Replace(kwinPlacementScript)
+	if err := os.WriteFile(path, []byte(script), 0600); err != nil {
+		return err
+	}
+
+	scripting := conn.Object("org.kde.KWin", "/Scripting")
+
+	// Scripts stay loaded until KWin restarts, so replace the one of an earlier run
+	if err := unloadKWinScript(scripting); err != nil {
+		return err
+	}
+
+	var id int32
+	if err := scripting.Call("org.kde.kwin.Scripting.loadScript", 0, path, kwinScriptName).Store(&id); err != nil {
+		return fmt.Errorf("could not load KWin script: %w", err)
+	}
+	if id < 0 {
+		return fmt.Errorf("KWin rejected the script %s", path)
+	}
+
+	// Plasma 6 exports loaded scripts under /Scripting, Plasma 5 at the top level
+	scriptPath := dbus.ObjectPath(fmt.Sprintf("/Scripting/Script%d", id))
+	if getKDETools().version < 6 {
+		scriptPath = dbus.ObjectPath(fmt.Sprintf("/%d", id))
+	}
+	if err := conn.Object("org.kde.KWin", scriptPath).Call("org.kde.kwin.Script.run", 0).Err; err != nil {
+		return fmt.Errorf("could not run KWin script: %w", err)
+	}
+
+	slog.Debug("loaded KWin placement script", "id", id, "path", path)
+	return nil
+}
+
+// unloadKWinPlacementScript removes the placement script from KWin, e.g. on exit
+func unloadKWinPlacementScript() error {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+	return unloadKWinScript(conn.Object("org.kde.KWin", "/Scripting"))
+}
+
+// unloadKWinScript unloads the placement script if it is loaded
+func unloadKWinScript(scripting dbus.BusObject) error {
+	var loaded bool
+	if err := scripting.Call("org.kde.kwin.Scripting.isScriptLoaded", 0, kwinScriptName).Store(&loaded); err != nil {
+		return fmt.Errorf("KWin scripting unavailable: %w", err)
+	}
+	if !loaded {
+		return nil
+	}
+
+	var unloaded bool
+	return scripting.Call("org.kde.kwin.Scripting.unloadScript", 0, kwinScriptName).Store(&unloaded)
+}
+
+// usesKWinPlacement reports whether windows are placed by the KWin script
+func usesKWinPlacement() bool {
+	return isWaylandSession() && isKDEPlasma()
+}
@@ -0,0 +1,811 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"NoteBoard/core"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	// Pairing codes use an alphabet without look-alike characters
+	pairingAlphabet   = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	pairingCodeLen    = 8
+	pairingCodeExpiry = 2 * time.Minute
+
+	// The pairing code is stretched so a captured pairing can't be brute forced while the code is valid
+	pairingIterations = 100000
+
+	lanTimeout = 10 * time.Second
+
+	// Items older than this are rejected as replays
+	lanMaxMessageAge = 5 * time.Minute
+
+	// Limits what a device reads from a connection before it can check the sender.
+	// Item messages carry the sealed item base64 encoded, which takes a third more.
+	lanMaxMessageSize = maxItemSize * 3 / 2
+)
+
+// LANSettings configures sharing items with paired devices on the local network
+type LANSettings struct {
+	Enabled    bool         `json:"enabled"`
+	AutoMirror bool         `json:"autoMirror"` // Send every new clipboard item to all paired devices
+	Port       int          `json:"port"`       // 0 picks a free port
+	DeviceID   string       `json:"deviceId"`
+	Name       string       `json:"name"`
+	Peers      []PairedPeer `json:"peers"`
+}
+
+// PairedPeer is a device this one shares a key with
+type PairedPeer struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Key  string `json:"key"` // Base64 encoded AES-256 key
+}
+
+// lanPeer is a NoteBoard instance found on the network
+type lanPeer struct {
+	id   string
+	name string
+	addr string
+	seen time.Time
+}
+
+// lanPairing is the pairing code this device currently accepts
+type lanPairing struct {
+	code    string
+	expires time.Time
+}
+
+// lanState holds the running LAN sharing service
+type lanState struct {
+	mu       sync.Mutex
+	listener net.Listener
+	mdns     *net.UDPConn
+	self     mdnsService
+	peers    map[string]lanPeer // Discovered peers by device ID
+	pairing  *lanPairing
+}
+
+// lanMessage is one message of the LAN protocol, sent as a JSON line
+type lanMessage struct {
+	Type   string `json:"type"` // "pair", "confirm", "item", "ok" or "error"
+	Device string `json:"device"`
+	Name   string `json:"name,omitempty"`
+	Key    []byte `json:"key,omitempty"` // X25519 public key while pairing
+	MAC    []byte `json:"mac,omitempty"` // Proof of knowing the pairing code
+	Nonce  []byte `json:"nonce,omitempty"`
+	Data   []byte `json:"data,omitempty"` // lanPayload sealed with the pair key
+	Error  string `json:"error,omitempty"`
+}
+
+// lanPayload is the encrypted content of an item message
+type lanPayload struct {
+	Sent time.Time `json:"sent"`
+	To   string    `json:"to"` // Device ID of the receiver, so items can't be reflected to the sender
+	Item core.Item `json:"item"`
+}
+
+// lanNonces remembers the nonces of the items received from each device while
+// they are fresh, so a captured item can't be replayed
+type lanNonces struct {
+	mu   sync.Mutex
+	seen map[string]map[string]time.Time // Send times by nonce, by device ID
+}
+
+// add records the nonce of an item sent at the given time and reports whether
+// it wasn't seen before
+func (n *lanNonces) add(device string, nonce []byte, sent time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.seen == nil {
+		n.seen = make(map[string]map[string]time.Time)
+	}
+	seen := n.seen[device]
+	if seen == nil {
+		seen = make(map[string]time.Time)
+		n.seen[device] = seen
+	}
+
+	// Items older than lanMaxMessageAge are rejected anyway
+	for key, at := range seen {
+		if time.Since(at) > lanMaxMessageAge {
+			delete(seen, key)
+		}
+	}
+
+	if _, ok := seen[string(nonce)]; ok {
+		return false
+	}
+	seen[string(nonce)] = sent
+	return true
+}
+
+// newPairingCode returns a random pairing code
+func newPairingCode() string {
+	b := make([]byte, pairingCodeLen)
+	rand.Read(b)
+	for i := range b {
+		b[i] = pairingAlphabet[int(b[i])%len(pairingAlphabet)]
+	}
+	return string(b)
+}
+
+// formatPairingCode splits a pairing code in two for reading it out
+func formatPairingCode(code string) string {
+	return code[:pairingCodeLen/2] + "-" + code[pairingCodeLen/2:]
+}
+
+// normalizePairingCode undoes formatting and typing variations in an entered code
+func normalizePairingCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// pairingTranscript binds the pairing to both devices and their key exchange
+func pairingTranscript(initiator, responder lanMessage) []byte {
+	h := sha256.New()
+	h.Write([]byte("noteboard-pair\x00"))
+	for _, part := range [][]byte{[]byte(initiator.Device), []byte(responder.Device), initiator.Key, responder.Key} {
+		h.Write([]byte(strconv.Itoa(len(part)) + ":"))
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+// pairingKeys derives the confirmation MAC key from the code and the pair key
+// from the key exchange, so the pair key stays secret even if the code leaks later
+func pairingKeys(code string, shared, transcript []byte) (macKey, pairKey []byte, err error) {
+	macKey, err = pbkdf2.Key(sha256.New, code, transcript, pairingIterations, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+	mac := hmac.New(sha256.New, shared)
+	mac.Write(transcript)
+	mac.Write(macKey)
+	return macKey, mac.Sum(nil), nil
+}
+
+// pairingMAC proves knowledge of the code for one side of the pairing
+func pairingMAC(macKey []byte, role string, transcript []byte) []byte {
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write([]byte(role))
+	mac.Write(transcript)
+	return mac.Sum(nil)
+}
+
+// lanSnapshot returns the running LAN service, or nil, and the LAN settings
+func (cm *ClipboardManager) lanSnapshot() (*lanState, LANSettings) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.lanState, cm.lan
+}
+
+// startLAN listens for paired devices and starts discovery. Callers hold cm.mu.
+func (cm *ClipboardManager) startLAN() {
+	if !cm.lan.Enabled || cm.lanState != nil {
+		return
+	}
+	if cm.lan.DeviceID == "" || cm.lan.Name == "" {
+		// Enabled by editing the config file, store the generated defaults
+		cm.updateLAN(cm.lan)
+	}
+
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(cm.lan.Port))
+	if err != nil {
+		slog.Warn("could not start LAN sharing", "err", err)
+		return
+	}
+
+	state := &lanState{
+		listener: listener,
+		peers:    make(map[string]lanPeer),
+		self: mdnsService{
+			id:   cm.lan.DeviceID,
+			name: cm.lan.Name,
+			port: listener.Addr().(*net.TCPAddr).Port,
+			ttl:  mdnsTTL,
+		},
+	}
+	cm.lanState = state
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go cm.handleLANConn(conn)
+		}
+	}()
+
+	state.mdns, err = listenMDNS()
+	if err != nil {
+		slog.Warn("LAN discovery unavailable", "err", err)
+		return
+	}
+	go runDiscovery(state.mdns, state.self, func(service mdnsService, ip net.IP) {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+
+		if service.ttl == 0 {
+			delete(state.peers, service.id)
+			return
+		}
+		state.peers[service.id] = lanPeer{
+			id:   service.id,
+			name: service.name,
+			addr: net.JoinHostPort(ip.String(), strconv.Itoa(service.port)),
+			seen: time.Now(),
+		}
+	})
+}
+
+// stopLAN says goodbye on the network and stops listening. Callers hold cm.mu.
+func (cm *ClipboardManager) stopLAN() {
+	state := cm.lanState
+	if state == nil {
+		return
+	}
+	cm.lanState = nil
+
+	state.listener.Close()
+	if state.mdns != nil {
+		goodbye := state.self
+		goodbye.ttl = 0
+		if data, err := buildMDNSAnnouncement(goodbye); err == nil {
+			group, _ := net.ResolveUDPAddr("udp4", mdnsAddress)
+			state.mdns.WriteToUDP(data, group)
+		}
+		state.mdns.Close()
+	}
+}
+
+// setLAN stores the LAN settings and restarts the service
+func (cm *ClipboardManager) setLAN(settings LANSettings) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.updateLAN(settings)
+}
+
+// updateLAN stores the LAN settings and restarts the service. Callers hold cm.mu.
+func (cm *ClipboardManager) updateLAN(settings LANSettings) {
+	if settings.DeviceID == "" {
+		settings.DeviceID = newDeviceID()
+	}
+	if settings.Name == "" {
+		settings.Name, _ = os.Hostname()
+	}
+
+	restart := settings.Enabled != cm.lan.Enabled || settings.Port != cm.lan.Port
+	cm.lan = settings
+
+	config := loadConfig()
+	config.LAN = settings
+	saveConfig(config)
+
+	if restart {
+		cm.stopLAN()
+		cm.startLAN()
+	}
+}
+
+// lanPeers returns the discovered devices, ignoring ones not heard of for a while
+func (cm *ClipboardManager) lanPeers() []lanPeer {
+	state, _ := cm.lanSnapshot()
+	if state == nil {
+		return nil
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	var peers []lanPeer
+	for _, peer := range state.peers {
+		if time.Since(peer.seen) < 3*mdnsQueryInterval {
+			peers = append(peers, peer)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].name < peers[j].name })
+	return peers
+}
+
+// pairedPeer returns the pairing with the given device
+func (cm *ClipboardManager) pairedPeer(id string) (PairedPeer, []byte, bool) {
+	_, settings := cm.lanSnapshot()
+	for _, peer := range settings.Peers {
+		if peer.ID == id {
+			key, err := base64.StdEncoding.DecodeString(peer.Key)
+			return peer, key, err == nil && len(key) == encryptionKeyLen
+		}
+	}
+	return PairedPeer{}, nil, false
+}
+
+// savePairedPeer stores or replaces the pairing with a device
+func (cm *ClipboardManager) savePairedPeer(id, name string, key []byte) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	settings := cm.lan
+	settings.Peers = nil
+	for _, peer := range cm.lan.Peers {
+		if peer.ID != id {
+			settings.Peers = append(settings.Peers, peer)
+		}
+	}
+	settings.Peers = append(settings.Peers, PairedPeer{ID: id, Name: name, Key: base64.StdEncoding.EncodeToString(key)})
+	cm.updateLAN(settings)
+}
+
+// forgetPeer removes the pairing with a device
+func (cm *ClipboardManager) forgetPeer(id string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	settings := cm.lan
+	settings.Peers = nil
+	for _, peer := range cm.lan.Peers {
+		if peer.ID != id {
+			settings.Peers = append(settings.Peers, peer)
+		}
+	}
+	cm.updateLAN(settings)
+}
+
+// startPairing makes this device accept a pairing with the returned code for a while
+func (cm *ClipboardManager) startPairing() (string, error) {
+	state, _ := cm.lanSnapshot()
+	if state == nil {
+		return "", errors.New("LAN sharing is not running")
+	}
+
+	code := newPairingCode()
+	state.mu.Lock()
+	state.pairing = &lanPairing{code: code, expires: time.Now().Add(pairingCodeExpiry)}
+	state.mu.Unlock()
+	return code, nil
+}
+
+// takePairingCode returns the current pairing code and invalidates it, so every
+// code allows a single attempt
+func (state *lanState) takePairingCode() (string, bool) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	pairing := state.pairing
+	state.pairing = nil
+	if pairing == nil || time.Now().After(pairing.expires) {
+		return "", false
+	}
+	return pairing.code, true
+}
+
+// handleLANConn answers one request from another device
+func (cm *ClipboardManager) handleLANConn(conn net.Conn) {
+	defer conn.Close()
+	// Unpaired devices can connect too, so they get neither unlimited time nor memory
+	conn.SetDeadline(time.Now().Add(lanTimeout))
+
+	_, settings := cm.lanSnapshot()
+	decoder := json.NewDecoder(io.LimitReader(conn, lanMaxMessageSize))
+	encoder := json.NewEncoder(conn)
+	fail := func(err error) {
+		encoder.Encode(lanMessage{Type: "error", Device: settings.DeviceID, Error: err.Error()})
+	}
+
+	var msg lanMessage
+	if err := decoder.Decode(&msg); err != nil {
+		return
+	}
+
+	switch msg.Type {
+	case "pair":
+		if err := cm.respondPairing(msg, decoder, encoder); err != nil {
+			slog.Warn("pairing failed", "device", msg.Name, "err", err)
+			fail(err)
+		}
+
+	case "item":
+		if err := cm.receiveItem(msg); err != nil {
+			slog.Warn("rejected item", "device", msg.Device, "err", err)
+			fail(err)
+			return
+		}
+		encoder.Encode(lanMessage{Type: "ok", Device: settings.DeviceID})
+
+	default:
+		fail(fmt.Errorf("unknown message %q", msg.Type))
+	}
+}
+
+// respondPairing completes a pairing started by another device
+func (cm *ClipboardManager) respondPairing(request lanMessage, decoder *json.Decoder, encoder *json.Encoder) error {
+	state, settings := cm.lanSnapshot()
+	if state == nil {
+		return errors.New("LAN sharing is not running")
+	}
+	code, ok := state.takePairingCode()
+	if !ok {
+		return errors.New("device is not waiting for a pairing")
+	}
+
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	remote, err := ecdh.X25519().NewPublicKey(request.Key)
+	if err != nil {
+		return err
+	}
+	shared, err := private.ECDH(remote)
+	if err != nil {
+		return err
+	}
+
+	response := lanMessage{Type: "pair", Device: settings.DeviceID, Name: settings.Name, Key: private.PublicKey().Bytes()}
+	transcript := pairingTranscript(request, response)
+	macKey, pairKey, err := pairingKeys(code, shared, transcript)
+	if err != nil {
+		return err
+	}
+	if err := encoder.Encode(response); err != nil {
+		return err
+	}
+
+	var confirm lanMessage
+	if err := decoder.Decode(&confirm); err != nil {
+		return err
+	}
+	if confirm.Type != "confirm" || !hmac.Equal(confirm.MAC, pairingMAC(macKey, "initiator", transcript)) {
+		return errors.New("wrong pairing code")
+	}
+
+	err = encoder.Encode(lanMessage{Type: "confirm", Device: settings.DeviceID, MAC: pairingMAC(macKey, "responder", transcript)})
+	if err != nil {
+		return err
+	}
+
+	cm.savePairedPeer(request.Device, request.Name, pairKey)
+	return nil
+}
+
+// pairWith pairs with a discovered device using the code it shows
+func (cm *ClipboardManager) pairWith(peer lanPeer, code string) error {
+	code = normalizePairingCode(code)
+	_, settings := cm.lanSnapshot()
+
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", peer.addr, lanTimeout)
+	if err != nil {
+		return fmt.Errorf("could not reach %s: %w", peer.name, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(lanTimeout))
+
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+
+	request := lanMessage{Type: "pair", Device: settings.DeviceID, Name: settings.Name, Key: private.PublicKey().Bytes()}
+	if err := encoder.Encode(request); err != nil {
+		return err
+	}
+
+	var response lanMessage
+	if err := decoder.Decode(&response); err != nil {
+		return err
+	}
+	if response.Type == "error" {
+		return fmt.Errorf("%s refused pairing: %s", peer.name, response.Error)
+	}
+	if response.Type != "pair" || response.Device != peer.id {
+		return errors.New("unexpected pairing response")
+	}
+
+	remote, err := ecdh.X25519().NewPublicKey(response.Key)
+	if err != nil {
+		return err
+	}
+	shared, err := private.ECDH(remote)
+	if err != nil {
+		return err
+	}
+	transcript := pairingTranscript(request, response)
+	macKey, pairKey, err := pairingKeys(code, shared, transcript)
+	if err != nil {
+		return err
+	}
+
+	if err := encoder.Encode(lanMessage{Type: "confirm", Device: settings.DeviceID, MAC: pairingMAC(macKey, "initiator", transcript)}); err != nil {
+		return err
+	}
+
+	var confirm lanMessage
+	if err := decoder.Decode(&confirm); err != nil {
+		return err
+	}
+	if confirm.Type == "error" {
+		return fmt.Errorf("pairing failed: %s", confirm.Error)
+	}
+	if confirm.Type != "confirm" || !hmac.Equal(confirm.MAC, pairingMAC(macKey, "responder", transcript)) {
+		return errors.New("pairing failed: device could not prove the code")
+	}
+
+	cm.savePairedPeer(response.Device, response.Name, pairKey)
+	return nil
+}
+
+// sendItem sends the history item with the given ID to a paired device
+func (cm *ClipboardManager) sendItem(peerID, id string) error {
+	item, ok := cm.itemByID(id)
+	if !ok {
+		return errors.New("the item was removed")
+	}
+	return cm.sendLANItem(peerID, item)
+}
+
+// sendLANItem sends an item to a paired device
+func (cm *ClipboardManager) sendLANItem(peerID string, item core.Item) error {
+	_, settings := cm.lanSnapshot()
+	paired, key, ok := cm.pairedPeer(peerID)
+	if !ok {
+		return errors.New("device is not paired")
+	}
+
+	var addr string
+	for _, peer := range cm.lanPeers() {
+		if peer.id == peerID {
+			addr = peer.addr
+		}
+	}
+	if addr == "" {
+		return fmt.Errorf("%s is not on the network", paired.Name)
+	}
+
+	msg, err := newItemMessage(settings.DeviceID, peerID, key, time.Now(), item)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, lanTimeout)
+	if err != nil {
+		return fmt.Errorf("could not reach %s: %w", paired.Name, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(lanTimeout))
+
+	if err := json.NewEncoder(conn).Encode(msg); err != nil {
+		return err
+	}
+
+	var reply lanMessage
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil {
+		return err
+	}
+	if reply.Type != "ok" {
+		return fmt.Errorf("%s rejected the item: %s", paired.Name, reply.Error)
+	}
+	return nil
+}
+
+// newItemMessage seals an item for a paired device
+func newItemMessage(from, to string, key []byte, sent time.Time, item core.Item) (lanMessage, error) {
+	payload, err := json.Marshal(lanPayload{
+		Sent: sent,
+		To:   to,
+		Item: core.Item{Content: item.Content, Type: item.Type, Tags: item.Tags},
+	})
+	if err != nil {
+		return lanMessage{}, err
+	}
+	nonce, data, err := sealData(key, payload)
+	if err != nil {
+		return lanMessage{}, err
+	}
+	return lanMessage{Type: "item", Device: from, Nonce: nonce, Data: data}, nil
+}
+
+// receiveItem adds an item sent by a paired device to the history
+func (cm *ClipboardManager) receiveItem(msg lanMessage) error {
+	_, settings := cm.lanSnapshot()
+	_, key, ok := cm.pairedPeer(msg.Device)
+	if !ok {
+		return errors.New("device is not paired")
+	}
+
+	plaintext, err := openData(key, msg.Nonce, msg.Data)
+	if err != nil {
+		return errors.New("could not decrypt item")
+	}
+
+	var payload lanPayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return err
+	}
+	if payload.To != settings.DeviceID {
+		return errors.New("item is meant for another device")
+	}
+	if age := time.Since(payload.Sent); age > lanMaxMessageAge || age < -lanMaxMessageAge {
+		return errors.New("item is too old")
+	}
+	// The nonce is authenticated with the item, so it identifies the message
+	if !cm.lanNonces.add(msg.Device, msg.Nonce, payload.Sent) {
+		return errors.New("item was already received")
+	}
+
+	// insertItem rather than addItem, so mirrored items aren't sent back
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if !cm.insertItem(payload.Item.Content) {
+		return nil
+	}
+
+	// The sender's tags are added to the ones the item may already have here
+	tags := cm.history.At(0).Tags
+	for _, tag := range payload.Item.Tags {
+		if !slices.Contains(tags, tag) {
+			tags = append(slices.Clip(tags), tag)
+		}
+	}
+	if cm.history.SetTags(0, tags) {
+		cm.historyChanged()
+	}
+	return nil
+}
+
+// mirrorNewItem sends a new item to all paired devices if mirroring is on
+func (cm *ClipboardManager) mirrorNewItem(item core.Item) {
+	cm.mu.Lock()
+	mirror := cm.lanState != nil && cm.lan.AutoMirror && !cm.locked
+	peers := cm.lan.Peers
+	cm.mu.Unlock()
+	if !mirror {
+		return
+	}
+
+	for _, peer := range peers {
+		go func() {
+			if err := cm.sendLANItem(peer.ID, item); err != nil {
+				slog.Warn("could not mirror item", "device", peer.Name, "err", err)
+			}
+		}()
+	}
+}
+
+// lanMenuItems returns the row menu entries sending an item to paired devices
+func (cm *ClipboardManager) lanMenuItems(id string) []*fyne.MenuItem {
+	state, settings := cm.lanSnapshot()
+	if state == nil {
+		return nil
+	}
+
+	var items []*fyne.MenuItem
+	for _, peer := range settings.Peers {
+		items = append(items, fyne.NewMenuItem("Send to "+peer.Name, func() {
+			go func() {
+				if err := cm.sendItem(peer.ID, id); err != nil {
+					cm.showError(err, cm.window)
+				}
+			}()
+		}))
+	}
+	return items
+}
+
+// createLANSettings builds the LAN sharing section of the settings window
+func (cm *ClipboardManager) createLANSettings(settingsWindow fyne.Window) fyne.CanvasObject {
+	peerList := container.NewVBox()
+	var refreshPeers func()
+	_, current := cm.lanSnapshot()
+
+	enableToggle := widget.NewCheck("Share with devices on the local network", nil)
+	enableToggle.SetChecked(current.Enabled)
+	enableToggle.OnChanged = func(checked bool) {
+		_, settings := cm.lanSnapshot()
+		settings.Enabled = checked
+		cm.setLAN(settings)
+		refreshPeers()
+	}
+
+	mirrorToggle := widget.NewCheck("Send new clipboard items to paired devices", nil)
+	mirrorToggle.SetChecked(current.AutoMirror)
+	mirrorToggle.OnChanged = func(checked bool) {
+		_, settings := cm.lanSnapshot()
+		settings.AutoMirror = checked
+		cm.setLAN(settings)
+	}
+
+	pairButton := widget.NewButton("Pair a device…", func() {
+		code, err := cm.startPairing()
+		if err != nil {
+			dialog.ShowError(err, settingsWindow)
+			return
+		}
+		_, settings := cm.lanSnapshot()
+		dialog.ShowInformation("Pair a device",
+			fmt.Sprintf("On the other device, choose %s and enter this code:\n\n%s\n\nThe code is valid for %d minutes.",
+				settings.Name, formatPairingCode(code), int(pairingCodeExpiry.Minutes())),
+			settingsWindow)
+	})
+
+	refreshPeers = func() {
+		peerList.RemoveAll()
+		state, settings := cm.lanSnapshot()
+		if state == nil {
+			return
+		}
+
+		for _, peer := range settings.Peers {
+			forget := widget.NewButton("Forget", func() {
+				cm.forgetPeer(peer.ID)
+				refreshPeers()
+			})
+			peerList.Add(container.NewBorder(nil, nil, nil, forget, widget.NewLabel(peer.Name+" (paired)")))
+		}
+
+		for _, peer := range cm.lanPeers() {
+			if _, _, paired := cm.pairedPeer(peer.id); paired {
+				continue
+			}
+			pair := widget.NewButton("Pair…", func() {
+				codeEntry := widget.NewEntry()
+				codeEntry.SetPlaceHolder("XXXX-XXXX")
+				dialog.ShowForm("Pair with "+peer.name, "Pair", "Cancel",
+					[]*widget.FormItem{widget.NewFormItem("Code", codeEntry)},
+					func(confirmed bool) {
+						if !confirmed {
+							return
+						}
+						go func() {
+							if err := cm.pairWith(peer, codeEntry.Text); err != nil {
+								cm.showError(err, settingsWindow)
+								return
+							}
+							cm.runUI(refreshPeers)
+							cm.showInformation("Paired", "Paired with "+peer.name, settingsWindow)
+						}()
+					}, settingsWindow)
+			})
+			peerList.Add(container.NewBorder(nil, nil, nil, pair, widget.NewLabel(peer.name)))
+		}
+	}
+	refreshPeers()
+
+	return container.NewVBox(
+		enableToggle,
+		mirrorToggle,
+		container.NewHBox(pairButton, widget.NewButton("Refresh", refreshPeers)),
+		peerList,
+	)
+}
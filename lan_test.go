@@ -0,0 +1,194 @@
+package main
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"NoteBoard/core"
+)
+
+// startTestLAN runs the LAN service of a test manager on the loopback interface,
+// without discovery
+func startTestLAN(t *testing.T, cm *ClipboardManager, id string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	cm.lan = LANSettings{Enabled: true, DeviceID: id, Name: id}
+	cm.lanState = &lanState{
+		listener: listener,
+		peers:    make(map[string]lanPeer),
+		self:     mdnsService{id: id, name: id, port: listener.Addr().(*net.TCPAddr).Port},
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go cm.handleLANConn(conn)
+		}
+	}()
+}
+
+// testPeer returns the other manager as a discovered device and makes cm see it
+func testPeer(cm, other *ClipboardManager) lanPeer {
+	state, settings := other.lanSnapshot()
+	peer := lanPeer{
+		id:   settings.DeviceID,
+		name: settings.Name,
+		addr: state.listener.Addr().String(),
+		seen: time.Now(),
+	}
+	cm.lanState.mu.Lock()
+	cm.lanState.peers[peer.id] = peer
+	cm.lanState.mu.Unlock()
+	return peer
+}
+
+// pairTestManagers returns two managers paired with each other
+func pairTestManagers(t *testing.T) (a, b *ClipboardManager) {
+	t.Helper()
+	a, b = newTestManager(t), newTestManager(t)
+	startTestLAN(t, a, "device-a")
+	startTestLAN(t, b, "device-b")
+
+	code, err := b.startPairing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.pairWith(testPeer(a, b), strings.ToLower(formatPairingCode(code))); err != nil {
+		t.Fatal(err)
+	}
+	testPeer(b, a)
+
+	// b saves the pairing after confirming it
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, _, ok := b.pairedPeer("device-a"); ok {
+			return a, b
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("device-b didn't save the pairing")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLANPairing(t *testing.T) {
+	a, b := pairTestManagers(t)
+
+	_, keyA, okA := a.pairedPeer("device-b")
+	_, keyB, okB := b.pairedPeer("device-a")
+	if !okA || !okB || string(keyA) != string(keyB) {
+		t.Fatalf("devices aren't paired with the same key: %v %v", okA, okB)
+	}
+
+	// A wrong code fails on both sides and uses up the code
+	code, err := b.startPairing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := []byte(code)
+	wrong[0] = pairingAlphabet[(strings.IndexByte(pairingAlphabet, wrong[0])+1)%len(pairingAlphabet)]
+	if err := a.pairWith(testPeer(a, b), string(wrong)); err == nil {
+		t.Error("pairing with a wrong code succeeded")
+	}
+	if err := a.pairWith(testPeer(a, b), code); err == nil {
+		t.Error("pairing code could be used twice")
+	}
+	if _, key, _ := a.pairedPeer("device-b"); string(key) != string(keyA) {
+		t.Error("failed pairing replaced the key")
+	}
+}
+
+func TestLANItemAuth(t *testing.T) {
+	a, b := pairTestManagers(t)
+	_, key, _ := a.pairedPeer("device-b")
+	item := core.Item{Content: "hello", Type: "text", Tags: []string{"work"}}
+
+	if err := a.sendLANItem("device-b", item); err != nil {
+		t.Fatal(err)
+	}
+	b.mu.Lock()
+	got := b.history.At(0)
+	b.mu.Unlock()
+	if got.Content != "hello" || !slices.Equal(got.Tags, item.Tags) {
+		t.Fatalf("received %q with tags %q", got.Content, got.Tags)
+	}
+
+	msg, err := newItemMessage("device-a", "device-b", key, time.Now(), core.Item{Content: "again"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.receiveItem(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.receiveItem(msg); err == nil {
+		t.Error("replayed item was accepted")
+	}
+
+	rejected := map[string]func() (lanMessage, error){
+		"too old": func() (lanMessage, error) {
+			return newItemMessage("device-a", "device-b", key, time.Now().Add(-2*lanMaxMessageAge), item)
+		},
+		"for another device": func() (lanMessage, error) {
+			return newItemMessage("device-a", "device-a", key, time.Now(), item)
+		},
+		"unpaired device": func() (lanMessage, error) {
+			return newItemMessage("device-c", "device-b", key, time.Now(), item)
+		},
+		"wrong key": func() (lanMessage, error) {
+			return newItemMessage("device-a", "device-b", make([]byte, encryptionKeyLen), time.Now(), item)
+		},
+		"tampered": func() (lanMessage, error) {
+			msg, err := newItemMessage("device-a", "device-b", key, time.Now(), item)
+			msg.Data[0] ^= 1
+			return msg, err
+		},
+	}
+	for name, build := range rejected {
+		msg, err := build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.receiveItem(msg); err == nil {
+			t.Errorf("%s item was accepted", name)
+		}
+	}
+	waitForItems(t, b, 2)
+}
+
+func TestMDNSRoundTrip(t *testing.T) {
+	query, err := buildMDNSQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isQuery, services := parseMDNSMessage(query); !isQuery || services != nil {
+		t.Errorf("query parsed as %v, %v", isQuery, services)
+	}
+
+	for _, service := range []mdnsService{
+		{id: "abc123", name: "Laptop", port: 4321, ttl: mdnsTTL},
+		{id: "abc123", name: "Laptop", port: 4321, ttl: 0}, // Goodbye
+	} {
+		data, err := buildMDNSAnnouncement(service)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isQuery, services := parseMDNSMessage(data)
+		if isQuery || len(services) != 1 || services[0] != service {
+			t.Errorf("announcement of %+v parsed as %v, %+v", service, isQuery, services)
+		}
+	}
+
+	if isQuery, services := parseMDNSMessage([]byte("garbage")); isQuery || services != nil {
+		t.Errorf("garbage parsed as %v, %v", isQuery, services)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	logFileName = "noteboard.log"
+
+	// The log file is rotated when it exceeds maxLogSize, keeping maxLogFiles old files
+	maxLogSize  = 1 << 20
+	maxLogFiles = 3
+
+	// Lines shown in the log view
+	logViewLines = 500
+)
+
+// logLevel is Info by default and Debug with --verbose
+var logLevel = new(slog.LevelVar)
+
+// getLogPath returns the path of the current log file
+func getLogPath() string {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return logFileName
+	}
+	return filepath.Join(configDir, logFileName)
+}
+
+// setupLogging sends log records to stderr and, if logToFile is set, to the
+// rotating log file in the config directory
+func setupLogging(verbose, logToFile bool) {
+	if verbose {
+		logLevel.Set(slog.LevelDebug)
+	}
+
+	var out io.Writer = os.Stderr
+	if logToFile {
+		file, err := openRotatingFile(getLogPath(), maxLogSize, maxLogFiles)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not open log file: %v\n", err)
+		} else {
+			out = io.MultiWriter(os.Stderr, file)
+		}
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: logLevel})))
+}
+
+// parseFlags removes the global flags from the command line arguments
+func parseFlags(args []string) (rest []string, verbose bool) {
+	for _, arg := range args {
+		switch arg {
+		case "-v", "--verbose":
+			verbose = true
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, verbose
+}
+
+// rotatingFile is a log file that is renamed to path.1, path.2, … when it grows too large
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	keep    int
+	file    *os.File
+	size    int64
+}
+
+// openRotatingFile opens or creates a log file for appending
+func openRotatingFile(path string, maxSize int64, keep int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxSize: maxSize, keep: keep}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends p, rotating the file first if p would make it too large
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the old files up by one, dropping the oldest, and starts a new file
+func (f *rotatingFile) rotate() error {
+	f.file.Close()
+	for i := f.keep - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	os.Rename(f.path, f.path+".1")
+	return f.open()
+}
+
+// readLogTail returns the last lines of the log, continuing into the previous
+// file if the current one was just rotated
+func readLogTail(lines int) (string, error) {
+	path := getLogPath()
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	previous, _ := os.ReadFile(path + ".1")
+
+	all := strings.Split(strings.TrimRight(string(previous)+string(current), "\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n"), nil
+}
+
+// runCommand runs an external program and logs a failure with the program's output.
+// Most callers can't do anything about a failure, but the log shows what went wrong.
+func runCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		slog.Warn("command failed", "cmd", cmd.String(), "err", err, "output", strings.TrimSpace(string(output)))
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	slog.Debug("ran command", "cmd", cmd.String())
+	return nil
+}
+
+// createLogSettings creates the button opening the log view
+func (cm *ClipboardManager) createLogSettings() fyne.CanvasObject {
+	return widget.NewButtonWithIcon("Show logs", theme.DocumentIcon(), func() {
+		cm.showLogs()
+	})
+}
+
+// showLogs opens a window with the end of the log file, e.g. to attach to a bug report
+func (cm *ClipboardManager) showLogs() {
+	logWindow := fyne.CurrentApp().NewWindow("NoteBoard Logs")
+	logWindow.Resize(fyne.NewSize(700, 500))
+
+	text := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+	text.Wrapping = fyne.TextWrapBreak
+	scroll := container.NewScroll(text)
+
+	load := func() {
+		logs, err := readLogTail(logViewLines)
+		if err != nil {
+			logs = fmt.Sprintf("Could not read %s: %v", getLogPath(), err)
+		}
+		text.SetText(logs)
+		scroll.ScrollToBottom()
+	}
+	load()
+
+	buttons := container.NewHBox(
+		widget.NewButtonWithIcon("Refresh", theme.ViewRefreshIcon(), load),
+		widget.NewButtonWithIcon("Copy", theme.ContentCopyIcon(), func() {
+			logWindow.Clipboard().SetContent(text.Text)
+		}),
+		widget.NewButtonWithIcon("Open folder", theme.FolderOpenIcon(), func() {
+			if err := exec.Command("xdg-open", filepath.Dir(getLogPath())).Start(); err != nil {
+				dialog.ShowError(err, logWindow)
+			}
+		}),
+	)
+
+	logWindow.SetContent(container.NewBorder(nil, container.NewVBox(widget.NewLabel(getLogPath()), buttons), nil, nil, scroll))
+	logWindow.Show()
+}
@@ -6,22 +6,28 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"image/color"
+	"log/slog"
 	"net"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"NoteBoard/core"
+	"NoteBoard/kwin"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	desktop "fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
-	"github.com/go-vgo/robotgo"
+	"github.com/fsnotify/fsnotify"
 	hook "github.com/robotn/gohook"
 )
 
@@ -31,42 +37,82 @@ const (
 	appID             = "io.github.ekats.noteboard"
 	appName           = "NoteBoard"
 	socketName        = "noteboard.sock"
-)
 
-// ClipboardItem represents a single item in the clipboard history
-type ClipboardItem struct {
-	content   string
-	timestamp time.Time
-	itemType  string // "text", "image", etc.
-}
+	// Largest item read from other devices or the HTTP API, and the longest line
+	// of a sync log
+	maxItemSize = 16 << 20
+)
 
 // ClipboardManager manages clipboard history and UI interactions
 type ClipboardManager struct {
-	items          []ClipboardItem
+	history        *core.History
+	clipboard      core.ClipboardBackend
+	clipboardStop  chan struct{} // Closed to stop watching the clipboard
+	secretContent  string        // Copied by copySecret, kept out of the history
 	window         fyne.Window
 	list           *widget.List
 	clearButton    *widget.Button
-	pinned         map[int]bool
 	hotkeySettings HotkeySettings
 	configPath     string
 	isWayland      bool
-}
-
-// CustomTooltip is a widget that shows content in a pop-up window when activated
-type CustomTooltip struct {
-	widget.DisableableWidget
-	content     string
-	popupWindow fyne.Window
-	parent      fyne.Window
-	showDelay   *time.Timer
-	hideDelay   *time.Timer
-}
-
-// tooltipRenderer handles the layout of the tooltip button
-type tooltipRenderer struct {
-	tooltip *CustomTooltip
-	text    *widget.Label
-	objects []fyne.CanvasObject
+	actions        []ExternalAction
+	rules          []compiledRule
+	hooks          []HookScript
+
+	saveHistoryEnabled bool
+	copyAfterEdit      bool // Copy items to the clipboard after editing them
+
+	// Encrypted history state
+	encryption   EncryptionSettings
+	locked       bool
+	unlocking    bool
+	historyKey   []byte
+	historySalt  []byte
+	lastActivity time.Time
+	lockedView   *fyne.Container
+
+	// List filtering by search text and tags
+	filtered   []int // Indices of the items shown in the list
+	searchText string
+	tagFilter  map[string]bool
+	tagBar     *fyne.Container
+
+	// Sync through a shared folder
+	sync        SyncSettings
+	syncKey     []byte               // Encrypts the sync logs while history encryption is on
+	syncState   map[string]core.Item // Items as last written to or merged from the sync logs
+	syncDeleted map[string]bool      // Items deleted by the user since the last sync write
+	syncWatcher *fsnotify.Watcher
+
+	// Sharing with paired devices on the local network
+	lan       LANSettings
+	lanState  *lanState
+	lanNonces lanNonces // Outlives lanState, so restarting the service doesn't allow replays
+
+	// Local HTTP API
+	api       APISettings
+	apiServer *apiServer
+
+	// Overlay picker on wlroots compositors
+	overlay     OverlaySettings
+	overlayOpen atomic.Bool
+
+	// Full view of an item below the list, see preview.go
+	preview *previewPane
+
+	// Rows selected for merging, see merge.go
+	selection selectionState
+
+	// Where the global shortcut shows the main window
+	placement PlacementSettings
+
+	// Looked up files of file list items, see files.go
+	fileStats fileStatCache
+
+	// Concurrency, see threading.go
+	mu             sync.Mutex
+	ui             func(func()) // fyne.Do, replaced in tests
+	refreshPending atomic.Bool
 }
 
 // HotkeySettings stores user-configured keyboard shortcuts
@@ -78,26 +124,53 @@ type HotkeySettings struct {
 
 // Config structure for persistent settings
 type Config struct {
-	Hotkeys HotkeySettings `json:"hotkeys"`
+	Hotkeys HotkeySettings   `json:"hotkeys"`
+	Actions []ExternalAction `json:"actions"` // User-defined transformations
+	Rules   []ContentRule    `json:"rules"`   // Reactions to new clipboard content
+	Hooks   []HookScript     `json:"hooks"`   // Scripts run on history events
+
+	Clipboard     string `json:"clipboard"`     // Clipboard backend, see clipboard.go
+	CopyAfterEdit bool   `json:"copyAfterEdit"` // Copy items to the clipboard after editing them
+
+	SaveHistory bool               `json:"saveHistory"` // Persist history across restarts
+	Encryption  EncryptionSettings `json:"encryption"`  // Encrypt the saved history
+	Sync        SyncSettings       `json:"sync"`        // Share the history between devices
+	LAN         LANSettings        `json:"lan"`         // Send items to paired devices nearby
+	API         APISettings        `json:"api"`         // Local HTTP API for integrations
+	Overlay     OverlaySettings    `json:"overlay"`     // Picker overlay on wlroots compositors
+	Placement   PlacementSettings  `json:"placement"`   // Where the main window is shown
 }
 
-// getConfigPath returns the path to the config file
-func getConfigPath() string {
+// getConfigDir returns the directory holding the config, history and runtime files.
+// NOTEBOARD_CONFIG_DIR overrides it, e.g. to run a second instance for testing.
+func getConfigDir() (string, error) {
+	if dir := os.Getenv("NOTEBOARD_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+
 	// Get user's home directory
 	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".config", "clipboard-manager"), nil
+}
+
+// getConfigPath returns the path to the config file
+func getConfigPath() string {
+	configDir, err := getConfigDir()
 	if err != nil {
 		// Fallback to current directory if home is not available
 		return configFileName
 	}
 
-	// Create a .config/clipboard-manager directory
-	configDir := filepath.Join(homeDir, ".config", "clipboard-manager")
-
 	// Create the directory if it doesn't exist
 	if _, err := os.Stat(configDir); os.IsNotExist(err) {
 		err := os.MkdirAll(configDir, 0755)
 		if err != nil {
 			// Fallback to home directory if can't create the config dir
+			homeDir, _ := os.UserHomeDir()
 			return filepath.Join(homeDir, configFileName)
 		}
 	}
@@ -116,6 +189,10 @@ func loadConfig() Config {
 			ModifierKey: "ctrl+alt",
 			ActionKey:   "v",
 		},
+		Clipboard:   clipboardAuto,
+		SaveHistory: false, // Saved history holds whatever was copied, so it is opt-in
+		Overlay:     OverlaySettings{Mode: overlayAuto, Anchor: anchorCenter},
+		Placement:   PlacementSettings{Mode: placeAtCursor},
 	}
 
 	// Check if config file exists
@@ -129,15 +206,15 @@ func loadConfig() Config {
 	// Read config file
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		fmt.Printf("Warning: Could not read config file, using defaults: %v\n", err)
+		slog.Warn("could not read config file, using defaults", "err", err)
 		return defaultConfig
 	}
 
-	// Parse config
-	var config Config
+	// Parse config, keeping defaults for settings missing from the file
+	config := defaultConfig
 	err = json.Unmarshal(data, &config)
 	if err != nil {
-		fmt.Printf("Warning: Could not parse config file, using defaults: %v\n", err)
+		slog.Warn("could not parse config file, using defaults", "err", err)
 		return defaultConfig
 	}
 
@@ -154,8 +231,11 @@ func saveConfig(config Config) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	// Write to file
-	return os.WriteFile(configPath, data, 0644)
+	// Write to file, private to the user as it holds the LAN pairing keys
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(configPath, 0600)
 }
 
 // isWaylandSession detects if running on Wayland
@@ -163,24 +243,32 @@ func isWaylandSession() bool {
 	return os.Getenv("XDG_SESSION_TYPE") == "wayland"
 }
 
-func ensureSingleInstance() bool {
-	// Get user's home directory for socket path
-	homeDir, err := os.UserHomeDir()
+// getSocketPath returns the path of the control socket, creating the runtime directory if needed
+func getSocketPath() (string, error) {
+	configDir, err := getConfigDir()
 	if err != nil {
-		fmt.Printf("Warning: Could not get home directory: %v\n", err)
-		return false
+		return "", fmt.Errorf("could not get home directory: %w", err)
 	}
 
 	// Create runtime directory if it doesn't exist
-	runtimeDir := filepath.Join(homeDir, ".config", "clipboard-manager", "runtime")
+	runtimeDir := filepath.Join(configDir, "runtime")
 	if _, err := os.Stat(runtimeDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(runtimeDir, 0755); err != nil {
-			fmt.Printf("Warning: Could not create runtime directory: %v\n", err)
-			return false
+			return "", fmt.Errorf("could not create runtime directory: %w", err)
 		}
 	}
 
-	socketPath := filepath.Join(runtimeDir, socketName)
+	return filepath.Join(runtimeDir, socketName), nil
+}
+
+// ensureSingleInstance claims the control socket. It returns true if another
+// instance is already running, otherwise the listener the caller should serve.
+func ensureSingleInstance() (net.Listener, bool) {
+	socketPath, err := getSocketPath()
+	if err != nil {
+		slog.Warn("could not get control socket", "err", err)
+		return nil, false
+	}
 
 	// Remove socket if it exists but process is not running
 	if _, err := os.Stat(socketPath); err == nil {
@@ -189,8 +277,8 @@ func ensureSingleInstance() bool {
 		if err == nil {
 			// If connection succeeds, another instance is running
 			conn.Close()
-			fmt.Println("Another instance is already running. Exiting.")
-			return true
+			slog.Info("another instance is already running, exiting")
+			return nil, true
 		}
 
 		// If connection fails, remove the stale socket
@@ -200,23 +288,11 @@ func ensureSingleInstance() bool {
 	// Create and listen on the socket
 	listener, err := net.Listen("unix", socketPath)
 	if err != nil {
-		fmt.Printf("Warning: Could not create socket: %v\n", err)
-		return false
+		slog.Warn("could not create socket", "err", err)
+		return nil, false
 	}
 
-	// Start a goroutine to accept connections
-	go func() {
-		for {
-			conn, err := listener.Accept()
-			if err != nil {
-				continue
-			}
-			// Just close the connection, we're just detecting other instances
-			conn.Close()
-		}
-	}()
-
-	return false
+	return listener, false
 }
 
 // createDesktopFile creates a .desktop file for autostart
@@ -264,10 +340,10 @@ func setupKDEGlobalShortcut(cm *ClipboardManager) error {
 		return nil // Only needed for Wayland
 	}
 
-	// Check if kwriteconfig5 is available (for KDE)
-	_, err := exec.LookPath("kwriteconfig5")
-	if err != nil {
-		return fmt.Errorf("kwriteconfig5 not found, cannot set KDE shortcuts")
+	// Check if kwriteconfig is available (for KDE)
+	kde := getKDETools()
+	if !hasCommand(kde.kwriteconfig) {
+		return fmt.Errorf("%s not found, cannot set KDE shortcuts", kde.kwriteconfig)
 	}
 
 	// Format the hotkey for KDE
@@ -298,13 +374,8 @@ func setupKDEGlobalShortcut(cm *ClipboardManager) error {
 
 	// This is a simplified example that might need to be expanded
 	shortcutGroup := "manjaro-clipboard"
-	cmdShowHide := exec.Command("kwriteconfig5",
-		"--file", "kglobalshortcutsrc",
-		"--group", shortcutGroup,
-		"--key", "show_clipboard",
+	return kde.writeConfig("kglobalshortcutsrc", shortcutGroup, "show_clipboard",
 		kdeShortcut+",none,Show Clipboard Manager")
-
-	return cmdShowHide.Run()
 }
 
 // newClipboardManager creates a new clipboard manager instance
@@ -316,16 +387,41 @@ func newClipboardManager(w fyne.Window) *ClipboardManager {
 	isWayland := isWaylandSession()
 
 	cm := &ClipboardManager{
-		items:          make([]ClipboardItem, 0, maxClipboardItems),
+		history:        core.NewHistory(maxClipboardItems),
+		clipboard:      newClipboardBackend(config.Clipboard, isWayland),
 		window:         w,
-		pinned:         make(map[int]bool),
 		hotkeySettings: config.Hotkeys, // Use loaded hotkey settings
 		configPath:     getConfigPath(),
 		isWayland:      isWayland,
+		actions:        config.Actions,
+		rules:          compileRules(config.Rules),
+		hooks:          config.Hooks,
+
+		saveHistoryEnabled: config.SaveHistory,
+		copyAfterEdit:      config.CopyAfterEdit,
+		encryption:         config.Encryption,
+		tagFilter:          make(map[string]bool),
+		sync:               config.Sync,
+		lan:                config.LAN,
+		api:                config.API,
+		overlay:            config.Overlay,
+		placement:          config.Placement,
+		ui:                 fyne.Do,
 	}
 
 	cm.list = cm.createItemList()
 
+	// Restore the saved history
+	cm.mu.Lock()
+	if cm.saveHistoryEnabled {
+		cm.loadHistory()
+	}
+	cm.updateFilter()
+	cm.startSync()
+	cm.startAPI()
+	cm.startLAN()
+	cm.mu.Unlock()
+
 	cm.clearButton = widget.NewButton("Clear All", func() {
 		cm.clearItems()
 	})
@@ -334,318 +430,51 @@ func newClipboardManager(w fyne.Window) *ClipboardManager {
 	if cm.isWayland {
 		err := setupKDEGlobalShortcut(cm)
 		if err != nil {
-			fmt.Printf("Warning: Failed to set up KDE global shortcut: %v\n", err)
+			slog.Warn("could not set up KDE global shortcut", "err", err)
 		}
 	}
 
 	return cm
 }
 
-// addItem adds an item to the clipboard history
+// addItem adds an item to the clipboard history and runs the matching content rules
 func (cm *ClipboardManager) addItem(content string) {
-	// Skip if content is empty or the same as the most recent item
-	if content == "" || (len(cm.items) > 0 && content == cm.items[0].content) {
-		return
-	}
-
-	// Create new item
-	newItem := ClipboardItem{
-		content:   content,
-		timestamp: time.Now(),
-		itemType:  "text",
-	}
-
-	// Remove duplicate if exists elsewhere in the list
-	for i, item := range cm.items {
-		if item.content == content {
-			cm.removeItem(i)
-			break
-		}
-	}
-
-	// Add at the beginning
-	cm.items = append([]ClipboardItem{newItem}, cm.items...)
-
-	// Trim if exceeds max
-	if len(cm.items) > maxClipboardItems {
-		cm.items = cm.items[:maxClipboardItems]
-	}
-
-	// Refresh the list
-	cm.list.Refresh()
-}
-
-// NewCustomTooltip creates a new custom tooltip for showing text content
-func NewCustomTooltip(content string, parent fyne.Window) *CustomTooltip {
-	tooltip := &CustomTooltip{
-		content: content,
-		parent:  parent,
-	}
-	tooltip.ExtendBaseWidget(tooltip)
-	return tooltip
-}
-
-// CreateRenderer is a private method to Fyne which defines how this widget is rendered
-func (t *CustomTooltip) CreateRenderer() fyne.WidgetRenderer {
-	text := widget.NewLabel("...")
-	text.Alignment = fyne.TextAlignCenter
-
-	return &tooltipRenderer{
-		tooltip: t,
-		text:    text,
-		objects: []fyne.CanvasObject{text},
-	}
-}
-
-func (r *tooltipRenderer) MinSize() fyne.Size {
-	return r.text.MinSize()
-}
-
-func (r *tooltipRenderer) Layout(size fyne.Size) {
-	r.text.Resize(size)
-}
-
-func (r *tooltipRenderer) Refresh() {
-	r.text.Refresh()
-}
-
-func (r *tooltipRenderer) Objects() []fyne.CanvasObject {
-	return r.objects
-}
-
-func (r *tooltipRenderer) Destroy() {}
-
-// MouseIn is called when the mouse enters the tooltip area
-func (t *CustomTooltip) MouseIn(event *desktop.MouseEvent) {
-	// Cancel any pending hide timer
-	if t.hideDelay != nil {
-		t.hideDelay.Stop()
-		t.hideDelay = nil
-	}
-
-	// Start show timer (300ms delay before showing)
-	t.showDelay = time.AfterFunc(300*time.Millisecond, func() {
-		t.showContent()
-	})
-}
-
-// MouseOut is called when the mouse leaves the tooltip area
-func (t *CustomTooltip) MouseOut() {
-	// Cancel any pending show timer
-	if t.showDelay != nil {
-		t.showDelay.Stop()
-		t.showDelay = nil
-	}
-
-	// Start hide timer (200ms delay before hiding)
-	t.hideDelay = time.AfterFunc(200*time.Millisecond, func() {
-		t.hideContent()
-	})
-}
-
-// MouseMoved is called when the mouse moves within the tooltip area
-func (t *CustomTooltip) MouseMoved(*desktop.MouseEvent) {}
-
-// showContent displays the tooltip content
-func (t *CustomTooltip) showContent() {
-	// If there's already a popup open, close it
-	if t.popupWindow != nil {
-		t.popupWindow.Close()
-		t.popupWindow = nil
-	}
-
-	// Create the popup window
-	app := fyne.CurrentApp()
-	t.popupWindow = app.NewWindow("Content")
-	t.popupWindow.SetFixedSize(true)
-
-	// Set window type hint if available
-	if typeHint, ok := t.popupWindow.(interface{ SetDialogType() }); ok {
-		typeHint.SetDialogType()
-	}
-
-	// Create content
-	textDisplay := widget.NewLabel(t.content)
-	textDisplay.Wrapping = fyne.TextWrapWord
-
-	// Create scrollable container
-	scrollContainer := container.NewScroll(textDisplay)
-	scrollContainer.Resize(fyne.NewSize(400, 300))
-
-	t.popupWindow.SetContent(scrollContainer)
-
-	// Position the window near the cursor for better UX
-	// This works on both X11 and Wayland
-	curX, curY := robotgo.Location()
-
-	// Check if we're on Wayland
-	isWayland := os.Getenv("XDG_SESSION_TYPE") == "wayland"
-
-	if isWayland {
-		// For Wayland, we'll use a different approach
-		// First resize the window
-		t.popupWindow.Resize(fyne.NewSize(400, 300))
-
-		// Then force XWayland usage for this window if possible
-		// Set the env var for XWayland before window is mapped
-		if setter, ok := t.popupWindow.(interface{ SetEnv(string, string) }); ok {
-			setter.SetEnv("GDK_BACKEND", "x11")
-		}
-
-		// Use xdg-decoration protocol to remove decorations if available
-		if setter, ok := t.popupWindow.(interface{ SetDecoration(bool) }); ok {
-			setter.SetDecoration(false)
-		}
-
-		// For KDE on Wayland, we can try to set a window rule
-		if isKDEPlasma() {
-			// Create a temporary unique identifier for this window
-			uniqueID := fmt.Sprintf("tooltip-%d", time.Now().UnixNano())
-
-			// Try to set window role to get a consistent identifier
-			if roleSetter, ok := t.popupWindow.(interface{ SetRole(string) }); ok {
-				roleSetter.SetRole(uniqueID)
-			}
-
-			// Delay execution to ensure window is created
-			go func() {
-				time.Sleep(100 * time.Millisecond)
-
-				// Try to position with KWin DBus API
-				exec.Command("qdbus", "org.kde.KWin", "/KWin",
-					"org.kde.KWin.setWindowGeometry", uniqueID,
-					strconv.Itoa(curX+20), strconv.Itoa(curY+20),
-					"400", "300").Run()
-			}()
-		}
-	} else {
-		// For X11, use the standard approach
-		// Position window near cursor directly without needing parent position
-		if mover, ok := t.popupWindow.(interface{ SetPosition(x, y int) }); ok {
-			// Set position to near mouse cursor
-			mover.SetPosition(curX+20, curY+20)
-		}
+	cm.mu.Lock()
+	inserted := cm.insertItem(content)
+	var item core.Item
+	if inserted {
+		item = cm.history.At(0)
 	}
+	cm.mu.Unlock()
 
-	// Make it an overlay window (no decorations)
-	if setter, ok := t.popupWindow.(interface{ SetDecoration(bool) }); ok {
-		setter.SetDecoration(false)
+	if inserted {
+		cm.mirrorNewItem(item)
+		cm.applyAutoRules(content)
 	}
-
-	// Make it stay on top of other windows
-	if setter, ok := t.popupWindow.(interface{ SetOnTop(bool) }); ok {
-		setter.SetOnTop(true)
-	}
-
-	// For better Wayland support, try multiple methods
-	go func() {
-		// Wait a bit for window to be mapped
-		time.Sleep(100 * time.Millisecond)
-
-		if isWayland {
-			// Try to use wl-shell-surface protocol if available
-			runWaylandPositioningCommands(t.popupWindow, curX+20, curY+20)
-		} else {
-			// For X11, use xprop
-			// Try to find our window ID
-			cmd := exec.Command("xdotool", "search", "--name", "Content")
-			output, err := cmd.Output()
-			if err == nil && len(output) > 0 {
-				// Get the first window ID
-				lines := strings.Split(string(output), "\n")
-				if len(lines) > 0 {
-					windowID := strings.TrimSpace(lines[0])
-					if windowID != "" {
-						// Set the window type to tooltip or notification
-						exec.Command("xprop", "-id", windowID, "-f", "_NET_WM_WINDOW_TYPE", "32a",
-							"-set", "_NET_WM_WINDOW_TYPE", "_NET_WM_WINDOW_TYPE_NOTIFICATION").Run()
-
-						// Also set the window to always stay on top
-						exec.Command("xprop", "-id", windowID, "-f", "_NET_WM_STATE", "32a",
-							"-set", "_NET_WM_STATE", "_NET_WM_STATE_ABOVE,_NET_WM_STATE_STAYS_ON_TOP").Run()
-
-						// Position window near the cursor
-						exec.Command("xdotool", "windowmove", windowID,
-							strconv.Itoa(curX+20), strconv.Itoa(curY+20)).Run()
-					}
-				}
-			}
-		}
-	}()
-
-	// Show the window
-	t.popupWindow.Show()
 }
 
-// Helper function to run Wayland-specific positioning commands
-func runWaylandPositioningCommands(window fyne.Window, x, y int) {
-	// First try to see if we can get the window ID via XWayland
-	cmd := exec.Command("xwininfo", "-name", "Content")
-	output, err := cmd.CombinedOutput()
-	if err == nil && strings.Contains(string(output), "Window id") {
-		// Parse window ID
-		lines := strings.Split(string(output), "\n")
-		for _, line := range lines {
-			if strings.Contains(line, "Window id") {
-				parts := strings.Split(line, " ")
-				if len(parts) > 3 {
-					windowID := strings.TrimSpace(parts[3])
-					// Move window using xdotool (works with XWayland)
-					exec.Command("xdotool", "windowmove", windowID,
-						strconv.Itoa(x), strconv.Itoa(y)).Run()
-					return
-				}
-			}
-		}
-	}
-
-	// If we're on KDE Plasma, try with KWin's DBus interface
-	if isKDEPlasma() {
-		// Find window by title
-		cmd := exec.Command("qdbus", "org.kde.KWin", "/KWin", "org.kde.KWin.queryWindowInfo")
-		output, err := cmd.CombinedOutput()
-		if err == nil {
-			lines := strings.Split(string(output), "\n")
-			for _, line := range lines {
-				if strings.Contains(line, "Content") {
-					// Found our window, try to move it
-					parts := strings.Split(line, ",")
-					if len(parts) > 0 {
-						winID := strings.TrimSpace(parts[0])
-						exec.Command("qdbus", "org.kde.KWin", "/KWin",
-							"org.kde.KWin.setWindowGeometry", winID,
-							strconv.Itoa(x), strconv.Itoa(y),
-							"400", "300").Run()
-						return
-					}
-				}
-			}
-		}
-	}
-}
-
-// hideContent hides the tooltip content
-func (t *CustomTooltip) hideContent() {
-	if t.popupWindow != nil {
-		t.popupWindow.Close()
-		t.popupWindow = nil
+// insertItem adds an item to the top of the clipboard history. It returns false
+// if the content was empty or already the most recent item. Callers hold cm.mu.
+func (cm *ClipboardManager) insertItem(content string) bool {
+	if !cm.history.Add(content, detectContentType(content)) {
+		return false
 	}
-}
 
-// Tapped handles tap events - show the tooltip on tap as well
-func (t *CustomTooltip) Tapped(*fyne.PointEvent) {
-	if t.popupWindow != nil {
-		t.hideContent()
-	} else {
-		t.showContent()
-	}
+	// Refresh the list
+	cm.historyChanged()
+	cm.publishItem(0)
+	cm.runHooks(hookAdded, 0)
+	return true
 }
 
-// Now, modify the existing createItemList function to use our custom tooltip
+// createItemList creates the list of the filtered history. Selecting a row shows
+// the item in the preview pane, or toggles it in selection mode.
 func (cm *ClipboardManager) createItemList() *widget.List {
-	return widget.NewList(
+	list := widget.NewList(
 		func() int {
-			return len(cm.items)
+			cm.mu.Lock()
+			defer cm.mu.Unlock()
+			return len(cm.filtered)
 		},
 		func() fyne.CanvasObject {
 			// Create a template for list items
@@ -653,23 +482,45 @@ func (cm *ClipboardManager) createItemList() *widget.List {
 			contentLabel.Wrapping = fyne.TextWrapWord
 			contentLabel.Truncation = fyne.TextTruncateEllipsis
 
-			// Create placeholder for the tooltip
-			tooltipPlaceholder := container.NewStack(
-				widget.NewLabel("..."), // This will be replaced in updateItem
-			)
+			// URLs are shown as clickable links instead of the label
+			contentLink := widget.NewHyperlink("", nil)
+			contentLink.Truncation = fyne.TextTruncateEllipsis
+			contentLink.Hide()
+			contentStack := container.NewStack(contentLabel, contentLink)
+
+			// Content type icon, with a swatch for colour items
+			typeIcon := widget.NewIcon(theme.FileTextIcon())
+			swatch := canvas.NewRectangle(color.Transparent)
+			swatch.SetMinSize(fyne.NewSize(theme.IconInlineSize(), theme.IconInlineSize()))
+			swatch.StrokeWidth = 1
+			swatch.StrokeColor = theme.Color(theme.ColorNameForeground)
+			swatch.Hide()
+			typeIndicator := container.NewCenter(container.NewStack(typeIcon, swatch))
 
-			// Content container with label and tooltip placeholder
-			contentContainer := container.NewBorder(nil, nil, nil, tooltipPlaceholder, contentLabel)
+			// Handle previewing items with more content than fits the row
+			handle := newPreviewHandle(cm)
+
+			// Check box selecting the row for merging, shown in selection mode
+			selectCheck := widget.NewCheck("", nil)
+			selectCheck.Hide()
+
+			// Content container with check box, type indicator, label and preview handle
+			contentContainer := container.NewBorder(nil, nil, container.NewHBox(selectCheck, typeIndicator), handle, contentStack)
 
 			timeLabel := widget.NewLabel("Time")
 			timeLabel.TextStyle = fyne.TextStyle{Italic: true}
 
 			pinButton := widget.NewButtonWithIcon("", theme.ContentAddIcon(), func() {})
 			copyButton := widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {})
+			editButton := widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), func() {})
 			deleteButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {})
+			menuButton := widget.NewButtonWithIcon("", theme.MoreVerticalIcon(), func() {})
+
+			buttons := container.NewHBox(pinButton, copyButton, editButton, deleteButton, menuButton)
+			// Buttons for content rules offered on this item
+			ruleBar := container.NewHBox()
 
-			buttons := container.NewHBox(pinButton, copyButton, deleteButton)
-			bottomBar := container.NewBorder(nil, nil, timeLabel, buttons)
+			bottomBar := container.NewBorder(nil, nil, timeLabel, buttons, ruleBar)
 
 			// Create the main container
 			return container.NewBorder(
@@ -680,12 +531,17 @@ func (cm *ClipboardManager) createItemList() *widget.List {
 				contentContainer,
 			)
 		},
-		func(i widget.ListItemID, o fyne.CanvasObject) {
-			if i >= len(cm.items) {
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			// Map the row to the item it shows in the filtered list
+			cm.mu.Lock()
+			i := cm.itemIndex(id)
+			if i < 0 || i >= cm.history.Len() {
+				cm.mu.Unlock()
 				return // Safety check for index out of range
 			}
 
-			item := cm.items[i]
+			item := cm.history.At(i)
+			cm.mu.Unlock()
 
 			// Properly cast to container
 			content, ok := o.(*fyne.Container)
@@ -693,104 +549,318 @@ func (cm *ClipboardManager) createItemList() *widget.List {
 				return // Skip if wrong type
 			}
 
-			// Get content container (which contains the label and tooltip placeholder)
+			// Get content container (which contains the label and preview handle)
 			contentContainer, ok := content.Objects[0].(*fyne.Container)
 			if !ok {
 				return
 			}
 
-			// Get the content label and tooltip placeholder
-			contentLabel, _ := contentContainer.Objects[0].(*widget.Label)
-			tooltipContainer, ok := contentContainer.Objects[1].(*fyne.Container)
+			// Get the content label/link, check box, type indicator and preview handle
+			contentStack, ok := contentContainer.Objects[0].(*fyne.Container)
+			if !ok {
+				return
+			}
+			contentLabel, _ := contentStack.Objects[0].(*widget.Label)
+			contentLink, _ := contentStack.Objects[1].(*widget.Hyperlink)
+			left, ok := contentContainer.Objects[1].(*fyne.Container)
+			if !ok || len(left.Objects) < 2 {
+				return
+			}
+			selectCheck, _ := left.Objects[0].(*widget.Check)
+			typeIndicator, ok := left.Objects[1].(*fyne.Container)
 			if !ok {
 				return
 			}
+			handle, ok := contentContainer.Objects[2].(*previewHandle)
+			if !ok {
+				return
+			}
+
+			cm.updateTypeIndicator(typeIndicator, item)
+
+			if selectCheck != nil {
+				// Detach the handler while restoring the state of the recycled row
+				selectCheck.OnChanged = nil
+				selectCheck.SetChecked(cm.isSelected(item.ID))
+				selectCheck.OnChanged = func(selected bool) {
+					cm.setSelected(item.ID, selected)
+				}
+				if cm.selection.active {
+					selectCheck.Show()
+				} else {
+					selectCheck.Hide()
+				}
+			}
 
 			// Get bottom bar
 			bottomBar, _ := content.Objects[1].(*fyne.Container)
 
 			if contentLabel != nil {
+				// File lists show names and sizes instead of the raw URIs
+				displayContent := item.Content
+				if item.Type == typeFiles {
+					displayContent = fileListSummary(cm.fileStats.get(item))
+				}
+
 				// Get first two lines of content
-				lines := strings.Split(item.content, "\n")
-				truncatedContent := item.content
+				lines := strings.Split(displayContent, "\n")
+				truncatedContent := displayContent
 
 				// Check if the content needs to be truncated
-				if len(lines) > 2 {
+				hasMore := len(lines) > 2
+				if hasMore {
 					// Only show first two lines
 					truncatedContent = lines[0]
 					if len(lines) > 1 {
 						truncatedContent += "\n" + lines[1]
 					}
+				}
 
-					// Create custom tooltip if there's more content
-					tooltip := NewCustomTooltip(item.content, cm.window)
-					tooltipContainer.Objects[0] = tooltip
-					tooltipContainer.Refresh()
-					tooltipContainer.Show()
+				// JSON, code and files always get a handle for the detailed view
+				handle.itemID = item.ID
+				if hasMore || item.Type == typeJSON || item.Type == typeCode || item.Type == typeFiles {
+					handle.Show()
 				} else {
-					// Hide tooltip if not needed
-					tooltipContainer.Hide()
+					handle.Hide()
 				}
 
-				// Set content
+				// Set content, as a link for URLs
 				contentLabel.SetText(truncatedContent)
+				contentLabel.Show()
+				if contentLink != nil {
+					contentLink.Hide()
+					if item.Type == typeURL {
+						if u, err := url.Parse(strings.TrimSpace(item.Content)); err == nil {
+							contentLink.SetText(strings.TrimSpace(item.Content))
+							contentLink.SetURL(u)
+							contentLink.Show()
+							contentLabel.Hide()
+						}
+					}
+				}
 			}
 
 			if bottomBar != nil {
-				timeLabel, _ := bottomBar.Objects[0].(*widget.Label)
-				buttonsContainer, _ := bottomBar.Objects[1].(*fyne.Container)
+				ruleBar, _ := bottomBar.Objects[0].(*fyne.Container)
+				timeLabel, _ := bottomBar.Objects[1].(*widget.Label)
+				buttonsContainer, _ := bottomBar.Objects[2].(*fyne.Container)
 
 				if timeLabel != nil {
-					// Set time
-					timeLabel.SetText(item.timestamp.Format("15:04:05"))
+					// Set time, followed by the item's tags
+					text := item.Timestamp.Format("15:04:05")
+					for _, tag := range item.Tags {
+						text += " #" + tag
+					}
+					timeLabel.SetText(text)
+				}
+
+				if ruleBar != nil {
+					// Offer the content rules that match this item
+					ruleBar.RemoveAll()
+					for _, rule := range cm.matchingRules(item.Content, false) {
+						ruleBar.Add(widget.NewButton(rule.Name, func() {
+							cm.runOfferedRule(rule, item.Content)
+						}))
+					}
 				}
 
-				if buttonsContainer != nil && len(buttonsContainer.Objects) >= 3 {
+				if buttonsContainer != nil && len(buttonsContainer.Objects) >= 5 {
 					pinButton, _ := buttonsContainer.Objects[0].(*widget.Button)
 					copyButton, _ := buttonsContainer.Objects[1].(*widget.Button)
-					deleteButton, _ := buttonsContainer.Objects[2].(*widget.Button)
+					editButton, _ := buttonsContainer.Objects[2].(*widget.Button)
+					deleteButton, _ := buttonsContainer.Objects[3].(*widget.Button)
+					menuButton, _ := buttonsContainer.Objects[4].(*widget.Button)
 
 					// Set pin icon based on state
 					if pinButton != nil {
-						if cm.pinned[i] {
+						if item.Pinned {
 							pinButton.SetIcon(theme.ContentRemoveIcon())
 						} else {
 							pinButton.SetIcon(theme.ContentAddIcon())
 						}
 
 						pinButton.OnTapped = func() {
-							cm.pinned[i] = !cm.pinned[i]
-							cm.list.Refresh()
+							cm.setItemPinned(item.ID, !item.Pinned)
 						}
 					}
 
-					// Set button actions
+					// Set button actions. The history may have changed since the row
+					// was bound, so they look the item up by ID.
 					if copyButton != nil {
 						copyButton.OnTapped = func() {
-							go func() {
-								if cm.isWayland {
-									// For Wayland, use wl-copy instead of robotgo
-									cmd := exec.Command("wl-copy", item.content)
-									cmd.Run()
-								} else {
-									robotgo.WriteAll(item.content)
-								}
-
-								// Hide the window
-								cm.window.Hide()
-							}()
+							if current, ok := cm.itemByID(item.ID); ok {
+								cm.copyAndHide(current)
+							}
+						}
+					}
+
+					if editButton != nil {
+						editButton.OnTapped = func() {
+							cm.showEditDialog(item.ID)
 						}
 					}
 
 					if deleteButton != nil {
 						deleteButton.OnTapped = func() {
-							cm.removeItem(i)
+							cm.removeItemByID(item.ID)
+						}
+					}
+
+					if menuButton != nil {
+						menuButton.OnTapped = func() {
+							cm.showRowMenu(item.ID, menuButton)
 						}
 					}
 				}
 			}
 		},
 	)
+
+	list.OnSelected = func(id widget.ListItemID) {
+		cm.mu.Lock()
+		cm.touch()
+		i := cm.itemIndex(id)
+		var itemID string
+		if i >= 0 && i < cm.history.Len() {
+			itemID = cm.history.At(i).ID
+		}
+		cm.mu.Unlock()
+
+		if itemID == "" {
+			return
+		}
+
+		if cm.selection.active {
+			// Tapping a row in selection mode toggles its check box
+			cm.setSelected(itemID, !cm.isSelected(itemID))
+			list.Unselect(id)
+			list.RefreshItem(id)
+			return
+		}
+		cm.showPreview(itemID, true)
+	}
+	return list
+}
+
+// itemByID returns the item with the given ID, if it is still in the history
+func (cm *ClipboardManager) itemByID(id string) (core.Item, bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	index := cm.history.IndexOfID(id)
+	if index < 0 {
+		return core.Item{}, false
+	}
+	return cm.history.At(index), true
+}
+
+// copyAndHide copies an item and hides the window once it is on the clipboard
+func (cm *ClipboardManager) copyAndHide(item core.Item) {
+	cm.noteActivity()
+	go func() {
+		if err := cm.copyItem(item); err != nil {
+			cm.showError(err, cm.window)
+			return
+		}
+		cm.runUI(cm.hideWindow)
+	}()
+}
+
+// copyItem puts an item back on the system clipboard
+func (cm *ClipboardManager) copyItem(item core.Item) error {
+	if item.Type == typeFiles {
+		if err := writeClipboardFiles(cm.currentClipboard(), parseURIList(item.Content)); err != nil {
+			return err
+		}
+	} else if err := core.WriteText(cm.currentClipboard(), item.Content); err != nil {
+		return fmt.Errorf("could not copy to clipboard: %w", err)
+	}
+
+	cm.mu.Lock()
+	cm.runHooks(hookCopied, cm.history.IndexOfID(item.ID))
+	cm.mu.Unlock()
+	return nil
+}
+
+// showRowMenu pops up the context menu for the item with the given ID below the given button
+func (cm *ClipboardManager) showRowMenu(id string, anchor fyne.CanvasObject) {
+	cm.noteActivity()
+	menu := fyne.NewMenu("", cm.rowMenuItems(id)...)
+
+	pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(anchor)
+	pos = pos.Add(fyne.NewPos(0, anchor.Size().Height))
+	widget.ShowPopUpMenuAtPosition(menu, cm.window.Canvas(), pos)
+}
+
+// rowMenuItems builds the context menu entries for the item with the given ID
+func (cm *ClipboardManager) rowMenuItems(id string) []*fyne.MenuItem {
+	items := []*fyne.MenuItem{
+		fyne.NewMenuItem("Edit…", func() {
+			cm.showEditDialog(id)
+		}),
+		fyne.NewMenuItem("Edit tags…", func() {
+			cm.showTagDialog(id)
+		}),
+		fyne.NewMenuItem("Split…", func() {
+			cm.showSplitDialog(id)
+		}),
+	}
+
+	if len(cm.actions) > 0 {
+		items = append(items, fyne.NewMenuItemSeparator())
+	}
+
+	for _, action := range cm.actions {
+		name := action.Name
+		items = append(items, fyne.NewMenuItem(name, func() {
+			item, ok := cm.itemByID(id)
+			if !ok {
+				return
+			}
+			go func() {
+				if _, err := cm.transformContent(name, item.Content); err != nil {
+					cm.showError(err, cm.window)
+				}
+			}()
+		}))
+	}
+
+	if sendItems := cm.lanMenuItems(id); len(sendItems) > 0 {
+		items = append(items, fyne.NewMenuItemSeparator())
+		items = append(items, sendItems...)
+	}
+
+	return items
+}
+
+// updateTypeIndicator shows the item's content type icon, or a swatch for colours
+func (cm *ClipboardManager) updateTypeIndicator(indicator *fyne.Container, item core.Item) {
+	stack, ok := indicator.Objects[0].(*fyne.Container)
+	if !ok || len(stack.Objects) < 2 {
+		return
+	}
+	typeIcon, _ := stack.Objects[0].(*widget.Icon)
+	swatch, _ := stack.Objects[1].(*canvas.Rectangle)
+	if typeIcon == nil || swatch == nil {
+		return
+	}
+
+	if c, ok := parseColor(item.Content); ok && item.Type == typeColor {
+		swatch.FillColor = c
+		swatch.Show()
+		swatch.Refresh()
+		typeIcon.Hide()
+		return
+	}
+
+	swatch.Hide()
+	if item.Type == typeFiles && hasMissingFiles(cm.fileStats.get(item)) {
+		// Flag file lists whose files have been moved or deleted
+		typeIcon.SetResource(theme.WarningIcon())
+	} else {
+		typeIcon.SetResource(contentTypeIcon(item.Type))
+	}
+	typeIcon.Show()
 }
 
 // Helper function to create a scrollable text display
@@ -806,51 +876,77 @@ func createScrollableTextDisplay(content string) fyne.CanvasObject {
 
 // removeItem removes an item from clipboard history
 func (cm *ClipboardManager) removeItem(index int) {
-	if index < 0 || index >= len(cm.items) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.touch()
+	cm.removeItemLocked(index)
+}
+
+// removeItemByID removes the item with the given ID, if it is still there
+func (cm *ClipboardManager) removeItemByID(id string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.touch()
+	cm.removeItemLocked(cm.history.IndexOfID(id))
+}
+
+// removeItemLocked removes an item from clipboard history. Callers hold cm.mu.
+func (cm *ClipboardManager) removeItemLocked(index int) {
+	if index < 0 || index >= cm.history.Len() {
 		return
 	}
 
-	// Remove from pinned if it was pinned
-	delete(cm.pinned, index)
+	cm.runHooks(hookDeleted, index)
+	if item, ok := cm.history.Remove(index); ok {
+		cm.noteDeleted(item.ID)
+	}
+	cm.historyChanged()
+}
 
-	// Update pinned indices
-	newPinned := make(map[int]bool)
-	for pinIdx, pinned := range cm.pinned {
-		if pinIdx < index {
-			newPinned[pinIdx] = pinned
-		} else if pinIdx > index {
-			newPinned[pinIdx-1] = pinned
-		}
+// setPinned pins or unpins an item
+func (cm *ClipboardManager) setPinned(index int, pinned bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.touch()
+	cm.setPinnedLocked(index, pinned)
+}
+
+// setItemPinned pins or unpins the item with the given ID, if it is still there
+func (cm *ClipboardManager) setItemPinned(id string, pinned bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.touch()
+	cm.setPinnedLocked(cm.history.IndexOfID(id), pinned)
+}
+
+// setPinnedLocked pins or unpins an item. Callers hold cm.mu.
+func (cm *ClipboardManager) setPinnedLocked(index int, pinned bool) {
+	if !cm.history.SetPinned(index, pinned) {
+		return
 	}
-	cm.pinned = newPinned
 
-	// Remove the item
-	cm.items = append(cm.items[:index], cm.items[index+1:]...)
-	cm.list.Refresh()
+	if pinned {
+		cm.runHooks(hookPinned, index)
+	} else {
+		cm.runHooks(hookUnpinned, index)
+	}
+	cm.historyChanged()
 }
 
 // clearItems clears non-pinned items from clipboard history
 func (cm *ClipboardManager) clearItems() {
-	// Keep only pinned items
-	pinnedItems := make([]ClipboardItem, 0)
-	newPinned := make(map[int]bool)
-
-	idx := 0
-	for i, item := range cm.items {
-		if cm.pinned[i] {
-			pinnedItems = append(pinnedItems, item)
-			newPinned[idx] = true
-			idx++
-		}
-	}
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 
-	cm.items = pinnedItems
-	cm.pinned = newPinned
-	cm.list.Refresh()
+	cm.runClearedHooks()
+	for _, item := range cm.history.Clear() {
+		cm.noteDeleted(item.ID)
+	}
+	cm.historyChanged()
 }
 
 // registerGlobalShortcut registers global keyboard shortcut
-func registerGlobalShortcut(w fyne.Window, cm *ClipboardManager) {
+func registerGlobalShortcut(cm *ClipboardManager) {
 	// Skip if running on Wayland as we use KDE shortcuts instead
 	if cm.isWayland {
 		return
@@ -858,67 +954,8 @@ func registerGlobalShortcut(w fyne.Window, cm *ClipboardManager) {
 
 	go func() {
 		hook.Register(hook.KeyDown, cm.hotkeySettings.ShowHide, func(e hook.Event) {
-			// Use a channel to synchronize with the main thread
-			done := make(chan struct{})
-			go func() {
-				// Get the current mouse position
-				mouseX, mouseY := robotgo.Location()
-
-				// Get screen size (primary monitor)
-				screenWidth, screenHeight := robotgo.GetScreenSize()
-
-				// Set window size - assuming standard clipboard size
-				windowWidth := 400
-				windowHeight := 500
-
-				// Calculate window position based on mouse and screen
-				// We want to position the window so it's fully on screen
-				// and close to the mouse cursor
-				var windowX, windowY int
-
-				// X position: prefer right of cursor if space allows, otherwise left
-				if mouseX+windowWidth+20 < screenWidth {
-					// Position to the right of cursor
-					windowX = mouseX + 20
-				} else if mouseX-windowWidth-20 > 0 {
-					// Position to the left of cursor
-					windowX = mouseX - windowWidth - 20
-				} else {
-					// Center horizontally if neither fits well
-					windowX = (screenWidth - windowWidth) / 2
-				}
-
-				// Y position: prefer below cursor if space allows, otherwise above
-				if mouseY+windowHeight+20 < screenHeight {
-					// Position below cursor
-					windowY = mouseY + 20
-				} else if mouseY-windowHeight-20 > 0 {
-					// Position above cursor
-					windowY = mouseY - windowHeight - 20
-				} else {
-					// Center vertically if neither fits well
-					windowY = (screenHeight - windowHeight) / 2
-				}
-
-				// Since RunOnMain is not available, we'll use a direct approach
-				// First hide the window
-				w.Hide()
-
-				// Resize to ensure window manager updates
-				w.Resize(fyne.NewSize(float32(windowWidth), float32(windowHeight)))
-
-				// Use SetPosition if available
-				if setter, ok := w.(interface{ SetPosition(pos fyne.Position) }); ok {
-					setter.SetPosition(fyne.NewPos(float32(windowX), float32(windowY)))
-				}
-
-				// Show window and request focus
-				w.Show()
-				w.RequestFocus()
-
-				close(done)
-			}()
-			<-done // Wait for UI operations to complete
+			// Show the window on the monitor under the cursor, see placement.go
+			cm.summonWindow()
 		})
 
 		// Start the hook listening process
@@ -927,42 +964,6 @@ func registerGlobalShortcut(w fyne.Window, cm *ClipboardManager) {
 	}()
 }
 
-// monitorClipboard monitors system clipboard for changes
-func (cm *ClipboardManager) monitorClipboard() {
-	lastContent := ""
-
-	go func() {
-		for {
-			var content string
-			var err error
-
-			if cm.isWayland {
-				// Use wl-paste for Wayland
-				cmd := exec.Command("wl-paste", "-n")
-				output, cmdErr := cmd.Output()
-				if cmdErr == nil {
-					content = string(output)
-				}
-			} else {
-				// Use robotgo for X11
-				content, err = robotgo.ReadAll()
-			}
-
-			if err == nil && content != lastContent && content != "" {
-				lastContent = content
-
-				// Since RunOnMain is not available, use goroutine and directly
-				// access the UI components but be careful about race conditions
-				go func(contentCopy string) {
-					cm.addItem(contentCopy)
-				}(content) // Pass content as parameter to avoid race condition
-			}
-
-			time.Sleep(500 * time.Millisecond)
-		}
-	}()
-}
-
 // UpdateHotkey updates the hotkey settings
 func (cm *ClipboardManager) UpdateHotkey(modifierKey, actionKey string) {
 	// Parse modifier key into individual keys
@@ -986,10 +987,9 @@ func (cm *ClipboardManager) UpdateHotkey(modifierKey, actionKey string) {
 	cm.hotkeySettings.ModifierKey = modifierKey
 	cm.hotkeySettings.ActionKey = actionKey
 
-	// Save settings to config file
-	config := Config{
-		Hotkeys: cm.hotkeySettings,
-	}
+	// Save settings to config file, keeping the other sections intact
+	config := loadConfig()
+	config.Hotkeys = cm.hotkeySettings
 	saveConfig(config)
 
 	// Update KDE shortcut if on Wayland
@@ -998,228 +998,114 @@ func (cm *ClipboardManager) UpdateHotkey(modifierKey, actionKey string) {
 	}
 }
 
-// func setWindowAlwaysOnTop(windowTitle string) {
-// 	// Wait a moment for the window to appear and stabilize
-// 	time.Sleep(500 * time.Millisecond)
-// 	log.Print("AAAAAAAAAAAAAa")
-// 	if isWaylandSession() {
-// 		// For Wayland, try KDE-specific approach if it appears to be KDE
-// 		// if os.Getenv("KDE_FULL_SESSION") != "" || os.Getenv("XDG_CURRENT_DESKTOP") == "KDE" {
-// 		// 	setKDEKeepAboveOthers(windowTitle)
-// 		// 	log.Print("AAAAAAAAAAAAAa")
-// 		// }
-// 	} else {
-// 		// For X11, use xprop/xdotool
-// 		setX11WindowAlwaysOnTop(windowTitle)
-// 	}
-// }
-
-// For X11 environments
-func setX11WindowAlwaysOnTop(windowTitle string) {
-	// Try to find the window by its title
-	cmd := exec.Command("xdotool", "search", "--name", windowTitle)
-	output, err := cmd.Output()
-	if err != nil {
-		fmt.Printf("Could not find window ID: %v\n", err)
-		return
-	}
+// kdeRuleDescription identifies NoteBoard's window rule in kwinrulesrc
+const kdeRuleDescription = appName + " Window Rule"
 
-	// If multiple matches, take the first one
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) == 0 {
-		fmt.Println("No matching windows found")
-		return
-	}
+// kdeKeepAboveRule returns the settings of the KWin rule keeping our window above others.
+// Match values: 1 = exact; rule values: 2 = force, 3 = apply initially.
+func kdeKeepAboveRule() map[string]string {
+	return map[string]string{
+		"Description": kdeRuleDescription,
+		"title":       appName,
+		"titlematch":  "1",
 
-	winID := lines[0]
+		"above":     "true",
+		"aboverule": "2",
+		"layer":     "4", // Above layer (not popup/overlay)
+		"layerrule": "2",
 
-	// Set the _NET_WM_STATE_ABOVE atom
-	cmd = exec.Command("xprop", "-id", winID, "-f", "_NET_WM_STATE", "32a",
-		"-set", "_NET_WM_STATE", "_NET_WM_STATE_ABOVE")
-	err = cmd.Run()
-	if err != nil {
-		fmt.Printf("Failed to set window always on top: %v\n", err)
-	} else {
-		fmt.Println("Successfully set window always on top via X11")
+		// Apply on all desktops and activities
+		"desktops":     "",
+		"desktopsrule": "3",
+		"activity":     "",
+		"activityrule": "3",
 	}
 }
 
-// SetKDEWindowKeepAbove sets whether the window should stay above others
+// setKDEWindowKeepAbove sets whether the window should stay above others. Enabling
+// it creates or updates our KWin window rule, disabling it deletes the rule.
 func (cm *ClipboardManager) setKDEWindowKeepAbove(enabled bool) error {
 	// Only proceed if we're running in KDE Plasma
 	if !isKDEPlasma() {
 		return fmt.Errorf("not running in KDE Plasma")
 	}
 
-	// Check if kwriteconfig5 and qdbus are available
-	_, err := exec.LookPath("kwriteconfig5")
+	path, err := kwin.DefaultPath()
 	if err != nil {
-		return fmt.Errorf("kwriteconfig5 not found: %w", err)
+		return err
 	}
-
-	_, err = exec.LookPath("qdbus")
+	rules, err := kwin.Load(path)
 	if err != nil {
-		return fmt.Errorf("qdbus not found: %w", err)
+		return fmt.Errorf("failed to read KWin rules: %w", err)
 	}
 
-	// Find or create a window rule for our app
-	ruleId, isNew, err := cm.findOrCreateKDEWindowRule()
-	if err != nil {
-		return fmt.Errorf("failed to find/create KDE window rule: %w", err)
-	}
-
-	// Rule group is based on the ID
-	ruleGroup := fmt.Sprintf("%d", ruleId)
-
-	// Set the keep above property based on the enabled parameter
-	kwritePath, _ := exec.LookPath("kwriteconfig5")
-
-	// Set above (keep above others) setting
-	exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", ruleGroup,
-		"--key", "above", strconv.FormatBool(enabled)).Run()
-	exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", ruleGroup,
-		"--key", "aboverule", "2").Run() // 2 = force yes
-
-	// If it's a new rule or if we're enabling the feature, also set these properties
-	if isNew || enabled {
-		// Window matching criteria - match by window title EXACTLY (not substring)
-		exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", ruleGroup,
-			"--key", "title", appName).Run()
-		exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", ruleGroup,
-			"--key", "titlematch", "0").Run() // 0 = exact match (was 2 for substring)
-
-		// Description
-		exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", ruleGroup,
-			"--key", "Description", appName+" Window Rule").Run()
-
-		// If enabled, also set Layer to Above (not popup/overlay)
-		if enabled {
-			// Force Layer: Above
-			exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", ruleGroup,
-				"--key", "layer", "4").Run() // 4 = Above layer (was 6 for Overlay)
-			exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", ruleGroup,
-				"--key", "layerrule", "2").Run() // 2 = force yes
-		} else {
-			// Reset layer to normal
-			exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", ruleGroup,
-				"--key", "layer", "0").Run() // 0 = normal
-			exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", ruleGroup,
-				"--key", "layerrule", "2").Run() // 2 = force yes
+	id, exists := rules.FindRule(kdeRuleDescription)
+	switch {
+	case enabled:
+		if !exists {
+			id = kwin.NewRuleID()
 		}
+		rules.SetRule(id, kdeKeepAboveRule())
+	case exists:
+		rules.DeleteRule(id)
+	default:
+		return nil
+	}
 
-		// Make the rule apply to all desktops
-		exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", ruleGroup,
-			"--key", "desktops", "").Run()
-		exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", ruleGroup,
-			"--key", "desktopsrule", "3").Run() // 3 = all desktops
-
-		// Apply to all activities
-		exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", ruleGroup,
-			"--key", "activity", "").Run()
-		exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", ruleGroup,
-			"--key", "activityrule", "3").Run() // 3 = all activities
+	if err := rules.Save(path); err != nil {
+		return fmt.Errorf("failed to write KWin rules: %w", err)
 	}
 
 	// Reload KWin rules
-	exec.Command("qdbus", "org.kde.KWin", "/KWin", "org.kde.KWin.reconfigure").Run()
-
-	return nil
-}
-
-// Helper method to find an existing window rule or create a new one
-func (cm *ClipboardManager) findOrCreateKDEWindowRule() (int, bool, error) {
-	// Get number of existing rules in kwinrulesrc
-	countCmd := exec.Command("kreadconfig5", "--file", "kwinrulesrc", "--group", "General", "--key", "count")
-	countOutput, err := countCmd.Output()
-	count := 0
-	if err == nil {
-		count, _ = strconv.Atoi(strings.TrimSpace(string(countOutput)))
+	if err := reconfigureKWin(); err != nil {
+		return fmt.Errorf("failed to reload KWin rules: %w", err)
 	}
 
-	// Check if there's already a rule for our app
-	for i := 1; i <= count; i++ {
-		ruleGroup := fmt.Sprintf("%d", i)
-
-		// Get rule description
-		descCmd := exec.Command("kreadconfig5", "--file", "kwinrulesrc", "--group", ruleGroup, "--key", "Description")
-		descOutput, err := descCmd.Output()
-		if err != nil {
-			continue
-		}
-
-		desc := strings.TrimSpace(string(descOutput))
-		// Check if this rule is for our app
-		if strings.Contains(desc, appName) {
-			return i, false, nil // Found existing rule
-		}
-
-		// Check title match as well
-		titleCmd := exec.Command("kreadconfig5", "--file", "kwinrulesrc", "--group", ruleGroup, "--key", "title")
-		titleOutput, err := titleCmd.Output()
-		if err != nil {
-			continue
-		}
-
-		title := strings.TrimSpace(string(titleOutput))
-		if title == appName {
-			return i, false, nil // Found existing rule
-		}
+	// The placement script applies keep-above to windows it places
+	if usesKWinPlacement() {
+		return cm.loadKWinPlacement(enabled)
 	}
-
-	// No existing rule found, create a new one
-	newRuleIndex := count + 1
-
-	// Set the new rule count
-	kwritePath, _ := exec.LookPath("kwriteconfig5")
-	exec.Command(kwritePath, "--file", "kwinrulesrc", "--group", "General", "--key", "count", strconv.Itoa(newRuleIndex)).Run()
-
-	return newRuleIndex, true, nil // Return new rule index
-}
-
-func isKDEPlasma() bool {
-	// Check common environment variables that indicate KDE
-	desktop := os.Getenv("XDG_CURRENT_DESKTOP")
-	session := os.Getenv("KDE_FULL_SESSION")
-
-	return strings.Contains(strings.ToLower(desktop), "kde") || session == "true"
+	return nil
 }
 
-// IsKDEKeepAboveEnabled checks if Keep Above is currently enabled for our app
+// isKDEKeepAboveEnabled checks if our KWin window rule keeps the window above others
 func (cm *ClipboardManager) isKDEKeepAboveEnabled() bool {
 	// Only relevant for KDE Plasma
 	if !isKDEPlasma() {
 		return false
 	}
 
-	// Check if kreadconfig5 is available
-	_, err := exec.LookPath("kreadconfig5")
+	path, err := kwin.DefaultPath()
 	if err != nil {
 		return false
 	}
-
-	// Find the rule for our app
-	ruleId, isNew, err := cm.findOrCreateKDEWindowRule()
-	if err != nil || isNew {
-		return false // Rule doesn't exist or error
-	}
-
-	// Rule group is based on the ID
-	ruleGroup := fmt.Sprintf("%d", ruleId)
-
-	// Check if keep above is enabled
-	aboveCmd := exec.Command("kreadconfig5", "--file", "kwinrulesrc", "--group", ruleGroup, "--key", "above")
-	aboveOutput, err := aboveCmd.Output()
+	rules, err := kwin.Load(path)
 	if err != nil {
+		slog.Warn("could not read KWin rules", "err", err)
 		return false
 	}
 
-	above := strings.TrimSpace(string(aboveOutput))
-	return above == "true"
+	id, exists := rules.FindRule(kdeRuleDescription)
+	if !exists {
+		return false
+	}
+	settings, _ := rules.Rule(id)
+	return settings["above"] == "true"
 }
 
 func main() {
+	args, verbose := parseFlags(os.Args[1:])
+
+	// Subcommands talk to the running instance over the control socket
+	if len(args) > 0 {
+		setupLogging(verbose, false)
+		os.Exit(runCLI(args))
+	}
+	setupLogging(verbose, true)
+
 	// Check if another instance is running
-	if ensureSingleInstance() {
+	listener, running := ensureSingleInstance()
+	if running {
 		// Exit if another instance is already running
 		return
 	}
@@ -1230,19 +1116,36 @@ func main() {
 	a.SetIcon(resourceNoteboardFynePng)
 
 	w := a.NewWindow(appName)
-	w.Resize(fyne.NewSize(400, 500))
+	w.Resize(mainWindowSize)
 
 	// w.SetMaster()
 
 	cm := newClipboardManager(w)
 
+	// Report missing tools up front rather than through silently failing features
+	go warnMissingTools(runDiagnostics(cm.currentClipboard().Name()))
+
+	// Let KWin place our windows at the cursor on Wayland
+	if usesKWinPlacement() {
+		go func() {
+			if err := cm.loadKWinPlacement(cm.isKDEKeepAboveEnabled()); err != nil {
+				slog.Warn("could not load KWin placement script", "err", err)
+			}
+		}()
+	}
+
+	// Serve CLI requests on the control socket
+	if listener != nil {
+		go cm.serveSocket(listener)
+	}
+
 	// Register global shortcut if not on Wayland``
 	if !cm.isWayland {
-		registerGlobalShortcut(w, cm)
+		registerGlobalShortcut(cm)
 	}
 
 	w.SetCloseIntercept(func() {
-		w.Hide()
+		cm.hideWindow()
 	})
 
 	// Set up search
@@ -1250,24 +1153,13 @@ func main() {
 	searchEntry.SetPlaceHolder("Search clipboard items...")
 
 	// Fix search functionality
-	searchEntry.OnChanged = func(text string) {
-		if text == "" {
-			// Reset list to show all items
-			cm.list.Refresh()
-			return
-		}
+	searchEntry.OnChanged = cm.setSearchText
 
-		// This is just visual filtering - in a production app
-		// you'd want to maintain a separate filtered list
-		// text = strings.ToLower(text)
-		// Just refresh the entire list for now to keep it simple
-		cm.list.Refresh()
-	}
-
-	// Header with title and search
+	// Header with title, search and tag chips
 	header := container.NewVBox(
 		widget.NewLabel(appName),
 		searchEntry,
+		cm.createTagBar(),
 	)
 
 	// Create a system tray icon
@@ -1275,14 +1167,15 @@ func main() {
 		m := fyne.NewMenu(appName,
 			fyne.NewMenuItem("Show/Hide", func() {
 				if w.Content().Visible() {
-					w.Hide()
+					cm.hideWindow()
 				} else {
-					w.Show()
-					w.RequestFocus()
-					// go setWindowAlwaysOnTop(appName)
+					cm.showWindow()
 				}
 			}),
 			fyne.NewMenuItem("Quit", func() {
+				if usesKWinPlacement() {
+					unloadKWinPlacementScript()
+				}
 				a.Quit()
 			}),
 		)
@@ -1292,7 +1185,7 @@ func main() {
 		customIcon, err := loadIconResource(iconPath)
 		if err != nil {
 			// Fall back to default icon on error
-			fmt.Printf("Could not load custom icon: %v. Using default icon.\n", err)
+			slog.Warn("could not load custom icon, using default icon", "err", err)
 			desk.SetSystemTrayIcon(theme.ContentPasteIcon())
 		} else {
 			// Use custom icon
@@ -1307,18 +1200,26 @@ func main() {
 
 	// Footer with buttons
 	footer := container.NewHBox(
+		cm.createSelectionBar(),
 		layout.NewSpacer(),
 		cm.clearButton,
 		settingsButton,
 	)
 
-	// Main layout
+	// Main layout, with the locked placeholder over the list while the history is
+	// encrypted and the preview pane below it
 	content := container.NewBorder(
 		header,
 		footer,
 		nil,
 		nil,
-		container.NewPadded(cm.list),
+		container.NewBorder(
+			nil,
+			cm.createPreviewPane(),
+			nil,
+			nil,
+			container.NewStack(container.NewPadded(cm.list), cm.createLockedView()),
+		),
 	)
 
 	w.SetContent(content)
@@ -1326,27 +1227,34 @@ func main() {
 	// Start monitoring clipboard
 	cm.monitorClipboard()
 
-	// Add some sample items
-	if cm.isWayland {
-		cm.addItem("Running on Wayland mode")
-	} else {
-		cm.addItem("Running on X11 mode")
-	}
+	// Lock the encrypted history again when idle
+	cm.watchIdle()
+
+	// Add some sample items, unless there is saved history
+	cm.mu.Lock()
+	empty := cm.history.Len() == 0 && !cm.locked
+	cm.mu.Unlock()
+	if empty {
+		if cm.isWayland {
+			cm.addItem("Running on Wayland mode")
+		} else {
+			cm.addItem("Running on X11 mode")
+		}
 
-	cm.addItem("Welcome to NoteBoard!")
+		cm.addItem("Welcome to NoteBoard!")
 
-	// Display hotkey info
-	if cm.isWayland {
-		cm.addItem("Using KDE global shortcuts (set in System Settings)")
-	} else if len(cm.hotkeySettings.ShowHide) > 0 {
-		cm.addItem("Press " + strings.Join(cm.hotkeySettings.ShowHide, "+") + " to open this manager")
-	}
+		// Display hotkey info
+		if cm.isWayland {
+			cm.addItem("Using KDE global shortcuts (set in System Settings)")
+		} else if len(cm.hotkeySettings.ShowHide) > 0 {
+			cm.addItem("Press " + strings.Join(cm.hotkeySettings.ShowHide, "+") + " to open this manager")
+		}
 
-	cm.addItem("Items copied to your clipboard will appear here")
+		cm.addItem("Items copied to your clipboard will appear here")
+	}
 
-	w.Show()
+	cm.showWindow()
 	a.Run()
-	// go setWindowAlwaysOnTop(appName)
 }
 
 // Function to load an icon from the project directory
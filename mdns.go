@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	mdnsAddress    = "224.0.0.251:5353"
+	lanServiceName = "_noteboard._tcp.local."
+
+	mdnsTTL           = 120 // seconds
+	mdnsQueryInterval = time.Minute
+)
+
+// mdnsService is a NoteBoard instance announced on the network
+type mdnsService struct {
+	id   string
+	name string
+	port int
+	ttl  uint32
+}
+
+// buildMDNSQuery asks all NoteBoard instances on the network to announce themselves
+func buildMDNSQuery() ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	err := b.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(lanServiceName),
+		Type:  dnsmessage.TypePTR,
+		Class: dnsmessage.ClassINET,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// buildMDNSAnnouncement announces this instance. A TTL of zero says goodbye.
+func buildMDNSAnnouncement(service mdnsService) ([]byte, error) {
+	serviceName, err := dnsmessage.NewName(lanServiceName)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := dnsmessage.NewName(service.id + "." + lanServiceName)
+	if err != nil {
+		return nil, err
+	}
+	target, err := dnsmessage.NewName(service.id + ".local.")
+	if err != nil {
+		return nil, err
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true, Authoritative: true})
+	b.EnableCompression()
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+
+	header := func(name dnsmessage.Name) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: service.ttl}
+	}
+	if err := b.PTRResource(header(serviceName), dnsmessage.PTRResource{PTR: instance}); err != nil {
+		return nil, err
+	}
+	if err := b.SRVResource(header(instance), dnsmessage.SRVResource{Port: uint16(service.port), Target: target}); err != nil {
+		return nil, err
+	}
+	txt := dnsmessage.TXTResource{TXT: []string{"id=" + service.id, "name=" + service.name}}
+	if err := b.TXTResource(header(instance), txt); err != nil {
+		return nil, err
+	}
+
+	return b.Finish()
+}
+
+// parseMDNSMessage reports whether the packet queries for NoteBoard instances
+// and returns the instances it announces
+func parseMDNSMessage(data []byte) (query bool, services []mdnsService) {
+	var p dnsmessage.Parser
+	header, err := p.Start(data)
+	if err != nil {
+		return false, nil
+	}
+
+	if !header.Response {
+		questions, err := p.AllQuestions()
+		if err != nil {
+			return false, nil
+		}
+		for _, q := range questions {
+			if q.Type == dnsmessage.TypePTR && strings.EqualFold(q.Name.String(), lanServiceName) {
+				query = true
+			}
+		}
+		return query, nil
+	}
+
+	if err := p.SkipAllQuestions(); err != nil {
+		return false, nil
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return false, nil
+	}
+	if err := p.SkipAllAuthorities(); err == nil {
+		if additionals, err := p.AllAdditionals(); err == nil {
+			answers = append(answers, additionals...)
+		}
+	}
+
+	byInstance := make(map[string]*mdnsService)
+	instance := func(name string) *mdnsService {
+		name = strings.ToLower(name)
+		if byInstance[name] == nil {
+			byInstance[name] = &mdnsService{}
+		}
+		return byInstance[name]
+	}
+
+	for _, answer := range answers {
+		name := answer.Header.Name.String()
+		if !strings.HasSuffix(strings.ToLower(name), lanServiceName) {
+			continue
+		}
+
+		switch body := answer.Body.(type) {
+		case *dnsmessage.PTRResource:
+			instance(body.PTR.String()).ttl = answer.Header.TTL
+		case *dnsmessage.SRVResource:
+			instance(name).port = int(body.Port)
+		case *dnsmessage.TXTResource:
+			s := instance(name)
+			for _, entry := range body.TXT {
+				key, value, _ := strings.Cut(entry, "=")
+				switch key {
+				case "id":
+					s.id = value
+				case "name":
+					s.name = value
+				}
+			}
+		}
+	}
+
+	for _, s := range byInstance {
+		if s.id != "" && s.port != 0 {
+			services = append(services, *s)
+		}
+	}
+	return false, services
+}
+
+// runDiscovery announces this instance over multicast DNS and reports the other
+// instances it hears about until the connection is closed
+func runDiscovery(conn *net.UDPConn, self mdnsService, found func(service mdnsService, addr net.IP)) {
+	group, _ := net.ResolveUDPAddr("udp4", mdnsAddress)
+
+	announce := func() {
+		if data, err := buildMDNSAnnouncement(self); err == nil {
+			conn.WriteToUDP(data, group)
+		}
+	}
+	query := func() {
+		if data, err := buildMDNSQuery(); err == nil {
+			conn.WriteToUDP(data, group)
+		}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(mdnsQueryInterval)
+		defer ticker.Stop()
+		for {
+			announce()
+			query()
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		isQuery, services := parseMDNSMessage(buf[:n])
+		if isQuery {
+			announce()
+		}
+		for _, service := range services {
+			if service.id != self.id {
+				found(service, from.IP)
+			}
+		}
+	}
+}
+
+// listenMDNS joins the multicast DNS group
+func listenMDNS() (*net.UDPConn, error) {
+	group, err := net.ResolveUDPAddr("udp4", mdnsAddress)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return nil, fmt.Errorf("could not join multicast DNS group: %w", err)
+	}
+	return conn, nil
+}
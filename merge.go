@@ -0,0 +1,315 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"NoteBoard/core"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// mergeSeparator is a choice of text put between merged items
+type mergeSeparator struct {
+	name      string
+	separator string
+}
+
+// mergeSeparators are the separators offered when merging; the last one is custom
+var mergeSeparators = []mergeSeparator{
+	{"Newline", "\n"},
+	{"Space", " "},
+	{"Comma", ", "},
+	{"Custom", ""},
+}
+
+// unescapeSeparator turns \n and \t typed into a separator field into the characters
+var unescapeSeparator = strings.NewReplacer(`\n`, "\n", `\t`, "\t")
+
+// selectionState is the multi-selection of rows for merging. Items are kept by ID
+// in the order they were selected, which is the order they are merged in. Only
+// widget callbacks use it, so it lives on the main thread and needs no lock.
+type selectionState struct {
+	active       bool
+	ids          []string
+	selectButton *widget.Button
+	mergeButton  *widget.Button
+}
+
+// createSelectionBar creates the footer buttons to select rows and merge them
+func (cm *ClipboardManager) createSelectionBar() fyne.CanvasObject {
+	s := &cm.selection
+	s.selectButton = widget.NewButtonWithIcon("Select", theme.CheckButtonCheckedIcon(), func() {
+		cm.setSelecting(!s.active)
+	})
+	s.mergeButton = widget.NewButtonWithIcon("Merge", theme.ContentPasteIcon(), cm.showMergeDialog)
+	s.mergeButton.Hide()
+	return container.NewHBox(s.selectButton, s.mergeButton)
+}
+
+// setSelecting enters or leaves selection mode, in which rows show check boxes
+func (cm *ClipboardManager) setSelecting(active bool) {
+	s := &cm.selection
+	s.active = active
+	s.ids = nil
+	if active {
+		s.selectButton.SetText("Cancel")
+		s.selectButton.SetIcon(theme.CancelIcon())
+		s.mergeButton.Show()
+	} else {
+		s.selectButton.SetText("Select")
+		s.selectButton.SetIcon(theme.CheckButtonCheckedIcon())
+		s.mergeButton.Hide()
+	}
+	cm.updateMergeButton()
+	if cm.list != nil {
+		cm.list.UnselectAll()
+		cm.list.Refresh()
+	}
+}
+
+// setSelected adds an item to or removes it from the selection
+func (cm *ClipboardManager) setSelected(id string, selected bool) {
+	s := &cm.selection
+	index := slices.Index(s.ids, id)
+	switch {
+	case selected && index < 0:
+		s.ids = append(s.ids, id)
+	case !selected && index >= 0:
+		s.ids = slices.Delete(s.ids, index, index+1)
+	}
+	cm.updateMergeButton()
+}
+
+// isSelected reports whether an item is selected for merging
+func (cm *ClipboardManager) isSelected(id string) bool {
+	return slices.Contains(cm.selection.ids, id)
+}
+
+// updateMergeButton shows the number of selected items; merging needs two
+func (cm *ClipboardManager) updateMergeButton() {
+	s := &cm.selection
+	if s.mergeButton == nil {
+		return
+	}
+	s.mergeButton.SetText(fmt.Sprintf("Merge (%d)", len(s.ids)))
+	if len(s.ids) >= 2 {
+		s.mergeButton.Enable()
+	} else {
+		s.mergeButton.Disable()
+	}
+}
+
+// showMergeDialog asks for the separator and merges the selected items
+func (cm *ClipboardManager) showMergeDialog() {
+	ids := slices.Clone(cm.selection.ids)
+	custom := len(mergeSeparators) - 1
+
+	customEntry := widget.NewEntry()
+	customEntry.SetPlaceHolder(`e.g. " | ", \n for a newline`)
+	customEntry.Disable()
+
+	var names []string
+	for _, s := range mergeSeparators {
+		names = append(names, s.name)
+	}
+	separatorSelect := widget.NewSelect(names, nil)
+	separatorSelect.OnChanged = func(string) {
+		if separatorSelect.SelectedIndex() == custom {
+			customEntry.Enable()
+		} else {
+			customEntry.Disable()
+		}
+	}
+	separatorSelect.SetSelectedIndex(0)
+
+	dialog.ShowForm(fmt.Sprintf("Merge %d Items", len(ids)), "Merge", "Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Separator", separatorSelect),
+			widget.NewFormItem("Custom", customEntry),
+		},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			separator := mergeSeparators[separatorSelect.SelectedIndex()].separator
+			if separatorSelect.SelectedIndex() == custom {
+				separator = unescapeSeparator.Replace(customEntry.Text)
+			}
+
+			// Items are looked up by ID as the history may have changed since they were selected
+			if err := cm.mergeItems(ids, separator); err != nil {
+				dialog.ShowError(err, cm.window)
+				return
+			}
+			cm.setSelecting(false)
+		}, cm.window)
+}
+
+// mergeItems adds an item joining the content of the items with the given IDs.
+// The merged items are kept.
+func (cm *ClipboardManager) mergeItems(ids []string, separator string) error {
+	cm.mu.Lock()
+	var items []core.Item
+	for _, id := range ids {
+		if index := cm.history.IndexOfID(id); index >= 0 {
+			items = append(items, cm.history.At(index))
+		}
+	}
+	if len(items) < 2 {
+		cm.mu.Unlock()
+		return errors.New("select at least two items to merge")
+	}
+	if slices.ContainsFunc(items, func(item core.Item) bool { return item.Type == typeFiles }) && separator != "\n" {
+		cm.mu.Unlock()
+		return errors.New("file lists can only be merged with newlines")
+	}
+	cm.insertItem(core.JoinItems(items, separator))
+	cm.mu.Unlock()
+	return nil
+}
+
+// unpinnedCount returns the number of items counting against the history limit.
+// Callers hold cm.mu.
+func (cm *ClipboardManager) unpinnedCount() int {
+	unpinned := 0
+	for _, item := range cm.history.Items() {
+		if !item.Pinned {
+			unpinned++
+		}
+	}
+	return unpinned
+}
+
+// splitLimit returns how many parts an item can be split into. The item is kept,
+// so unless it is pinned it takes one of the places in the history.
+func splitLimit(item core.Item) int {
+	if item.Pinned {
+		return maxClipboardItems
+	}
+	return maxClipboardItems - 1
+}
+
+// splitEvictions returns how many other unpinned items adding n parts of an item
+// drops from the history
+func (cm *ClipboardManager) splitEvictions(n int) int {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return max(0, cm.unpinnedCount()+n-maxClipboardItems)
+}
+
+// splitItem adds the parts of the item with the given ID as separate items,
+// first part on top. The item itself is kept, making room for the parts drops
+// the oldest other unpinned items.
+func (cm *ClipboardManager) splitItem(id, delimiter string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	index := cm.history.IndexOfID(id)
+	if index < 0 {
+		return errors.New("the item no longer exists")
+	}
+	item := cm.history.At(index)
+	parts := core.SplitContent(item.Content, delimiter)
+	if len(parts) < 2 {
+		return errors.New("nothing to split: the delimiter doesn't occur between parts")
+	}
+	if limit := splitLimit(item); len(parts) > limit {
+		return fmt.Errorf("%d parts are more than the %d that fit in the history next to the item", len(parts), limit)
+	}
+
+	// The item is pinned while the parts are added, so they evict other items.
+	// Added last to first, so the list reads in the item's order, and saved and
+	// refreshed once for all parts.
+	cm.history.SetPinned(index, true)
+	var added []string
+	for i := len(parts) - 1; i >= 0; i-- {
+		if cm.history.Add(parts[i], detectContentType(parts[i])) {
+			added = append(added, cm.history.At(0).ID)
+		}
+	}
+	cm.history.SetPinned(cm.history.IndexOfID(id), item.Pinned)
+
+	// Unpinned again, the item may need the place of the oldest other unpinned one
+	for i := cm.history.Len() - 1; i >= 0 && cm.unpinnedCount() > maxClipboardItems; i-- {
+		if other := cm.history.At(i); !other.Pinned && other.ID != id {
+			cm.history.Remove(i)
+		}
+	}
+
+	cm.historyChanged()
+	for _, id := range added {
+		if index := cm.history.IndexOfID(id); index >= 0 {
+			cm.publishItem(index)
+			cm.runHooks(hookAdded, index)
+		}
+	}
+	return nil
+}
+
+// showSplitDialog lets the user split the item with the given ID by line or by a delimiter
+func (cm *ClipboardManager) showSplitDialog(id string) {
+	item, ok := cm.itemByID(id)
+	if !ok {
+		return
+	}
+
+	const byLine, byDelimiter = "Each line", "Delimiter"
+	delimiterEntry := widget.NewEntry()
+	delimiterEntry.SetPlaceHolder(`e.g. "," or ";", \t for a tab`)
+	delimiterEntry.Disable()
+
+	parts := widget.NewLabel("")
+	delimiter := func(mode string) string {
+		if mode == byLine {
+			return "\n"
+		}
+		return unescapeSeparator.Replace(delimiterEntry.Text)
+	}
+
+	modeRadio := widget.NewRadioGroup([]string{byLine, byDelimiter}, nil)
+	modeRadio.Horizontal = true
+	showParts := func() {
+		count := len(core.SplitContent(item.Content, delimiter(modeRadio.Selected)))
+		switch evicted := cm.splitEvictions(count); {
+		case count > splitLimit(item):
+			parts.SetText(fmt.Sprintf("%d parts, more than the %d that fit in the history next to the item", count, splitLimit(item)))
+		case evicted > 0:
+			parts.SetText(fmt.Sprintf("%d parts, making room drops the %d oldest unpinned items", count, evicted))
+		default:
+			parts.SetText(fmt.Sprintf("%d parts", count))
+		}
+	}
+	modeRadio.OnChanged = func(mode string) {
+		if mode == byDelimiter {
+			delimiterEntry.Enable()
+		} else {
+			delimiterEntry.Disable()
+		}
+		showParts()
+	}
+	modeRadio.Required = true
+	modeRadio.SetSelected(byLine)
+	delimiterEntry.OnChanged = func(string) { showParts() }
+
+	dialog.ShowForm("Split Item", "Split", "Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Split at", modeRadio),
+			widget.NewFormItem("Delimiter", delimiterEntry),
+			widget.NewFormItem("", parts),
+		},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			// The item is looked up by ID as the history may have changed while the dialog was open
+			if err := cm.splitItem(item.ID, delimiter(modeRadio.Selected)); err != nil {
+				dialog.ShowError(err, cm.window)
+			}
+		}, cm.window)
+}
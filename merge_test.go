@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitItem(t *testing.T) {
+	cm := newTestManager(t)
+	cm.addItem("a\r\n  indented\r\n\r\nc")
+	whole := itemID(cm, "a\r\n  indented\r\n\r\nc")
+
+	if err := cm.splitItem(whole, "\n"); err != nil {
+		t.Fatal(err)
+	}
+	cm.mu.Lock()
+	var got []string
+	for _, item := range cm.history.Items() {
+		got = append(got, item.Content)
+	}
+	cm.mu.Unlock()
+	if want := []string{"a", "  indented", "c", "a\r\n  indented\r\n\r\nc"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The parts and the item itself have to fit in the history
+	many := strings.Repeat("x,", maxClipboardItems-1) + "y"
+	cm.addItem(many)
+	if err := cm.splitItem(itemID(cm, many), ","); err == nil {
+		t.Error("split into more parts than fit next to the item")
+	}
+	checkConsistent(t, cm)
+}
+
+func TestSplitKeepsItemInFullHistory(t *testing.T) {
+	cm := newTestManager(t)
+	cm.addItem("a,b")
+	whole := itemID(cm, "a,b")
+	for i := range maxClipboardItems - 1 {
+		cm.addItem(fmt.Sprint("filler ", i))
+	}
+
+	// The item is the oldest, yet the parts evict the two oldest fillers instead
+	if err := cm.splitItem(whole, ","); err != nil {
+		t.Fatal(err)
+	}
+	checkConsistent(t, cm)
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.history.IndexOfID(whole) < 0 {
+		t.Error("the split item was evicted")
+	}
+	for _, content := range []string{"a", "b", "filler 2"} {
+		if cm.history.IndexOfContent(content) < 0 {
+			t.Errorf("%q is missing", content)
+		}
+	}
+	for _, content := range []string{"filler 0", "filler 1"} {
+		if cm.history.IndexOfContent(content) >= 0 {
+			t.Errorf("%q was kept", content)
+		}
+	}
+	if cm.history.At(cm.history.Len()-1).ID != whole || cm.history.At(cm.history.Len()-1).Pinned {
+		t.Error("the split item moved or stayed pinned")
+	}
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"NoteBoard/core"
+	"NoteBoard/screen"
+	"NoteBoard/wayland"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Overlay modes
+const (
+	overlayAuto = "auto" // When the compositor supports wlr-layer-shell
+	overlayOn   = "on"
+	overlayOff  = "off"
+)
+
+// Overlay anchors
+const (
+	anchorCenter = "center"
+	anchorCursor = "cursor"
+)
+
+const (
+	// Width of an item's line before it is cut to fit
+	overlayLineLength = 100
+
+	// An overlay still open after this long is forgotten, and is closed
+	overlayPickerTimeout = 5 * time.Minute
+
+	// Size of the overlay in surface pixels: a search row above overlayRows items
+	overlayWidth     = 640
+	overlayRows      = 12
+	overlayRowHeight = 28
+	overlayPadding   = 8
+	overlayHeight    = 2*overlayPadding + (overlayRows+1)*overlayRowHeight
+)
+
+// OverlaySettings configures the overlay picker, a keyboard-interactive
+// wlr-layer-shell surface above all windows
+type OverlaySettings struct {
+	Mode   string `json:"mode"`   // auto, on or off
+	Anchor string `json:"anchor"` // center or cursor, which only works on Hyprland
+}
+
+var (
+	layerShellOnce      sync.Once
+	layerShellSupported bool
+)
+
+// supportsLayerShell reports whether the compositor advertises wlr-layer-shell.
+// The answer doesn't change during a session, so the compositor is asked once.
+func supportsLayerShell() bool {
+	layerShellOnce.Do(func() {
+		if !isWaylandSession() {
+			return
+		}
+		supported, err := wayland.HasGlobal(wayland.LayerShell)
+		if err != nil {
+			slog.Warn("could not query the Wayland compositor", "err", err)
+			return
+		}
+		layerShellSupported = supported
+		slog.Debug("probed wlr-layer-shell support", "supported", supported)
+	})
+	return layerShellSupported
+}
+
+// usesOverlay reports whether the picker is presented as an overlay
+func (cm *ClipboardManager) usesOverlay() bool {
+	cm.mu.Lock()
+	mode := cm.overlay.Mode
+	cm.mu.Unlock()
+
+	switch mode {
+	case overlayOff:
+		return false
+	case overlayOn:
+		return isWaylandSession()
+	default:
+		// KWin advertises wlr-layer-shell too, but Plasma places the window through
+		// the KWin script instead
+		return !usesKWinPlacement() && supportsLayerShell()
+	}
+}
+
+// showPicker presents the history for picking an item: as an overlay if it is in
+// use and the history is unlocked, otherwise in the main window
+func (cm *ClipboardManager) showPicker() {
+	if !cm.usesOverlay() || cm.isLocked() {
+		cm.summonWindow()
+		return
+	}
+	// The shortcut pressed again while the overlay is open does nothing
+	if !cm.overlayOpen.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer cm.overlayOpen.Store(false)
+		if err := cm.runOverlayPicker(); err != nil {
+			slog.Warn("overlay picker failed, showing the window instead", "err", err)
+			cm.summonWindow()
+		}
+	}()
+}
+
+// runOverlayPicker lets the user pick an item in the overlay and copies it
+func (cm *ClipboardManager) runOverlayPicker() error {
+	cm.mu.Lock()
+	items := cm.history.Items()
+	anchor := cm.overlay.Anchor
+	cm.mu.Unlock()
+
+	// The theme is read on the main thread like everything else of the toolkit
+	styles := make(chan overlayStyle, 1)
+	cm.runUI(func() {
+		styles <- currentOverlayStyle()
+	})
+	style := <-styles
+	face, err := style.face()
+	if err != nil {
+		return err
+	}
+	defer face.Close()
+
+	options := wayland.OverlayOptions{Width: overlayWidth, Height: overlayHeight, Namespace: appID}
+	if anchor == anchorCursor {
+		if x, y, monitor, ok := cursorPosition(); ok {
+			// Keep the overlay on the monitor
+			options.Position = &image.Point{
+				X: max(0, min(x, monitor.Width-overlayWidth)),
+				Y: max(0, min(y, monitor.Height-overlayHeight)),
+			}
+		}
+	}
+
+	overlay, err := wayland.OpenOverlay(options)
+	if err != nil {
+		return err
+	}
+	defer overlay.Close()
+	overlay.SetDeadline(time.Now().Add(overlayPickerTimeout))
+
+	picker := newOverlayPicker(items)
+	img := image.NewRGBA(image.Rect(0, 0, overlayWidth, overlayHeight))
+	for {
+		picker.draw(img, face, style)
+		if err := overlay.Draw(img); err != nil {
+			return err
+		}
+
+		key, err := overlay.ReadKey()
+		switch {
+		case errors.Is(err, wayland.ErrClosed):
+			return nil
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			return fmt.Errorf("overlay was left open for %s", overlayPickerTimeout)
+		case err != nil:
+			return err
+		}
+
+		if picked, done := picker.handleKey(key); done {
+			if picked == nil {
+				return nil
+			}
+			return cm.copyItem(*picked)
+		}
+	}
+}
+
+// overlayPicker is the state of the overlay: the search typed so far and the
+// selected match
+type overlayPicker struct {
+	items    []core.Item
+	search   string
+	matches  []int // Indices of the items matching the search
+	selected int   // Index into matches
+	top      int   // First match shown
+}
+
+func newOverlayPicker(items []core.Item) *overlayPicker {
+	p := &overlayPicker{items: items}
+	p.filter()
+	return p
+}
+
+// filter finds the items matching the search and selects the first
+func (p *overlayPicker) filter() {
+	search := strings.ToLower(p.search)
+	p.matches = p.matches[:0]
+	for i, item := range p.items {
+		if core.Matches(item, search, nil) {
+			p.matches = append(p.matches, i)
+		}
+	}
+	p.selected, p.top = 0, 0
+}
+
+// move moves the selection by delta matches, scrolling it into view
+func (p *overlayPicker) move(delta int) {
+	p.selected = max(0, min(p.selected+delta, len(p.matches)-1))
+	if p.selected < p.top {
+		p.top = p.selected
+	}
+	if p.selected >= p.top+overlayRows {
+		p.top = p.selected - overlayRows + 1
+	}
+}
+
+// handleKey applies a key press. done is true once the picker is finished, with
+// the picked item unless it was cancelled.
+func (p *overlayPicker) handleKey(key wayland.Key) (picked *core.Item, done bool) {
+	switch key.Code {
+	case wayland.KeyEscape:
+		return nil, true
+	case wayland.KeyEnter, wayland.KeyKPEnter:
+		if len(p.matches) == 0 {
+			return nil, false
+		}
+		return &p.items[p.matches[p.selected]], true
+	case wayland.KeyUp:
+		p.move(-1)
+	case wayland.KeyDown, wayland.KeyTab:
+		p.move(1)
+	case wayland.KeyPageUp:
+		p.move(-overlayRows)
+	case wayland.KeyPageDown:
+		p.move(overlayRows)
+	case wayland.KeyBackspace:
+		if runes := []rune(p.search); len(runes) > 0 {
+			p.search = string(runes[:len(runes)-1])
+			p.filter()
+		}
+	default:
+		if key.Rune != 0 && !key.Ctrl {
+			p.search += string(key.Rune)
+			p.filter()
+		}
+	}
+	return nil, false
+}
+
+// overlayStyle is the look of the overlay, taken from the Fyne theme
+type overlayStyle struct {
+	font                                                   []byte // TrueType data
+	textSize                                               float32
+	background, foreground, placeholder, selection, border color.Color
+}
+
+// currentOverlayStyle reads the theme. It runs on the main thread.
+func currentOverlayStyle() overlayStyle {
+	return overlayStyle{
+		font:        theme.Font(fyne.TextStyle{}).Content(),
+		textSize:    theme.TextSize(),
+		background:  theme.Color(theme.ColorNameOverlayBackground),
+		foreground:  theme.Color(theme.ColorNameForeground),
+		placeholder: theme.Color(theme.ColorNamePlaceHolder),
+		selection:   theme.Color(theme.ColorNameSelection),
+		border:      theme.Color(theme.ColorNameInputBorder),
+	}
+}
+
+// face returns the style's font at its text size
+func (s overlayStyle) face() (font.Face, error) {
+	parsed, err := opentype.Parse(s.font)
+	if err != nil {
+		return nil, err
+	}
+	return opentype.NewFace(parsed, &opentype.FaceOptions{Size: float64(s.textSize), DPI: 72, Hinting: font.HintingFull})
+}
+
+// draw renders the search row and the visible matches
+func (p *overlayPicker) draw(img *image.RGBA, face font.Face, style overlayStyle) {
+	bounds := img.Bounds()
+	draw.Draw(img, bounds, image.NewUniform(style.border), image.Point{}, draw.Src)
+	draw.Draw(img, bounds.Inset(1), image.NewUniform(style.background), image.Point{}, draw.Src)
+
+	textWidth := bounds.Dx() - 4*overlayPadding
+	metrics := face.Metrics()
+	row := func(i int, text string, textColor color.Color) {
+		top := overlayPadding + i*overlayRowHeight
+		baseline := top + (overlayRowHeight+metrics.Ascent.Ceil()-metrics.Descent.Ceil())/2
+		drawer := font.Drawer{Dst: img, Src: image.NewUniform(textColor), Face: face, Dot: fixed.P(2*overlayPadding, baseline)}
+		drawer.DrawString(fitText(face, text, textWidth))
+	}
+
+	if p.search == "" {
+		row(0, "Search clipboard items...", style.placeholder)
+	} else {
+		row(0, p.search+"|", style.foreground)
+	}
+	if len(p.matches) == 0 {
+		row(1, "No matching items", style.placeholder)
+		return
+	}
+	for i := p.top; i < min(len(p.matches), p.top+overlayRows); i++ {
+		if i == p.selected {
+			top := overlayPadding + (i-p.top+1)*overlayRowHeight
+			rect := image.Rect(overlayPadding, top, bounds.Dx()-overlayPadding, top+overlayRowHeight)
+			draw.Draw(img, rect, image.NewUniform(style.selection), image.Point{}, draw.Over)
+		}
+		row(i-p.top+1, overlayLine(p.items[p.matches[i]]), style.foreground)
+	}
+}
+
+// fitText shortens text with an ellipsis until it is at most width pixels wide
+func fitText(face font.Face, text string, width int) string {
+	limit := fixed.I(width)
+	if font.MeasureString(face, text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && font.MeasureString(face, string(runes)+"…") > limit {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// overlayLine returns an item's content as a single line for the overlay
+func overlayLine(item core.Item) string {
+	text := item.Content
+	if item.Type == typeFiles {
+		text = fileListSummary(statFiles(item.Content))
+	}
+	text = strings.Join(strings.Fields(text), " ")
+
+	if runes := []rune(text); len(runes) > overlayLineLength {
+		text = string(runes[:overlayLineLength-1]) + "…"
+	}
+	if item.Pinned {
+		text = "[pinned] " + text
+	}
+	return text
+}
+
+// cursorPosition returns the pointer position and the monitor under it on
+// Hyprland, the only wlroots compositor that tells clients where the pointer is.
+// Hyprland reports global coordinates, while the overlay is positioned within its
+// output, which is the focused one under the pointer, so the position is made
+// relative to it.
+func cursorPosition() (x, y int, monitor screen.Monitor, ok bool) {
+	if os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") == "" {
+		return 0, 0, monitor, false
+	}
+	output, err := exec.Command("hyprctl", "cursorpos").Output()
+	if err != nil {
+		return 0, 0, monitor, false
+	}
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d, %d", &x, &y); err != nil {
+		return 0, 0, monitor, false
+	}
+
+	monitors, err := listMonitors()
+	if err != nil {
+		slog.Warn("could not list monitors", "err", err)
+		return 0, 0, monitor, false
+	}
+	monitor, _ = screen.At(monitors, x, y)
+	return x - monitor.X, y - monitor.Y, monitor, true
+}
+
+// setOverlay saves the overlay settings
+func (cm *ClipboardManager) setOverlay(settings OverlaySettings) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.overlay = settings
+
+	config := loadConfig()
+	config.Overlay = settings
+	saveConfig(config)
+}
+
+// createOverlaySettings creates the overlay picker section of the settings window
+func (cm *ClipboardManager) createOverlaySettings() fyne.CanvasObject {
+	status := widget.NewLabel("")
+	showStatus := func() {
+		if cm.usesOverlay() {
+			status.SetText("\"noteboard show\" opens the overlay")
+		} else {
+			status.SetText("\"noteboard show\" opens the main window")
+		}
+	}
+
+	modeSelect := widget.NewSelect([]string{overlayAuto, overlayOn, overlayOff}, nil)
+	modeSelect.SetSelected(cm.overlay.Mode)
+	modeSelect.OnChanged = func(mode string) {
+		settings := cm.overlay
+		settings.Mode = mode
+		cm.setOverlay(settings)
+		showStatus()
+	}
+
+	anchorSelect := widget.NewSelect([]string{anchorCenter, anchorCursor}, nil)
+	anchorSelect.SetSelected(cm.overlay.Anchor)
+	anchorSelect.OnChanged = func(anchor string) {
+		settings := cm.overlay
+		settings.Anchor = anchor
+		cm.setOverlay(settings)
+	}
+
+	showStatus()
+	return container.NewVBox(
+		widget.NewLabel("Overlay Picker (Sway, Hyprland and other wlroots compositors)"),
+		widget.NewLabel("Picks items in an overlay above all windows instead of the window.\n"+
+			"Type to search, Enter copies the selected item, Escape closes it.\n"+
+			"Opening it at the cursor only works on Hyprland."),
+		widget.NewForm(
+			widget.NewFormItem("Overlay", modeSelect),
+			widget.NewFormItem("Position", anchorSelect),
+		),
+		status,
+	)
+}
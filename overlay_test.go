@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"NoteBoard/core"
+	"NoteBoard/wayland"
+)
+
+func TestOverlayPickerKeys(t *testing.T) {
+	var items []core.Item
+	for _, content := range []string{"apple", "banana", "cherry", "apricot"} {
+		items = append(items, core.Item{Content: content})
+	}
+	p := newOverlayPicker(items)
+
+	keys := []wayland.Key{
+		{Rune: 'A'},
+		{Rune: 'x', Ctrl: true}, // Shortcuts don't type
+		{Code: wayland.KeyDown},
+		{Code: wayland.KeyDown}, // Stays on the last match
+	}
+	for _, key := range keys {
+		if _, done := p.handleKey(key); done {
+			t.Fatalf("%+v finished the picker", key)
+		}
+	}
+	if p.search != "A" || len(p.matches) != 3 || p.selected != 2 {
+		t.Fatalf("search %q matches %v with %d selected", p.search, p.matches, p.selected)
+	}
+
+	p.handleKey(wayland.Key{Rune: 'p'})
+	if picked, done := p.handleKey(wayland.Key{Code: wayland.KeyEnter}); !done || picked == nil || picked.Content != "apple" {
+		t.Errorf("picked %v, %v after typing %q", picked, done, p.search)
+	}
+
+	p.handleKey(wayland.Key{Rune: 'z'})
+	if picked, done := p.handleKey(wayland.Key{Code: wayland.KeyEnter}); done || picked != nil {
+		t.Error("picked an item without matches")
+	}
+	p.handleKey(wayland.Key{Code: wayland.KeyBackspace})
+	if p.search != "Ap" || len(p.matches) != 2 {
+		t.Errorf("search %q matches %v after backspace", p.search, p.matches)
+	}
+	if picked, done := p.handleKey(wayland.Key{Code: wayland.KeyEscape}); !done || picked != nil {
+		t.Error("escape didn't cancel")
+	}
+}
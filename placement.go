@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"NoteBoard/screen"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/go-vgo/robotgo"
+)
+
+// Placement modes of the main window
+const (
+	placeAtCursor   = "cursor"     // Next to the cursor, on the monitor under it
+	placeCentered   = "centered"   // In the middle of the monitor under the cursor
+	placeRemembered = "remembered" // Where it was last hidden, centred the first time
+)
+
+// placementOffset is the distance of windows placed at the cursor from it
+const placementOffset = 20
+
+// mainWindowSize is the size of the main window in toolkit units
+var mainWindowSize = fyne.NewSize(400, 500)
+
+// PlacementSettings configures where the global shortcut shows the main window
+type PlacementSettings struct {
+	Mode     string          `json:"mode"`               // cursor, centered or remembered
+	Position *windowPosition `json:"position,omitempty"` // Last position in remembered mode
+}
+
+// windowPosition is a window's top left corner in the global coordinate space
+type windowPosition struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// monitorSource is a tool listing the monitor layout and its parser
+type monitorSource struct {
+	command []string
+	parse   func([]byte) ([]screen.Monitor, error)
+}
+
+// currentMonitorSource picks the tool that knows the layout of this session
+func currentMonitorSource() monitorSource {
+	switch {
+	case !isWaylandSession():
+		return monitorSource{[]string{"xrandr", "--listmonitors"}, screen.ParseXrandr}
+	case os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "":
+		return monitorSource{[]string{"hyprctl", "monitors", "-j"}, screen.ParseHyprctl}
+	case os.Getenv("SWAYSOCK") != "":
+		return monitorSource{[]string{"swaymsg", "-t", "get_outputs", "-r"}, screen.ParseSway}
+	case isKDEPlasma():
+		return monitorSource{[]string{"kscreen-doctor", "-j"}, screen.ParseKScreenDoctor}
+	default:
+		return monitorSource{[]string{"wlr-randr"}, screen.ParseWlrRandr}
+	}
+}
+
+// listMonitors returns the monitor layout of the session
+func listMonitors() ([]screen.Monitor, error) {
+	source := currentMonitorSource()
+	output, err := exec.Command(source.command[0], source.command[1:]...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source.command[0], err)
+	}
+	monitors, err := source.parse(output)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source.command[0], err)
+	}
+	return monitors, nil
+}
+
+// monitorUnderCursor returns the cursor position, the monitor layout and the
+// monitor under the cursor on X11, falling back to the primary screen
+func monitorUnderCursor() (cursorX, cursorY int, monitors []screen.Monitor, current screen.Monitor) {
+	cursorX, cursorY = robotgo.Location()
+	monitors, err := listMonitors()
+	if err != nil {
+		slog.Warn("could not list monitors, placing on the primary one", "err", err)
+		screenWidth, screenHeight := robotgo.GetScreenSize()
+		monitors = []screen.Monitor{{Name: "primary", Width: screenWidth, Height: screenHeight, Scale: 1, Primary: true}}
+	}
+	current, _ = screen.At(monitors, cursorX, cursorY)
+	return cursorX, cursorY, monitors, current
+}
+
+// placementPosition returns where the main window of the given size in pixels
+// goes on X11, according to the placement mode
+func (cm *ClipboardManager) placementPosition(width, height int) (x, y int) {
+	cm.mu.Lock()
+	settings := cm.placement
+	cm.mu.Unlock()
+
+	cursorX, cursorY, monitors, monitor := monitorUnderCursor()
+	switch settings.Mode {
+	case placeRemembered:
+		if p := settings.Position; p != nil {
+			// The remembered monitor may be gone, so keep the window on the nearest one
+			remembered, _ := screen.At(monitors, p.X, p.Y)
+			return remembered.Clamp(p.X, p.Y, width, height)
+		}
+		return monitor.Centered(width, height)
+	case placeCentered:
+		return monitor.Centered(width, height)
+	default:
+		return monitor.NearCursor(cursorX, cursorY, width, height, placementOffset)
+	}
+}
+
+// summonWindow shows the main window where the placement mode puts it. On
+// Wayland the compositor places it, on KDE through the KWin script.
+func (cm *ClipboardManager) summonWindow() {
+	if cm.isWayland {
+		cm.showWindow()
+		return
+	}
+
+	// Monitor coordinates are in pixels, the window size in toolkit units
+	scale := cm.window.Canvas().Scale()
+	x, y := cm.placementPosition(int(mainWindowSize.Width*scale), int(mainWindowSize.Height*scale))
+
+	// Hide the window first so the window manager maps it anew
+	cm.runUI(func() {
+		cm.window.Hide()
+		cm.window.Resize(mainWindowSize)
+	})
+	cm.showWindow()
+
+	go func() {
+		// The toolkit can't position windows, and window managers place newly
+		// mapped windows themselves, so move it once it is mapped
+		time.Sleep(100 * time.Millisecond)
+		if err := moveX11Window(appName, x, y); err != nil {
+			slog.Warn("could not move window", "err", err)
+		}
+	}()
+}
+
+// hideWindow hides the main window, remembering its position in remembered mode
+func (cm *ClipboardManager) hideWindow() {
+	cm.mu.Lock()
+	remember := cm.placement.Mode == placeRemembered
+	cm.mu.Unlock()
+
+	if remember && !cm.isWayland {
+		if x, y, err := x11WindowPosition(appName); err != nil {
+			slog.Warn("could not remember window position", "err", err)
+		} else {
+			cm.setPlacementPosition(&windowPosition{X: x, Y: y})
+		}
+	}
+	cm.window.Hide()
+}
+
+// x11WindowID returns the ID of the first window titled exactly title
+func x11WindowID(title string) (string, error) {
+	output, err := exec.Command("xdotool", "search", "--name", "^"+regexp.QuoteMeta(title)+"$").Output()
+	if err != nil {
+		return "", fmt.Errorf("could not find window %q: %w", title, err)
+	}
+	id, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return id, nil
+}
+
+// moveX11Window moves a window's top left corner to x, y in global coordinates
+func moveX11Window(title string, x, y int) error {
+	id, err := x11WindowID(title)
+	if err != nil {
+		return err
+	}
+	return runCommand("xdotool", "windowmove", id, strconv.Itoa(x), strconv.Itoa(y))
+}
+
+// x11WindowPosition returns a window's top left corner in global coordinates
+func x11WindowPosition(title string) (x, y int, err error) {
+	id, err := x11WindowID(title)
+	if err != nil {
+		return 0, 0, err
+	}
+	output, err := exec.Command("xdotool", "getwindowgeometry", "--shell", id).Output()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Lines of KEY=value, e.g. X=-1920
+	var foundX, foundY bool
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "X":
+			x, err = strconv.Atoi(value)
+			foundX = err == nil
+		case "Y":
+			y, err = strconv.Atoi(value)
+			foundY = err == nil
+		}
+	}
+	if !foundX || !foundY {
+		return 0, 0, fmt.Errorf("unexpected xdotool output %q", output)
+	}
+	return x, y, nil
+}
+
+// setPlacement saves the placement settings and hands the mode to the KWin script
+func (cm *ClipboardManager) setPlacement(settings PlacementSettings) {
+	cm.mu.Lock()
+	cm.placement = settings
+	config := loadConfig()
+	config.Placement = settings
+	saveConfig(config)
+	cm.mu.Unlock()
+
+	if usesKWinPlacement() {
+		go func() {
+			if err := cm.loadKWinPlacement(cm.isKDEKeepAboveEnabled()); err != nil {
+				slog.Warn("could not reload KWin placement script", "err", err)
+			}
+		}()
+	}
+}
+
+// setPlacementPosition saves the remembered position of the main window
+func (cm *ClipboardManager) setPlacementPosition(position *windowPosition) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.placement.Position = position
+
+	config := loadConfig()
+	config.Placement = cm.placement
+	saveConfig(config)
+}
+
+// loadKWinPlacement (re)loads the KWin placement script with the placement settings
+func (cm *ClipboardManager) loadKWinPlacement(keepMainAbove bool) error {
+	cm.mu.Lock()
+	settings := cm.placement
+	cm.mu.Unlock()
+	return loadKWinPlacementScript(keepMainAbove, settings, cm.setPlacementPosition)
+}
+
+// placementMode returns the placement mode of the main window
+func (cm *ClipboardManager) placementMode() string {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.placement.Mode
+}
+
+// createPlacementSettings creates the window placement section of the settings window
+func (cm *ClipboardManager) createPlacementSettings() fyne.CanvasObject {
+	modes := []string{placeAtCursor, placeCentered, placeRemembered}
+	labels := []string{"At the cursor", "Centred on the monitor", "Where it was last"}
+
+	modeSelect := widget.NewSelect(labels, nil)
+	for i, mode := range modes {
+		if mode == cm.placementMode() {
+			modeSelect.SetSelectedIndex(i)
+		}
+	}
+	modeSelect.OnChanged = func(string) {
+		cm.mu.Lock()
+		settings := cm.placement
+		cm.mu.Unlock()
+		settings.Mode = modes[modeSelect.SelectedIndex()]
+		cm.setPlacement(settings)
+	}
+
+	// List the monitors so a wrong layout is easy to spot. Listing them runs
+	// external tools, so it doesn't hold up opening the settings.
+	monitorsLabel := widget.NewLabel("Monitors: …")
+	monitorsLabel.Wrapping = fyne.TextWrapWord
+	go func() {
+		var text string
+		if monitors, err := listMonitors(); err != nil {
+			text = "Could not list monitors: " + err.Error()
+		} else {
+			var names []string
+			for _, m := range monitors {
+				names = append(names, m.String())
+			}
+			text = "Monitors: " + strings.Join(names, ", ")
+		}
+		cm.runUI(func() {
+			monitorsLabel.SetText(text)
+		})
+	}()
+
+	section := container.NewVBox(
+		widget.NewLabel("Window Placement"),
+		widget.NewForm(widget.NewFormItem("Show the window", modeSelect)),
+		monitorsLabel,
+	)
+	switch {
+	case usesKWinPlacement():
+		section.Add(widget.NewLabel("KWin places the window when it is shown"))
+	case cm.isWayland:
+		section.Add(widget.NewLabel("The compositor decides where windows open on Wayland"))
+	}
+	return section
+}
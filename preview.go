@@ -0,0 +1,340 @@
+package main
+
+import (
+	"image/color"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"NoteBoard/core"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Delays before hovering a row shows its preview and leaving it hides the preview again
+const (
+	previewShowDelay = 300 * time.Millisecond
+	previewHideDelay = 200 * time.Millisecond
+)
+
+// previewHeight is the height of the preview pane below the list
+const previewHeight = 200
+
+// previewThumbnails is the most images of a file list shown in the preview
+const previewThumbnails = 4
+
+// imageExtensions are the file types the toolkit can decode for thumbnails
+var imageExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".svg": true}
+
+// previewPane shows the full content of an item below the list. Hovering a row's
+// preview handle shows it for as long as the pointer stays; selecting a row or
+// tapping the handle holds it until closed.
+type previewPane struct {
+	root       *fyne.Container
+	header     *widget.Label
+	body       *container.Scroll
+	menuButton *widget.Button
+
+	itemID    string    // Item shown, empty while hidden
+	shown     core.Item // Item as the body shows it, to rebuild it only on changes
+	held      bool      // Shown by selection or tap rather than hover
+	showTimer *time.Timer
+	hideTimer *time.Timer
+}
+
+// createPreviewPane creates the preview pane, hidden until an item is previewed
+func (cm *ClipboardManager) createPreviewPane() fyne.CanvasObject {
+	p := &previewPane{
+		header: widget.NewLabel(""),
+		body:   container.NewScroll(widget.NewLabel("")),
+	}
+	p.header.TextStyle = fyne.TextStyle{Italic: true}
+	p.header.Truncation = fyne.TextTruncateEllipsis
+	p.body.SetMinSize(fyne.NewSize(0, previewHeight))
+
+	copyButton := widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
+		if item, ok := cm.previewedItem(); ok {
+			cm.copyAndHide(item)
+		}
+	})
+	p.menuButton = widget.NewButtonWithIcon("", theme.MoreVerticalIcon(), func() {
+		if item, ok := cm.previewedItem(); ok {
+			cm.showRowMenu(item.ID, p.menuButton)
+		}
+	})
+	closeButton := widget.NewButtonWithIcon("", theme.CancelIcon(), cm.hidePreview)
+
+	buttons := container.NewHBox(copyButton, p.menuButton, closeButton)
+	p.root = container.NewBorder(
+		container.NewVBox(widget.NewSeparator(), container.NewBorder(nil, nil, nil, buttons, p.header)),
+		nil, nil, nil,
+		p.body,
+	)
+	p.root.Hide()
+
+	cm.preview = p
+	return p.root
+}
+
+// previewedItem returns the item shown in the preview pane
+func (cm *ClipboardManager) previewedItem() (core.Item, bool) {
+	if cm.preview == nil || cm.preview.itemID == "" {
+		return core.Item{}, false
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	index := cm.history.IndexOfID(cm.preview.itemID)
+	if index < 0 || cm.locked {
+		return core.Item{}, false
+	}
+	return cm.history.At(index), true
+}
+
+// showPreview shows an item in the preview pane. held keeps it shown when the
+// pointer leaves the row.
+func (cm *ClipboardManager) showPreview(itemID string, held bool) {
+	p := cm.preview
+	if p == nil {
+		return
+	}
+	p.stopTimers()
+
+	// Hovering doesn't replace an item the user picked
+	if p.held && !held && p.itemID != itemID {
+		return
+	}
+	p.itemID = itemID
+	p.held = p.held || held
+	cm.updatePreview()
+}
+
+// hidePreview hides the preview pane
+func (cm *ClipboardManager) hidePreview() {
+	p := cm.preview
+	if p == nil {
+		return
+	}
+	p.stopTimers()
+	p.itemID = ""
+	p.shown = core.Item{}
+	p.held = false
+	p.root.Hide()
+	if cm.list != nil {
+		cm.list.UnselectAll()
+	}
+}
+
+// updatePreview shows the current state of the previewed item, hiding the pane
+// if the item was removed or the history locked
+func (cm *ClipboardManager) updatePreview() {
+	p := cm.preview
+	if p == nil || p.itemID == "" {
+		return
+	}
+
+	item, ok := cm.previewedItem()
+	if !ok {
+		cm.hidePreview()
+		return
+	}
+
+	header := item.Type + " · " + item.Timestamp.Format("15:04:05")
+	if !item.Edited.IsZero() {
+		header += " · edited " + item.Edited.Format("15:04:05")
+	}
+	if item.Pinned {
+		header += " · pinned"
+	}
+	for _, tag := range item.Tags {
+		header += " #" + tag
+	}
+	p.header.SetText(header)
+
+	// Keep the scroll position unless the content changed
+	if item.ID != p.shown.ID || item.Content != p.shown.Content || item.Type != p.shown.Type {
+		p.shown = item
+		p.body.Content = previewContent(item)
+		p.body.ScrollToTop()
+		p.body.Refresh()
+	}
+	p.root.Show()
+}
+
+// hoverPreview shows an item's preview once the pointer rests on its row
+func (cm *ClipboardManager) hoverPreview(itemID string) {
+	p := cm.preview
+	if p == nil {
+		return
+	}
+	p.stopTimers()
+	p.showTimer = time.AfterFunc(previewShowDelay, func() {
+		cm.runUI(func() { cm.showPreview(itemID, false) })
+	})
+}
+
+// endHoverPreview hides a preview shown by hovering once the pointer has left the row
+func (cm *ClipboardManager) endHoverPreview() {
+	p := cm.preview
+	if p == nil {
+		return
+	}
+	p.stopTimers()
+	if p.held {
+		return
+	}
+	p.hideTimer = time.AfterFunc(previewHideDelay, func() {
+		cm.runUI(func() {
+			if !p.held {
+				cm.hidePreview()
+			}
+		})
+	})
+}
+
+// togglePreview holds an item's preview, or hides it if it is already held
+func (cm *ClipboardManager) togglePreview(itemID string) {
+	p := cm.preview
+	if p == nil {
+		return
+	}
+	if p.held && p.itemID == itemID {
+		cm.hidePreview()
+		return
+	}
+	cm.showPreview(itemID, true)
+}
+
+func (p *previewPane) stopTimers() {
+	if p.showTimer != nil {
+		p.showTimer.Stop()
+		p.showTimer = nil
+	}
+	if p.hideTimer != nil {
+		p.hideTimer.Stop()
+		p.hideTimer = nil
+	}
+}
+
+// previewContent returns the widgets showing an item's full content
+func previewContent(item core.Item) fyne.CanvasObject {
+	switch item.Type {
+	case typeJSON, typeCode:
+		// JSON and code are previewed in monospace with syntax highlighting
+		return widget.NewRichText(highlightedPreview(item.Content, item.Type)...)
+	case typeFiles:
+		return filesPreview(statFiles(item.Content))
+	case typePath:
+		path := strings.TrimSpace(item.Content)
+		if isImageFile(path) {
+			return container.NewVBox(thumbnail(path), wrappedLabel(path))
+		}
+	case typeColor:
+		if c, ok := parseColor(item.Content); ok {
+			swatch := canvas.NewRectangle(c)
+			swatch.SetMinSize(fyne.NewSize(0, previewHeight/2))
+			swatch.StrokeWidth = 1
+			swatch.StrokeColor = theme.Color(theme.ColorNameForeground)
+			return container.NewVBox(swatch, wrappedLabel(item.Content))
+		}
+	}
+	return wrappedLabel(item.Content)
+}
+
+// filesPreview lists the files of a file list item, with thumbnails of the first images
+func filesPreview(files []clipboardFile) fyne.CanvasObject {
+	list := container.NewVBox()
+	thumbnails := 0
+	for _, file := range files {
+		icon := theme.FileIcon()
+		switch {
+		case file.missing:
+			icon = theme.WarningIcon()
+		case file.isDir:
+			icon = theme.FolderIcon()
+		}
+
+		name := widget.NewLabel(describeFile(file))
+		name.Truncation = fyne.TextTruncateEllipsis
+		path := widget.NewLabel(file.path)
+		path.TextStyle = fyne.TextStyle{Italic: true}
+		path.Truncation = fyne.TextTruncateEllipsis
+		list.Add(container.NewBorder(nil, nil, widget.NewIcon(icon), nil, container.NewVBox(name, path)))
+
+		if !file.missing && isImageFile(file.path) && thumbnails < previewThumbnails {
+			list.Add(thumbnail(file.path))
+			thumbnails++
+		}
+	}
+	return list
+}
+
+// isImageFile reports whether a path names an image the preview can show
+func isImageFile(path string) bool {
+	return imageExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// thumbnail returns a scaled image of a file
+func thumbnail(path string) fyne.CanvasObject {
+	image := canvas.NewImageFromFile(path)
+	image.FillMode = canvas.ImageFillContain
+	image.ScaleMode = canvas.ImageScaleSmooth
+	image.SetMinSize(fyne.NewSize(0, previewHeight*3/4))
+	return image
+}
+
+func wrappedLabel(text string) *widget.Label {
+	label := widget.NewLabel(text)
+	label.Wrapping = fyne.TextWrapWord
+	return label
+}
+
+// previewHandle marks rows with more content than fits the row. Hovering it
+// previews the item, tapping it holds the preview.
+type previewHandle struct {
+	widget.BaseWidget
+	cm     *ClipboardManager
+	itemID string
+}
+
+func newPreviewHandle(cm *ClipboardManager) *previewHandle {
+	h := &previewHandle{cm: cm}
+	h.ExtendBaseWidget(h)
+	return h
+}
+
+// CreateRenderer shows the handle as an ellipsis
+func (h *previewHandle) CreateRenderer() fyne.WidgetRenderer {
+	text := canvas.NewText("…", theme.Color(theme.ColorNameForeground))
+	text.Alignment = fyne.TextAlignCenter
+	background := canvas.NewRectangle(color.Transparent)
+	return widget.NewSimpleRenderer(container.NewStack(background, container.NewCenter(text)))
+}
+
+// MinSize keeps the handle at least an icon wide so it is easy to hit
+func (h *previewHandle) MinSize() fyne.Size {
+	size := theme.IconInlineSize() + 2*theme.InnerPadding()
+	return fyne.NewSize(size, size)
+}
+
+// MouseIn is called when the pointer enters the handle
+func (h *previewHandle) MouseIn(*desktop.MouseEvent) {
+	h.cm.hoverPreview(h.itemID)
+}
+
+// MouseMoved is called when the pointer moves over the handle
+func (h *previewHandle) MouseMoved(*desktop.MouseEvent) {}
+
+// MouseOut is called when the pointer leaves the handle
+func (h *previewHandle) MouseOut() {
+	h.cm.endHoverPreview()
+}
+
+// Tapped holds the preview, or hides it if it is already held
+func (h *previewHandle) Tapped(*fyne.PointEvent) {
+	h.cm.togglePreview(h.itemID)
+}
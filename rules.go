@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Rule actions
+const (
+	ruleActionOpen       = "open"        // Open the content with xdg-open (URLs, files)
+	ruleActionOpenFolder = "open-folder" // Open the containing folder in the file manager
+	ruleActionCommand    = "command"     // Run a command with the content on stdin
+	ruleActionTransform  = "transform"   // Apply a configured action
+	ruleActionTag        = "tag"         // Tag the item
+)
+
+// ContentRule reacts to new clipboard content that matches a regular
+// expression or a detected content type
+type ContentRule struct {
+	Name      string `json:"name"`                // Label of the button offered on the row
+	Pattern   string `json:"pattern,omitempty"`   // Regular expression matched against the content
+	Type      string `json:"type,omitempty"`      // Detected content type, or "jira"
+	Action    string `json:"action"`              // open, open-folder, command, transform, tag
+	Command   string `json:"command,omitempty"`   // Command for "command" rules
+	Transform string `json:"transform,omitempty"` // Action name for "transform" rules
+	Tag       string `json:"tag,omitempty"`       // Tag for "tag" rules
+	Auto      bool   `json:"auto"`                // Run automatically instead of offering a button
+}
+
+// compiledRule is a ContentRule with its pattern compiled
+type compiledRule struct {
+	ContentRule
+	re *regexp.Regexp
+}
+
+var jiraPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]+-[0-9]+$`)
+
+// detectRuleType returns the type rules match against: "jira" for Jira keys,
+// otherwise the detected content type
+func detectRuleType(content string) string {
+	if jiraPattern.MatchString(strings.TrimSpace(content)) {
+		return "jira"
+	}
+	return detectContentType(content)
+}
+
+// compileRules compiles the configured rules, skipping invalid ones
+func compileRules(rules []ContentRule) []compiledRule {
+	var compiled []compiledRule
+
+	for _, rule := range rules {
+		c := compiledRule{ContentRule: rule}
+
+		if rule.Pattern != "" {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				slog.Warn("skipping rule with invalid pattern", "rule", rule.Name, "err", err)
+				continue
+			}
+			c.re = re
+		}
+
+		if c.re == nil && rule.Type == "" {
+			slog.Warn("skipping rule without pattern or type", "rule", rule.Name)
+			continue
+		}
+
+		compiled = append(compiled, c)
+	}
+
+	return compiled
+}
+
+// matches reports whether the rule applies to content
+func (r compiledRule) matches(content string) bool {
+	if r.Type != "" && detectRuleType(content) != r.Type {
+		return false
+	}
+	if r.re != nil && !r.re.MatchString(content) {
+		return false
+	}
+	return true
+}
+
+// matchingRules returns the rules that apply to content, either automatic or offered
+func (cm *ClipboardManager) matchingRules(content string, auto bool) []compiledRule {
+	var matched []compiledRule
+	for _, rule := range cm.rules {
+		if rule.Auto == auto && rule.matches(content) {
+			matched = append(matched, rule)
+		}
+	}
+	return matched
+}
+
+// applyAutoRules runs the automatic rules matching newly added content
+func (cm *ClipboardManager) applyAutoRules(content string) {
+	for _, rule := range cm.matchingRules(content, true) {
+		// Tagging only touches the history, other actions may block on external programs
+		if rule.Action == ruleActionTag {
+			cm.runRule(rule, content)
+			continue
+		}
+
+		go func(rule compiledRule) {
+			if err := cm.runRule(rule, content); err != nil {
+				slog.Warn("rule failed", "rule", rule.Name, "err", err)
+			}
+		}(rule)
+	}
+}
+
+// runOfferedRule runs a rule chosen from a row button, reporting errors in a dialog
+func (cm *ClipboardManager) runOfferedRule(rule compiledRule, content string) {
+	go func() {
+		if err := cm.runRule(rule, content); err != nil {
+			cm.showError(err, cm.window)
+		}
+	}()
+}
+
+// runRule performs the rule's action on content
+func (cm *ClipboardManager) runRule(rule compiledRule, content string) error {
+	target := strings.TrimSpace(content)
+
+	switch rule.Action {
+	case ruleActionOpen:
+		return openWithDefaultApp(expandPath(target))
+
+	case ruleActionOpenFolder:
+		path := expandPath(target)
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			path = filepath.Dir(path)
+		}
+		return openWithDefaultApp(path)
+
+	case ruleActionCommand:
+		_, err := runShellCommand(rule.Name, rule.Command, 0, content)
+		return err
+
+	case ruleActionTransform:
+		_, err := cm.transformContent(rule.Transform, content)
+		return err
+
+	case ruleActionTag:
+		cm.tagContent(content, rule.Tag)
+		return nil
+	}
+
+	return fmt.Errorf("rule %q has unknown action %q", rule.Name, rule.Action)
+}
+
+// openWithDefaultApp opens path with xdg-open. It returns once xdg-open started;
+// it is waited for in the background so it doesn't linger as a zombie.
+func openWithDefaultApp(path string) error {
+	cmd := exec.Command("xdg-open", path)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			slog.Warn("xdg-open failed", "path", path, "err", err)
+		}
+	}()
+	return nil
+}
+
+// tagContent adds tag to the history item holding content
+func (cm *ClipboardManager) tagContent(content, tag string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	index := cm.history.IndexOfContent(content)
+	if index < 0 {
+		return
+	}
+	if tags := cm.history.At(index).Tags; !containsKey(tags, tag) {
+		cm.history.SetTags(index, append(slices.Clone(tags), tag))
+		cm.historyChanged()
+	}
+}
+
+// expandPath turns file:// URLs and ~/ paths into plain filesystem paths
+func expandPath(path string) string {
+	if strings.HasPrefix(path, "file://") {
+		if u, err := url.Parse(path); err == nil {
+			return u.Path
+		}
+		return strings.TrimPrefix(path, "file://")
+	}
+
+	if strings.HasPrefix(path, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, path[2:])
+		}
+	}
+
+	return path
+}
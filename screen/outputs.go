@@ -0,0 +1,246 @@
+package screen
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// errNoMonitors is returned when a tool lists no enabled outputs
+var errNoMonitors = errors.New("no enabled monitors")
+
+// xrandrMonitor matches a line of "xrandr --listmonitors" such as
+// " 1: +*DP-1 2560/597x1440/336+-2560+0  DP-1", where * marks the primary monitor
+var xrandrMonitor = regexp.MustCompile(`^\s*\d+:\s+\+?(\*?)(\S+)\s+(\d+)/\d+x(\d+)/\d+\+(-?\d+)\+(-?\d+)`)
+
+// ParseXrandr parses the output of "xrandr --listmonitors". RandR monitors follow
+// Xinerama, and X11 has no per-output scale, so every monitor has a scale of 1.
+func ParseXrandr(output []byte) ([]Monitor, error) {
+	var monitors []Monitor
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		match := xrandrMonitor.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		m := Monitor{Name: match[2], Scale: 1, Primary: match[1] == "*"}
+		m.Width, _ = strconv.Atoi(match[3])
+		m.Height, _ = strconv.Atoi(match[4])
+		m.X, _ = strconv.Atoi(match[5])
+		m.Y, _ = strconv.Atoi(match[6])
+		monitors = append(monitors, m)
+	}
+	return found(monitors)
+}
+
+// ParseWlrRandr parses the output of wlr-randr, which works on wlroots compositors:
+//
+//	DP-1 "Dell Inc. DELL U2720Q (DP-1)"
+//	  Enabled: yes
+//	  Modes:
+//	    3840x2160 px, 59.997002 Hz (preferred, current)
+//	  Position: -1920,0
+//	  Transform: normal
+//	  Scale: 2.000000
+func ParseWlrRandr(output []byte) ([]Monitor, error) {
+	var (
+		monitors []Monitor
+		current  *Monitor
+		enabled  bool
+		rotated  bool
+		modeW    int
+		modeH    int
+	)
+	finish := func() {
+		if current != nil && enabled {
+			current.Width, current.Height = logicalSize(modeW, modeH, current.Scale, rotated)
+			monitors = append(monitors, *current)
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			finish()
+			name, _, _ := strings.Cut(line, " ")
+			current = &Monitor{Name: name, Scale: 1}
+			enabled, rotated, modeW, modeH = true, false, 0, 0
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		key, value, _ := strings.Cut(strings.TrimSpace(line), ":")
+		value = strings.TrimSpace(value)
+		switch {
+		case key == "Enabled":
+			enabled = value == "yes"
+		case key == "Position":
+			fmt.Sscanf(value, "%d,%d", &current.X, &current.Y)
+		case key == "Transform":
+			rotated = strings.Contains(value, "90") || strings.Contains(value, "270")
+		case key == "Scale":
+			if scale, err := strconv.ParseFloat(value, 64); err == nil && scale > 0 {
+				current.Scale = scale
+			}
+		case strings.Contains(line, " px,") && strings.Contains(line, "current"):
+			fmt.Sscanf(strings.TrimSpace(line), "%dx%d", &modeW, &modeH)
+		}
+	}
+	finish()
+	return found(monitors)
+}
+
+// hyprlandMonitor is an entry of "hyprctl monitors -j". Width and height are
+// the mode's pixels; x and y are already logical.
+type hyprlandMonitor struct {
+	Name      string  `json:"name"`
+	X         int     `json:"x"`
+	Y         int     `json:"y"`
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Scale     float64 `json:"scale"`
+	Transform int     `json:"transform"` // Odd values are rotated by 90° or 270°
+	Focused   bool    `json:"focused"`
+	Disabled  bool    `json:"disabled"`
+}
+
+// ParseHyprctl parses the output of "hyprctl monitors -j". Hyprland has no
+// primary monitor, so the focused one is marked primary.
+func ParseHyprctl(output []byte) ([]Monitor, error) {
+	var entries []hyprlandMonitor
+	if err := json.Unmarshal(output, &entries); err != nil {
+		return nil, err
+	}
+
+	var monitors []Monitor
+	for _, e := range entries {
+		if e.Disabled {
+			continue
+		}
+		m := Monitor{Name: e.Name, X: e.X, Y: e.Y, Scale: scaleOr1(e.Scale), Primary: e.Focused}
+		m.Width, m.Height = logicalSize(e.Width, e.Height, m.Scale, e.Transform%2 == 1)
+		monitors = append(monitors, m)
+	}
+	return found(monitors)
+}
+
+// swayOutput is an entry of "swaymsg -t get_outputs -r", whose rect is logical
+type swayOutput struct {
+	Name    string  `json:"name"`
+	Active  bool    `json:"active"`
+	Focused bool    `json:"focused"`
+	Scale   float64 `json:"scale"`
+	Rect    struct {
+		X      int `json:"x"`
+		Y      int `json:"y"`
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"rect"`
+}
+
+// ParseSway parses the output of "swaymsg -t get_outputs -r". Like Hyprland,
+// Sway has no primary monitor, so the focused one is marked primary.
+func ParseSway(output []byte) ([]Monitor, error) {
+	var entries []swayOutput
+	if err := json.Unmarshal(output, &entries); err != nil {
+		return nil, err
+	}
+
+	var monitors []Monitor
+	for _, e := range entries {
+		if !e.Active {
+			continue
+		}
+		monitors = append(monitors, Monitor{
+			Name:    e.Name,
+			X:       e.Rect.X,
+			Y:       e.Rect.Y,
+			Width:   e.Rect.Width,
+			Height:  e.Rect.Height,
+			Scale:   scaleOr1(e.Scale),
+			Primary: e.Focused,
+		})
+	}
+	return found(monitors)
+}
+
+// kscreenOutput is an output of "kscreen-doctor -j". Size is the mode's pixels,
+// pos is logical. Rotation 2 (left) and 8 (right) swap width and height.
+type kscreenOutput struct {
+	Name      string  `json:"name"`
+	Enabled   bool    `json:"enabled"`
+	Connected bool    `json:"connected"`
+	Primary   bool    `json:"primary"`  // Plasma 5
+	Priority  int     `json:"priority"` // Plasma 6, 1 is the primary output
+	Scale     float64 `json:"scale"`
+	Rotation  int     `json:"rotation"`
+	Pos       struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	} `json:"pos"`
+	Size struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"size"`
+}
+
+// ParseKScreenDoctor parses the output of "kscreen-doctor -j" on KDE Plasma
+func ParseKScreenDoctor(output []byte) ([]Monitor, error) {
+	var config struct {
+		Outputs []kscreenOutput `json:"outputs"`
+	}
+	if err := json.Unmarshal(output, &config); err != nil {
+		return nil, err
+	}
+
+	var monitors []Monitor
+	for _, o := range config.Outputs {
+		if !o.Enabled || !o.Connected {
+			continue
+		}
+		m := Monitor{
+			Name:    o.Name,
+			X:       o.Pos.X,
+			Y:       o.Pos.Y,
+			Scale:   scaleOr1(o.Scale),
+			Primary: o.Primary || o.Priority == 1,
+		}
+		m.Width, m.Height = logicalSize(o.Size.Width, o.Size.Height, m.Scale, o.Rotation == 2 || o.Rotation == 8)
+		monitors = append(monitors, m)
+	}
+	return found(monitors)
+}
+
+// logicalSize converts a mode's pixels to the size in the global coordinate space
+func logicalSize(width, height int, scale float64, rotated bool) (int, int) {
+	if rotated {
+		width, height = height, width
+	}
+	return int(math.Round(float64(width) / scale)), int(math.Round(float64(height) / scale))
+}
+
+func scaleOr1(scale float64) float64 {
+	if scale <= 0 {
+		return 1
+	}
+	return scale
+}
+
+func found(monitors []Monitor) ([]Monitor, error) {
+	if len(monitors) == 0 {
+		return nil, errNoMonitors
+	}
+	return monitors, nil
+}
@@ -0,0 +1,115 @@
+package screen
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseXrandr(t *testing.T) {
+	output := `Monitors: 2
+ 0: +*DP-1 2560/597x1440/336+0+0  DP-1
+ 1: +HDMI-1 1920/527x1080/296+-1920+180  HDMI-1
+`
+	monitors, err := ParseXrandr([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Monitor{layout[1], layout[0]}; !slices.Equal(monitors, want) {
+		t.Errorf("got %v, want %v", monitors, want)
+	}
+}
+
+func TestParseWlrRandr(t *testing.T) {
+	output := `DP-1 "Dell Inc. DELL U2720Q (DP-1)"
+  Enabled: yes
+  Modes:
+    3840x2160 px, 59.997002 Hz (preferred, current)
+    2560x1440 px, 59.951000 Hz
+  Position: 0,0
+  Transform: normal
+  Scale: 1.500000
+HDMI-A-1 "Samsung (HDMI-A-1)"
+  Enabled: yes
+  Modes:
+    1920x1080 px, 60.000000 Hz (current)
+  Position: -1080,0
+  Transform: 90
+  Scale: 1.000000
+eDP-1 "Laptop panel"
+  Enabled: no
+  Modes:
+    1920x1200 px, 60.000000 Hz (preferred)
+`
+	monitors, err := ParseWlrRandr([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Monitor{
+		{Name: "DP-1", X: 0, Y: 0, Width: 2560, Height: 1440, Scale: 1.5},
+		{Name: "HDMI-A-1", X: -1080, Y: 0, Width: 1080, Height: 1920, Scale: 1},
+	}
+	if !slices.Equal(monitors, want) {
+		t.Errorf("got %v, want %v", monitors, want)
+	}
+}
+
+func TestParseHyprctl(t *testing.T) {
+	output := `[{"id": 0, "name": "DP-1", "width": 2560, "height": 1440, "x": 0, "y": 0, "scale": 1.25, "transform": 0, "focused": true, "disabled": false},
+{"id": 1, "name": "DP-2", "width": 1920, "height": 1080, "x": -1080, "y": -200, "scale": 1.00, "transform": 1, "focused": false, "disabled": false}]`
+	monitors, err := ParseHyprctl([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Monitor{
+		{Name: "DP-1", X: 0, Y: 0, Width: 2048, Height: 1152, Scale: 1.25, Primary: true},
+		{Name: "DP-2", X: -1080, Y: -200, Width: 1080, Height: 1920, Scale: 1},
+	}
+	if !slices.Equal(monitors, want) {
+		t.Errorf("got %v, want %v", monitors, want)
+	}
+}
+
+func TestParseSway(t *testing.T) {
+	output := `[{"name": "eDP-1", "active": true, "focused": false, "scale": 2.0, "rect": {"x": 0, "y": 0, "width": 1280, "height": 800}},
+{"name": "DP-3", "active": true, "focused": true, "scale": 1.0, "rect": {"x": 1280, "y": -280, "width": 2560, "height": 1440}},
+{"name": "HDMI-A-1", "active": false, "rect": {"x": 0, "y": 0, "width": 0, "height": 0}}]`
+	monitors, err := ParseSway([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Monitor{
+		{Name: "eDP-1", X: 0, Y: 0, Width: 1280, Height: 800, Scale: 2},
+		{Name: "DP-3", X: 1280, Y: -280, Width: 2560, Height: 1440, Scale: 1, Primary: true},
+	}
+	if !slices.Equal(monitors, want) {
+		t.Errorf("got %v, want %v", monitors, want)
+	}
+}
+
+func TestParseKScreenDoctor(t *testing.T) {
+	output := `{"outputs": [
+{"name": "eDP-1", "enabled": true, "connected": true, "priority": 1, "scale": 1.5, "rotation": 1, "pos": {"x": 0, "y": 0}, "size": {"width": 2880, "height": 1800}},
+{"name": "DP-2", "enabled": true, "connected": true, "priority": 2, "scale": 1, "rotation": 8, "pos": {"x": -1200, "y": -400}, "size": {"width": 1920, "height": 1200}},
+{"name": "HDMI-A-1", "enabled": false, "connected": false, "scale": 1, "rotation": 1}
+]}`
+	monitors, err := ParseKScreenDoctor([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Monitor{
+		{Name: "eDP-1", X: 0, Y: 0, Width: 1920, Height: 1200, Scale: 1.5, Primary: true},
+		{Name: "DP-2", X: -1200, Y: -400, Width: 1200, Height: 1920, Scale: 1},
+	}
+	if !slices.Equal(monitors, want) {
+		t.Errorf("got %v, want %v", monitors, want)
+	}
+}
+
+func TestParseWithoutMonitors(t *testing.T) {
+	if _, err := ParseXrandr([]byte("Monitors: 0\n")); err == nil {
+		t.Error("ParseXrandr accepted an empty layout")
+	}
+	if _, err := ParseSway([]byte("[]")); err == nil {
+		t.Error("ParseSway accepted an empty layout")
+	}
+}
@@ -0,0 +1,91 @@
+// Package screen describes the monitor layout and places windows on it. The
+// toolkit only knows the primary monitor, so the layout comes from the output
+// tools of the display server, see outputs.go.
+package screen
+
+import "fmt"
+
+// Monitor is an output in the global coordinate space. X and Y may be negative,
+// e.g. for a monitor left of the primary one. Width and Height are logical, i.e.
+// the mode's pixels divided by the scale and rotated with the output.
+type Monitor struct {
+	Name    string
+	X, Y    int
+	Width   int
+	Height  int
+	Scale   float64
+	Primary bool
+}
+
+func (m Monitor) String() string {
+	return fmt.Sprintf("%s %dx%d%+d%+d ×%g", m.Name, m.Width, m.Height, m.X, m.Y, m.Scale)
+}
+
+// Contains reports whether a point lies on the monitor
+func (m Monitor) Contains(x, y int) bool {
+	return x >= m.X && x < m.X+m.Width && y >= m.Y && y < m.Y+m.Height
+}
+
+// distance returns the squared distance of a point from the monitor, 0 if on it
+func (m Monitor) distance(x, y int) int {
+	dx := max(m.X-x, 0, x-(m.X+m.Width-1))
+	dy := max(m.Y-y, 0, y-(m.Y+m.Height-1))
+	return dx*dx + dy*dy
+}
+
+// At returns the monitor under a point, or the nearest one if the point lies in
+// a gap of the layout. ok is false without monitors.
+func At(monitors []Monitor, x, y int) (monitor Monitor, ok bool) {
+	best := -1
+	for i, m := range monitors {
+		if m.Contains(x, y) {
+			return m, true
+		}
+		if best < 0 || m.distance(x, y) < monitors[best].distance(x, y) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return Monitor{}, false
+	}
+	return monitors[best], true
+}
+
+// Primary returns the primary monitor, or the first one if none is marked
+func Primary(monitors []Monitor) (Monitor, bool) {
+	for _, m := range monitors {
+		if m.Primary {
+			return m, true
+		}
+	}
+	if len(monitors) == 0 {
+		return Monitor{}, false
+	}
+	return monitors[0], true
+}
+
+// NearCursor places a window of the given size offset below and right of the
+// cursor, flipping it to the other side where it would leave the monitor
+func (m Monitor) NearCursor(cursorX, cursorY, width, height, offset int) (x, y int) {
+	x, y = cursorX+offset, cursorY+offset
+	if x+width > m.X+m.Width {
+		x = cursorX - offset - width
+	}
+	if y+height > m.Y+m.Height {
+		y = cursorY - offset - height
+	}
+	return m.Clamp(x, y, width, height)
+}
+
+// Centered places a window of the given size in the middle of the monitor
+func (m Monitor) Centered(width, height int) (x, y int) {
+	return m.Clamp(m.X+(m.Width-width)/2, m.Y+(m.Height-height)/2, width, height)
+}
+
+// Clamp moves a window as little as needed to lie on the monitor. Windows larger
+// than the monitor keep their top left corner on it.
+func (m Monitor) Clamp(x, y, width, height int) (int, int) {
+	x = max(m.X, min(x, m.X+m.Width-width))
+	y = max(m.Y, min(y, m.Y+m.Height-height))
+	return x, y
+}
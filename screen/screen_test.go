@@ -0,0 +1,71 @@
+package screen
+
+import "testing"
+
+// A 1920x1080 monitor left of and lower than a 2560x1440 primary at the origin
+var layout = []Monitor{
+	{Name: "HDMI-1", X: -1920, Y: 180, Width: 1920, Height: 1080, Scale: 1},
+	{Name: "DP-1", X: 0, Y: 0, Width: 2560, Height: 1440, Scale: 1, Primary: true},
+}
+
+func TestAt(t *testing.T) {
+	tests := []struct {
+		x, y int
+		want string
+	}{
+		{100, 100, "DP-1"},
+		{-1, 500, "HDMI-1"},
+		{-1920, 180, "HDMI-1"},
+		{0, 0, "DP-1"},
+		{-100, 170, "HDMI-1"}, // Gap above the left monitor
+		{3000, 100, "DP-1"},   // Right of every monitor
+	}
+	for _, tt := range tests {
+		m, ok := At(layout, tt.x, tt.y)
+		if !ok || m.Name != tt.want {
+			t.Errorf("At(%d, %d) = %s, want %s", tt.x, tt.y, m.Name, tt.want)
+		}
+	}
+
+	if _, ok := At(nil, 0, 0); ok {
+		t.Error("At found a monitor in an empty layout")
+	}
+}
+
+func TestNearCursor(t *testing.T) {
+	left, right := layout[0], layout[1]
+	tests := []struct {
+		name   string
+		m      Monitor
+		cx, cy int
+		wantX  int
+		wantY  int
+	}{
+		{"below right", right, 100, 100, 120, 120},
+		{"flipped at the right edge", right, 2500, 100, 2080, 120},
+		{"flipped at the bottom edge", right, 100, 1400, 120, 880},
+		{"negative coordinates", left, -1900, 200, -1880, 220},
+		{"flipped on the left monitor", left, -10, 1200, -430, 680},
+	}
+	for _, tt := range tests {
+		x, y := tt.m.NearCursor(tt.cx, tt.cy, 400, 500, 20)
+		if x != tt.wantX || y != tt.wantY {
+			t.Errorf("%s: got %d,%d, want %d,%d", tt.name, x, y, tt.wantX, tt.wantY)
+		}
+	}
+}
+
+func TestCenteredAndClamp(t *testing.T) {
+	left := layout[0]
+	if x, y := left.Centered(400, 500); x != -1160 || y != 470 {
+		t.Errorf("Centered = %d,%d", x, y)
+	}
+	// A remembered position off the left edge is pulled back onto the monitor
+	if x, y := left.Clamp(-2500, 1000, 400, 500); x != -1920 || y != 760 {
+		t.Errorf("Clamp = %d,%d", x, y)
+	}
+	// Windows larger than the monitor keep their top left corner on it
+	if x, y := left.Clamp(0, 0, 4000, 2000); x != -1920 || y != 180 {
+		t.Errorf("Clamp of a large window = %d,%d", x, y)
+	}
+}
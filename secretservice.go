@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Secret Service (org.freedesktop.secrets) D-Bus names. Both GNOME Keyring and
+// KWallet (since KDE Frameworks 5.97) implement this API.
+const (
+	secretServiceName       = "org.freedesktop.secrets"
+	secretServicePath       = "/org/freedesktop/secrets"
+	secretServiceInterface  = "org.freedesktop.Secret.Service"
+	secretItemInterface     = "org.freedesktop.Secret.Item"
+	secretPromptInterface   = "org.freedesktop.Secret.Prompt"
+	secretCollectionIface   = "org.freedesktop.Secret.Collection"
+	secretDefaultCollection = "/org/freedesktop/secrets/aliases/default"
+)
+
+// secretAttributes identify NoteBoard's history key in the keyring
+var secretAttributes = map[string]string{
+	"application": appID,
+	"purpose":     "history-key",
+}
+
+// secret is the Secret Service wire representation of a secret
+type secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// secretServiceKey returns the history key stored in the keyring, creating and
+// storing a random key if there is none yet and create is set
+func secretServiceKey(create bool) ([]byte, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to session bus: %w", err)
+	}
+
+	service := conn.Object(secretServiceName, secretServicePath)
+
+	// The "plain" algorithm transfers the secret unencrypted over the (local) session bus
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	err = service.Call(secretServiceInterface+".OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &session)
+	if err != nil {
+		return nil, fmt.Errorf("secret service unavailable: %w", err)
+	}
+	defer conn.Object(secretServiceName, session).Call("org.freedesktop.Secret.Session.Close", 0)
+
+	var unlocked, locked []dbus.ObjectPath
+	err = service.Call(secretServiceInterface+".SearchItems", 0, secretAttributes).Store(&unlocked, &locked)
+	if err != nil {
+		return nil, fmt.Errorf("could not search keyring: %w", err)
+	}
+
+	if len(unlocked) == 0 && len(locked) > 0 {
+		unlocked, err = unlockSecretObjects(conn, locked)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(unlocked) > 0 {
+		var s secret
+		err = conn.Object(secretServiceName, unlocked[0]).
+			Call(secretItemInterface+".GetSecret", 0, session).Store(&s)
+		if err != nil {
+			return nil, fmt.Errorf("could not read history key: %w", err)
+		}
+		if len(s.Value) != encryptionKeyLen {
+			return nil, errors.New("history key in keyring has an unexpected length")
+		}
+		return s.Value, nil
+	}
+
+	if !create {
+		return nil, errors.New("no history key found in keyring")
+	}
+
+	return createSecretServiceKey(conn, session)
+}
+
+// createSecretServiceKey stores a new random history key in the default collection
+func createSecretServiceKey(conn *dbus.Conn, session dbus.ObjectPath) ([]byte, error) {
+	key := make([]byte, encryptionKeyLen)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	if _, err := unlockSecretObjects(conn, []dbus.ObjectPath{secretDefaultCollection}); err != nil {
+		return nil, err
+	}
+
+	properties := map[string]dbus.Variant{
+		secretItemInterface + ".Label":      dbus.MakeVariant(appName + " history key"),
+		secretItemInterface + ".Attributes": dbus.MakeVariant(secretAttributes),
+	}
+	value := secret{Session: session, Value: key, ContentType: "application/octet-stream"}
+
+	var item, prompt dbus.ObjectPath
+	err := conn.Object(secretServiceName, secretDefaultCollection).
+		Call(secretCollectionIface+".CreateItem", 0, properties, value, true).Store(&item, &prompt)
+	if err != nil {
+		return nil, fmt.Errorf("could not store history key: %w", err)
+	}
+
+	if prompt != "/" {
+		if _, err := runSecretPrompt(conn, prompt); err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// unlockSecretObjects unlocks keyring items or collections, prompting the user if needed
+func unlockSecretObjects(conn *dbus.Conn, objects []dbus.ObjectPath) ([]dbus.ObjectPath, error) {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	err := conn.Object(secretServiceName, secretServicePath).
+		Call(secretServiceInterface+".Unlock", 0, objects).Store(&unlocked, &prompt)
+	if err != nil {
+		return nil, fmt.Errorf("could not unlock keyring: %w", err)
+	}
+
+	if prompt == "/" {
+		return unlocked, nil
+	}
+
+	result, err := runSecretPrompt(conn, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, ok := result.Value().([]dbus.ObjectPath)
+	if !ok {
+		return nil, errors.New("keyring unlock returned no items")
+	}
+	return paths, nil
+}
+
+// runSecretPrompt shows a keyring prompt and waits for the user to complete it
+func runSecretPrompt(conn *dbus.Conn, prompt dbus.ObjectPath) (dbus.Variant, error) {
+	signals := make(chan *dbus.Signal, 1)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	matchOptions := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(prompt),
+		dbus.WithMatchInterface(secretPromptInterface),
+	}
+	if err := conn.AddMatchSignal(matchOptions...); err != nil {
+		return dbus.Variant{}, err
+	}
+	defer conn.RemoveMatchSignal(matchOptions...)
+
+	if err := conn.Object(secretServiceName, prompt).Call(secretPromptInterface+".Prompt", 0, "").Err; err != nil {
+		return dbus.Variant{}, fmt.Errorf("could not show keyring prompt: %w", err)
+	}
+
+	for signal := range signals {
+		if signal.Path != prompt || signal.Name != secretPromptInterface+".Completed" || len(signal.Body) < 2 {
+			continue
+		}
+
+		dismissed, _ := signal.Body[0].(bool)
+		if dismissed {
+			return dbus.Variant{}, errors.New("keyring prompt was dismissed")
+		}
+		result, _ := signal.Body[1].(dbus.Variant)
+		return result, nil
+	}
+
+	return dbus.Variant{}, errors.New("keyring prompt was interrupted")
+}
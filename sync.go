@@ -0,0 +1,739 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"NoteBoard/core"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	syncLogPrefix = "noteboard-"
+	syncLogSuffix = ".jsonl"
+
+	syncOpPut    = "put"
+	syncOpDelete = "delete"
+
+	// Which items are synced
+	syncScopeKept = "kept" // Pinned and tagged items, the default
+	syncScopeAll  = "all"
+
+	// syncKeyFileName holds the salt of the sync passphrase in the sync folder
+	syncKeyFileName = "noteboard-sync-key.json"
+	syncKeyCheck    = "noteboard sync key"
+
+	// Deletions are remembered this long so devices that were offline don't bring items back
+	syncTombstoneAge = 30 * 24 * time.Hour
+
+	// Wait for a syncing tool to finish writing before merging
+	syncMergeDelay = 2 * time.Second
+)
+
+// SyncSettings configures syncing the history through a shared folder
+type SyncSettings struct {
+	Enabled   bool   `json:"enabled"`
+	Directory string `json:"directory"` // e.g. a Syncthing or Nextcloud folder
+	DeviceID  string `json:"deviceId"`  // Names this device's log in the folder
+	Scope     string `json:"scope"`     // "kept" (pinned and tagged items) or "all"
+
+	// The sync key sealed with the history key, set while history encryption is on
+	KeyNonce  []byte `json:"keyNonce,omitempty"`
+	SealedKey []byte `json:"sealedKey,omitempty"`
+}
+
+// syncKeyFile is kept in the sync folder so every device derives the same sync
+// key from the same passphrase
+type syncKeyFile struct {
+	Salt       []byte `json:"salt"`
+	Iterations int    `json:"iterations"`
+	Nonce      []byte `json:"nonce"`
+	Check      []byte `json:"check"` // syncKeyCheck sealed with the key, to tell a wrong passphrase
+}
+
+// sealedItem is an item of a sync event encrypted with the sync key
+type sealedItem struct {
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// syncEvent is one line of a device's append-only sync log. Every device only
+// writes its own log, so syncing tools never have to resolve conflicts.
+type syncEvent struct {
+	Time   time.Time   `json:"time"`
+	Device string      `json:"device"`
+	Op     string      `json:"op"`
+	ID     string      `json:"id"`
+	Item   *core.Item  `json:"item,omitempty"`
+	Sealed *sealedItem `json:"sealed,omitempty"` // Replaces Item in encrypted logs
+}
+
+// newDeviceID returns a readable, unique name for this device's sync log
+func newDeviceID() string {
+	host, _ := os.Hostname()
+	host = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return -1
+	}, host)
+	if host == "" {
+		host = "device"
+	}
+
+	b := make([]byte, 4)
+	rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
+
+// syncLogPath returns the path of this device's log in the sync folder. Callers hold cm.mu.
+func (cm *ClipboardManager) syncLogPath() string {
+	return filepath.Join(cm.sync.Directory, syncLogPrefix+cm.sync.DeviceID+syncLogSuffix)
+}
+
+// isSyncLog reports whether name is a device log in the sync folder
+func isSyncLog(name string) bool {
+	name = filepath.Base(name)
+	return strings.HasPrefix(name, syncLogPrefix) && strings.HasSuffix(name, syncLogSuffix)
+}
+
+// readSyncLogs reads the logs of all devices, oldest event first, opening sealed
+// items with key. Lines that are incomplete or corrupt, e.g. while a file is being
+// synced, and items sealed with another key are skipped.
+func readSyncLogs(dir string, key []byte) ([]syncEvent, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []syncEvent
+	for _, entry := range entries {
+		if entry.IsDir() || !isSyncLog(entry.Name()) {
+			continue
+		}
+
+		file, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			slog.Warn("could not read sync log", "file", entry.Name(), "err", err)
+			continue
+		}
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(nil, maxItemSize)
+		for scanner.Scan() {
+			var event syncEvent
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.ID == "" {
+				continue
+			}
+			if event.Sealed != nil {
+				item, err := openSyncItem(key, event.Sealed)
+				if err != nil {
+					continue
+				}
+				event.Item, event.Sealed = item, nil
+			}
+			if event.Op == syncOpPut && event.Item == nil {
+				continue
+			}
+			events = append(events, event)
+		}
+		file.Close()
+	}
+
+	// Events of one device keep their order, ties between devices go by device ID
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Time.Equal(events[j].Time) {
+			return events[i].Time.Before(events[j].Time)
+		}
+		return events[i].Device < events[j].Device
+	})
+
+	return events, nil
+}
+
+// sealSyncItem encrypts an item for the sync log
+func sealSyncItem(key []byte, item core.Item) (*sealedItem, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	nonce, sealed, err := sealData(key, data)
+	if err != nil {
+		return nil, err
+	}
+	return &sealedItem{Nonce: nonce, Data: sealed}, nil
+}
+
+// openSyncItem decrypts an item sealed by sealSyncItem
+func openSyncItem(key []byte, sealed *sealedItem) (*core.Item, error) {
+	if key == nil {
+		return nil, errors.New("no sync key")
+	}
+	data, err := openData(key, sealed.Nonce, sealed.Data)
+	if err != nil {
+		return nil, err
+	}
+	var item core.Item
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// putEvent returns the event logging an item, sealed if there is a sync key.
+// Callers hold cm.mu.
+func (cm *ClipboardManager) putEvent(at time.Time, item core.Item) (syncEvent, error) {
+	event := syncEvent{Time: at, Device: cm.sync.DeviceID, Op: syncOpPut, ID: item.ID}
+	if cm.syncKey == nil {
+		event.Item = &item
+		return event, nil
+	}
+
+	sealed, err := sealSyncItem(cm.syncKey, item)
+	if err != nil {
+		return event, err
+	}
+	event.Sealed = sealed
+	return event, nil
+}
+
+// syncAllowed reports whether the logs can be read and written: the history must
+// be unlocked and, while it is encrypted, the sync key known, so nothing is ever
+// written to the sync folder in plaintext. Callers hold cm.mu.
+func (cm *ClipboardManager) syncAllowed() bool {
+	return cm.sync.Enabled && !cm.locked && (!cm.encryption.Enabled || cm.syncKey != nil)
+}
+
+// shouldSync reports whether an item goes into the sync log. Outside the "all"
+// scope items start syncing once pinned or tagged; items already in the logs keep
+// syncing so their changes reach the other devices. Callers hold cm.mu.
+func (cm *ClipboardManager) shouldSync(item core.Item) bool {
+	if _, synced := cm.syncState[item.ID]; synced {
+		return true
+	}
+	return cm.sync.Scope == syncScopeAll || item.Pinned || len(item.Tags) > 0
+}
+
+// noteDeleted records items the user deleted, so the next sync write deletes them
+// on the other devices too. Items that are evicted aren't deleted elsewhere.
+// Callers hold cm.mu.
+func (cm *ClipboardManager) noteDeleted(ids ...string) {
+	if cm.syncState == nil {
+		return
+	}
+	if cm.syncDeleted == nil {
+		cm.syncDeleted = make(map[string]bool)
+	}
+	for _, id := range ids {
+		cm.syncDeleted[id] = true
+	}
+}
+
+// latestSyncEvents returns the last event for every item ID
+func latestSyncEvents(events []syncEvent) map[string]syncEvent {
+	latest := make(map[string]syncEvent)
+	for _, event := range events {
+		latest[event.ID] = event
+	}
+	return latest
+}
+
+// sameItem reports whether two versions of an item are identical
+func sameItem(a, b core.Item) bool {
+	return a.Content == b.Content && a.Type == b.Type && a.Pinned == b.Pinned &&
+		a.Timestamp.Equal(b.Timestamp) && a.Edited.Equal(b.Edited) && slices.Equal(a.Tags, b.Tags)
+}
+
+// mergeSync merges the logs of all devices into the history. Local items the logs
+// don't know yet are kept and logged. The same content copied on several devices
+// is folded into one item, and the others are logged as deleted so all devices
+// end up with the same one. Callers hold cm.mu.
+func (cm *ClipboardManager) mergeSync() {
+	if !cm.syncAllowed() {
+		return
+	}
+
+	events, err := readSyncLogs(cm.sync.Directory, cm.syncKey)
+	if err != nil {
+		slog.Warn("could not read sync folder", "err", err)
+		return
+	}
+	latest := latestSyncEvents(events)
+
+	var merged []core.Item
+	synced := make(map[string]core.Item)
+	for id, event := range latest {
+		if event.Op == syncOpPut {
+			item := *event.Item
+			item.ID = id
+			merged = append(merged, item)
+			synced[id] = item
+		}
+	}
+	for _, item := range cm.history.Items() {
+		if _, known := latest[item.ID]; !known {
+			merged = append(merged, item)
+		}
+	}
+
+	merged, folded := core.FoldDuplicates(merged)
+	cm.history.Replace(merged)
+	cm.syncState = synced
+	cm.noteDeleted(folded...)
+	cm.historyChanged()
+}
+
+// syncChanges appends the differences between the synced items and the last
+// synced state to this device's log. Only items the user deleted are logged as
+// deletions; evicted ones are just no longer updated. Callers hold cm.mu.
+func (cm *ClipboardManager) syncChanges() {
+	deleted := cm.syncDeleted
+	cm.syncDeleted = nil
+
+	// The state is unknown until the logs have been merged
+	if !cm.syncAllowed() || cm.syncState == nil {
+		return
+	}
+
+	now := time.Now()
+	var events []syncEvent
+	current := make(map[string]core.Item)
+	for _, item := range cm.history.Items() {
+		if !cm.shouldSync(item) {
+			continue
+		}
+		current[item.ID] = item
+		if previous, ok := cm.syncState[item.ID]; !ok || !sameItem(previous, item) {
+			event, err := cm.putEvent(now, item)
+			if err != nil {
+				slog.Warn("could not write sync log", "err", err)
+				return
+			}
+			events = append(events, event)
+		}
+	}
+	for id := range cm.syncState {
+		if _, ok := current[id]; !ok && deleted[id] {
+			events = append(events, syncEvent{Time: now, Device: cm.sync.DeviceID, Op: syncOpDelete, ID: id})
+		}
+	}
+	cm.syncState = current
+
+	if len(events) == 0 {
+		return
+	}
+
+	file, err := os.OpenFile(cm.syncLogPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		slog.Warn("could not write sync log", "err", err)
+		return
+	}
+	defer file.Close()
+
+	// One write per batch, so other devices never see half of it
+	var buf strings.Builder
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		encoder.Encode(event)
+	}
+	if _, err := file.WriteString(buf.String()); err != nil {
+		slog.Warn("could not write sync log", "err", err)
+	}
+}
+
+// compactSyncLog rewrites this device's log to the current items and recent
+// deletions of all devices, so the logs don't grow forever. Callers hold cm.mu.
+func (cm *ClipboardManager) compactSyncLog() {
+	if !cm.syncAllowed() {
+		return
+	}
+
+	events, err := readSyncLogs(cm.sync.Directory, cm.syncKey)
+	if err != nil {
+		return
+	}
+	latest := latestSyncEvents(events)
+
+	var buf strings.Builder
+	encoder := json.NewEncoder(&buf)
+	for _, item := range cm.history.Items() {
+		if _, synced := cm.syncState[item.ID]; !synced {
+			continue
+		}
+		at := item.Timestamp
+		if last, ok := latest[item.ID]; ok {
+			at = last.Time
+		}
+		event, err := cm.putEvent(at, item)
+		if err != nil {
+			slog.Warn("could not compact sync log", "err", err)
+			return
+		}
+		encoder.Encode(event)
+	}
+	for id, event := range latest {
+		if event.Op == syncOpDelete && time.Since(event.Time) < syncTombstoneAge {
+			event.Device = cm.sync.DeviceID
+			event.ID = id
+			encoder.Encode(event)
+		}
+	}
+
+	path := cm.syncLogPath()
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(buf.String()), 0600); err != nil {
+		slog.Warn("could not compact sync log", "err", err)
+		return
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		slog.Warn("could not compact sync log", "err", err)
+	}
+}
+
+// startSync merges the other devices' logs and watches the sync folder for changes.
+// Callers hold cm.mu.
+func (cm *ClipboardManager) startSync() {
+	if !cm.syncAllowed() {
+		if cm.sync.Enabled && !cm.locked {
+			slog.Warn("sync is paused until the sync passphrase is set")
+		}
+		return
+	}
+
+	if err := os.MkdirAll(cm.sync.Directory, 0700); err != nil {
+		slog.Warn("could not create sync folder", "err", err)
+		return
+	}
+
+	cm.mergeSync()
+	cm.compactSyncLog()
+
+	if cm.syncWatcher != nil {
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("could not watch sync folder", "err", err)
+		return
+	}
+	if err := watcher.Add(cm.sync.Directory); err != nil {
+		watcher.Close()
+		slog.Warn("could not watch sync folder", "err", err)
+		return
+	}
+	cm.syncWatcher = watcher
+
+	go cm.watchSyncFolder(watcher, cm.syncLogPath())
+}
+
+// watchSyncFolder merges the logs whenever another device's log changes
+func (cm *ClipboardManager) watchSyncFolder(watcher *fsnotify.Watcher, ownLog string) {
+	merge := func() {
+		cm.mu.Lock()
+		defer cm.mu.Unlock()
+		cm.mergeSync()
+	}
+
+	var timer *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !isSyncLog(event.Name) || event.Name == ownLog || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(syncMergeDelay, merge)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("sync folder watch error", "err", err)
+		}
+	}
+}
+
+// stopSync stops watching the sync folder. The log stays in place. Callers hold cm.mu.
+func (cm *ClipboardManager) stopSync() {
+	if cm.syncWatcher != nil {
+		cm.syncWatcher.Close()
+		cm.syncWatcher = nil
+	}
+	cm.syncState = nil
+	cm.syncDeleted = nil
+}
+
+// deriveSyncKey derives the sync key from the passphrase shared by all devices,
+// with the salt kept in the sync folder. The first device creates the salt; the
+// others are told if their passphrase doesn't match.
+func deriveSyncKey(dir, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("a passphrase is required")
+	}
+
+	path := filepath.Join(dir, syncKeyFileName)
+	var file syncKeyFile
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", syncKeyFileName, err)
+		}
+		key, err := deriveKey(passphrase, file.Salt, file.Iterations)
+		if err != nil {
+			return nil, err
+		}
+		if check, err := openData(key, file.Nonce, file.Check); err != nil || string(check) != syncKeyCheck {
+			return nil, errors.New("the passphrase doesn't match the one the other devices use")
+		}
+		return key, nil
+
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	file = syncKeyFile{Salt: make([]byte, 16), Iterations: pbkdf2Iterations}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if file.Nonce, file.Check, err = sealData(key, []byte(syncKeyCheck)); err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, encoded, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// setSyncPassphrase derives the sync key, seals it with the history key for the
+// next unlock and restarts syncing with it. Deriving takes a while, so callers run
+// it in a goroutine of its own.
+func (cm *ClipboardManager) setSyncPassphrase(passphrase string) error {
+	cm.mu.Lock()
+	dir, historyKey := cm.sync.Directory, cm.historyKey
+	cm.mu.Unlock()
+
+	if dir == "" {
+		return errors.New("choose a sync folder first")
+	}
+	if historyKey == nil {
+		return errors.New("unlock the history first")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	key, err := deriveSyncKey(dir, passphrase)
+	if err != nil {
+		return err
+	}
+	nonce, sealed, err := sealData(historyKey, key)
+	if err != nil {
+		return err
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.stopSync()
+	cm.syncKey = key
+	cm.sync.KeyNonce, cm.sync.SealedKey = nonce, sealed
+	config := loadConfig()
+	config.Sync = cm.sync
+	saveConfig(config)
+
+	cm.startSync()
+	return nil
+}
+
+// openSyncKey unseals the sync key with the history key. Callers hold cm.mu.
+func (cm *ClipboardManager) openSyncKey() {
+	cm.syncKey = nil
+	if !cm.encryption.Enabled || cm.historyKey == nil || cm.sync.SealedKey == nil {
+		return
+	}
+
+	key, err := openData(cm.historyKey, cm.sync.KeyNonce, cm.sync.SealedKey)
+	if err != nil {
+		slog.Warn("could not open the sync key, set the sync passphrase again", "err", err)
+		return
+	}
+	cm.syncKey = key
+}
+
+// resealSyncKey seals the sync key with a new history key, or forgets it when
+// encryption is turned off. Callers hold cm.mu and save the config.
+func (cm *ClipboardManager) resealSyncKey(config *Config) {
+	cm.sync.KeyNonce, cm.sync.SealedKey = nil, nil
+	if !cm.encryption.Enabled {
+		cm.syncKey = nil
+	} else if cm.syncKey != nil {
+		nonce, sealed, err := sealData(cm.historyKey, cm.syncKey)
+		if err != nil {
+			slog.Warn("could not seal the sync key", "err", err)
+			cm.syncKey = nil
+		} else {
+			cm.sync.KeyNonce, cm.sync.SealedKey = nonce, sealed
+		}
+	}
+	config.Sync = cm.sync
+}
+
+// setSync stores the sync settings and starts or stops syncing
+func (cm *ClipboardManager) setSync(settings SyncSettings) error {
+	if settings.Enabled && settings.Directory == "" {
+		return fmt.Errorf("choose a sync folder first")
+	}
+	if settings.DeviceID == "" {
+		settings.DeviceID = newDeviceID()
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.stopSync()
+	cm.sync = settings
+
+	config := loadConfig()
+	config.Sync = settings
+	saveConfig(config)
+
+	cm.startSync()
+	return nil
+}
+
+// createSyncSettings builds the sync section of the settings window
+func (cm *ClipboardManager) createSyncSettings(settingsWindow fyne.Window) fyne.CanvasObject {
+	folderLabel := widget.NewLabel(cm.sync.Directory)
+	if cm.sync.Directory == "" {
+		folderLabel.SetText("No folder chosen")
+	}
+	folderLabel.Truncation = fyne.TextTruncateEllipsis
+
+	syncToggle := widget.NewCheck("Sync through a shared folder", nil)
+	syncToggle.SetChecked(cm.sync.Enabled)
+	syncToggle.OnChanged = func(checked bool) {
+		settings := cm.sync
+		settings.Enabled = checked
+		if err := cm.setSync(settings); err != nil {
+			dialog.ShowError(err, settingsWindow)
+			syncToggle.SetChecked(cm.sync.Enabled)
+		}
+	}
+
+	chooseButton := widget.NewButton("Choose…", func() {
+		dialog.ShowFolderOpen(func(folder fyne.ListableURI, err error) {
+			if err != nil || folder == nil {
+				return
+			}
+			settings := cm.sync
+			settings.Directory = folder.Path()
+			if err := cm.setSync(settings); err != nil {
+				dialog.ShowError(err, settingsWindow)
+				return
+			}
+			folderLabel.SetText(settings.Directory)
+		}, settingsWindow)
+	})
+
+	scopeCheck := widget.NewCheck("Only sync pinned and tagged items", nil)
+	scopeCheck.SetChecked(cm.sync.Scope != syncScopeAll)
+	scopeCheck.OnChanged = func(kept bool) {
+		settings := cm.sync
+		settings.Scope = syncScopeAll
+		if kept {
+			settings.Scope = syncScopeKept
+		}
+		if err := cm.setSync(settings); err != nil {
+			dialog.ShowError(err, settingsWindow)
+		}
+	}
+
+	content := container.NewVBox(
+		syncToggle,
+		container.NewBorder(nil, nil, nil, chooseButton, folderLabel),
+		scopeCheck,
+	)
+
+	if !cm.encryption.Enabled {
+		warning := widget.NewLabel("Note: the sync folder is not encrypted. Turn on history encryption to encrypt it.")
+		warning.Wrapping = fyne.TextWrapWord
+		warning.Importance = widget.WarningImportance
+		content.Add(warning)
+		return content
+	}
+
+	keyStatus := widget.NewLabel("")
+	keyStatus.Wrapping = fyne.TextWrapWord
+	updateKeyStatus := func() {
+		cm.mu.Lock()
+		hasKey := cm.syncKey != nil
+		cm.mu.Unlock()
+		if hasKey {
+			keyStatus.SetText("The sync folder is encrypted with the sync passphrase.")
+			keyStatus.Importance = widget.MediumImportance
+		} else {
+			keyStatus.SetText("Sync is paused until the sync passphrase is set. Use the same one on every device.")
+			keyStatus.Importance = widget.WarningImportance
+		}
+		keyStatus.Refresh()
+	}
+	updateKeyStatus()
+
+	var passphraseButton *widget.Button
+	passphraseButton = widget.NewButton("Set sync passphrase…", func() {
+		passphrase := widget.NewPasswordEntry()
+		dialog.ShowForm("Sync Passphrase", "Set", "Cancel",
+			[]*widget.FormItem{widget.NewFormItem("Passphrase", passphrase)},
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				// Deriving the key takes a moment
+				passphraseButton.Disable()
+				go func() {
+					err := cm.setSyncPassphrase(passphrase.Text)
+					cm.runUI(func() {
+						passphraseButton.Enable()
+						if err != nil {
+							dialog.ShowError(err, settingsWindow)
+						}
+						updateKeyStatus()
+					})
+				}()
+			}, settingsWindow)
+	})
+	content.Add(keyStatus)
+	content.Add(passphraseButton)
+
+	return content
+}
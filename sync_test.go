@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"NoteBoard/core"
+)
+
+// startTestSync enables syncing the test manager through a temporary folder
+func startTestSync(t *testing.T, cm *ClipboardManager) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.sync = SyncSettings{Enabled: true, Directory: dir, DeviceID: "test"}
+	cm.startSync()
+	t.Cleanup(func() {
+		cm.mu.Lock()
+		defer cm.mu.Unlock()
+		cm.stopSync()
+	})
+	return dir
+}
+
+// latestSynced returns the last logged event of every item
+func latestSynced(t *testing.T, dir string, key []byte) map[string]syncEvent {
+	t.Helper()
+	events, err := readSyncLogs(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return latestSyncEvents(events)
+}
+
+// itemID returns the ID of the item holding content
+func itemID(cm *ClipboardManager, content string) string {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	index := cm.history.IndexOfContent(content)
+	if index < 0 {
+		return ""
+	}
+	return cm.history.At(index).ID
+}
+
+func TestSyncScopeAndEvictions(t *testing.T) {
+	cm := newTestManager(t)
+	dir := startTestSync(t, cm)
+
+	cm.addItem("plain")
+	cm.addItem("tagged")
+	cm.mu.Lock()
+	cm.history.SetTags(0, []string{"work"})
+	cm.historyChanged()
+	cm.mu.Unlock()
+	tagged := itemID(cm, "tagged")
+
+	// Only pinned and tagged items are synced by default
+	latest := latestSynced(t, dir, nil)
+	if len(latest) != 1 || latest[tagged].Op != syncOpPut {
+		t.Fatalf("logged %v, want only the tagged item", latest)
+	}
+
+	// Evicting the tagged item doesn't delete it on the other devices
+	for i := range maxClipboardItems {
+		cm.addItem(fmt.Sprint(i))
+	}
+	if itemID(cm, "tagged") != "" {
+		t.Fatal("the tagged item wasn't evicted")
+	}
+	if event := latestSynced(t, dir, nil)[tagged]; event.Op != syncOpPut {
+		t.Errorf("eviction was logged as %q", event.Op)
+	}
+
+	// Deleting a synced item does
+	cm.addItem("pinned")
+	cm.setPinned(0, true)
+	pinned := itemID(cm, "pinned")
+	cm.removeItem(0)
+	if event := latestSynced(t, dir, nil)[pinned]; event.Op != syncOpDelete {
+		t.Errorf("deletion was logged as %q", event.Op)
+	}
+}
+
+func TestSyncLogIsEncrypted(t *testing.T) {
+	cm := newTestManager(t)
+	cm.encryption.Enabled = true
+	cm.historyKey = bytes.Repeat([]byte{1}, encryptionKeyLen)
+	dir := startTestSync(t, cm)
+	logPath := filepath.Join(dir, syncLogPrefix+"test"+syncLogSuffix)
+
+	// Nothing is written until the sync passphrase is set
+	cm.addItem("secret")
+	cm.setPinned(0, true)
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		t.Fatalf("the log was written without a sync key: %v", err)
+	}
+
+	if err := cm.setSyncPassphrase("shared"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("secret")) {
+		t.Errorf("the log holds the content in plaintext:\n%s", data)
+	}
+
+	id := itemID(cm, "secret")
+	cm.mu.Lock()
+	key := cm.syncKey
+	cm.mu.Unlock()
+	if event := latestSynced(t, dir, key)[id]; event.Item == nil || event.Item.Content != "secret" {
+		t.Errorf("the item couldn't be read back: %+v", event)
+	}
+	if _, ok := latestSynced(t, dir, nil)[id]; ok {
+		t.Error("the item was read without the key")
+	}
+
+	// Other devices need the same passphrase
+	if _, err := deriveSyncKey(dir, "wrong"); err == nil {
+		t.Error("a wrong passphrase was accepted")
+	}
+}
+
+func TestEditSyncsFoldedDuplicate(t *testing.T) {
+	cm := newTestManager(t)
+	dir := startTestSync(t, cm)
+
+	cm.addItem("a")
+	cm.setPinned(0, true)
+	cm.addItem("b")
+	cm.setPinned(0, true)
+	a, b := itemID(cm, "a"), itemID(cm, "b")
+
+	// Editing "b" into "a" folds the old "a" into it, deleting it on the other devices
+	if _, err := cm.editItem(b, "a"); err != nil {
+		t.Fatal(err)
+	}
+	latest := latestSynced(t, dir, nil)
+	if event := latest[a]; event.Op != syncOpDelete {
+		t.Errorf("the folded duplicate was logged as %q", event.Op)
+	}
+	if event := latest[b]; event.Op != syncOpPut || event.Item == nil || event.Item.Content != "a" {
+		t.Errorf("the edited item was logged as %+v", event)
+	}
+}
+
+func TestSyncFoldsDuplicates(t *testing.T) {
+	cm := newTestManager(t)
+	dir := startTestSync(t, cm)
+
+	cm.addItem("shared")
+	cm.setPinned(0, true)
+	local := itemID(cm, "shared")
+
+	// The same content copied earlier on another device
+	other := core.Item{ID: "other-item", Content: "shared", Type: "text", Timestamp: time.Now().Add(-time.Hour), Tags: []string{"work"}}
+	line, err := json.Marshal(syncEvent{Time: time.Now(), Device: "other", Op: syncOpPut, ID: other.ID, Item: &other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, syncLogPrefix+"other"+syncLogSuffix), append(line, '\n'), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cm.mu.Lock()
+	cm.mergeSync()
+	items := cm.history.Items()
+	cm.mu.Unlock()
+
+	if len(items) != 1 || items[0].ID != local || !items[0].Pinned || !slices.Equal(items[0].Tags, []string{"work"}) {
+		t.Fatalf("merged into %+v, want the newer item with the pin and tags of both", items)
+	}
+	latest := latestSynced(t, dir, nil)
+	if event := latest[other.ID]; event.Op != syncOpDelete {
+		t.Errorf("the folded item was logged as %q", event.Op)
+	}
+	if event := latest[local]; event.Op != syncOpPut || event.Item == nil || !slices.Equal(event.Item.Tags, []string{"work"}) {
+		t.Errorf("the kept item was logged as %+v", event)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// setSearchText filters the list by a case-insensitive search text
+func (cm *ClipboardManager) setSearchText(text string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.touch()
+	cm.searchText = text
+	cm.updateFilter()
+	cm.refreshUI()
+}
+
+// toggleTagFilter adds or removes a tag from the active tag filter
+func (cm *ClipboardManager) toggleTagFilter(tag string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.touch()
+	if cm.tagFilter[tag] {
+		delete(cm.tagFilter, tag)
+	} else {
+		cm.tagFilter[tag] = true
+	}
+	cm.updateFilter()
+	cm.refreshUI()
+}
+
+// updateFilter recomputes the visible items from the search text and tag filter.
+// Callers hold cm.mu.
+func (cm *ClipboardManager) updateFilter() {
+	// Forget filters on tags that no item has anymore
+	tags := cm.history.Tags()
+	for tag := range cm.tagFilter {
+		if !containsKey(tags, tag) {
+			delete(cm.tagFilter, tag)
+		}
+	}
+
+	cm.filtered = cm.history.Search(cm.searchText, cm.tagFilter)
+}
+
+// itemIndex maps a list row to the index of the item it shows, or -1.
+// Callers hold cm.mu.
+func (cm *ClipboardManager) itemIndex(id widget.ListItemID) int {
+	if id < 0 || id >= len(cm.filtered) {
+		return -1
+	}
+	return cm.filtered[id]
+}
+
+// updateTagBar rebuilds the chip bar under the search entry. It runs on the main thread.
+func (cm *ClipboardManager) updateTagBar(tags []string, tagFilter map[string]bool) {
+	if cm.tagBar == nil {
+		return
+	}
+
+	cm.tagBar.RemoveAll()
+	for _, tag := range tags {
+		chip := widget.NewButton("#"+tag, func() {
+			cm.toggleTagFilter(tag)
+		})
+		if tagFilter[tag] {
+			chip.Importance = widget.HighImportance
+		} else {
+			chip.Importance = widget.LowImportance
+		}
+		cm.tagBar.Add(chip)
+	}
+	cm.tagBar.Refresh()
+}
+
+// createTagBar creates the scrollable chip bar, which stays empty while no item is tagged
+func (cm *ClipboardManager) createTagBar() fyne.CanvasObject {
+	cm.tagBar = container.NewHBox()
+	cm.refreshUI()
+	return container.NewHScroll(cm.tagBar)
+}
+
+// setItemTags replaces the tags of the item with the given ID
+func (cm *ClipboardManager) setItemTags(id string, tags []string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.history.SetTags(cm.history.IndexOfID(id), tags) {
+		cm.historyChanged()
+	}
+}
+
+// parseTags splits comma or space separated tags, dropping '#' prefixes and duplicates
+func parseTags(text string) []string {
+	var tags []string
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ' '
+	}) {
+		tag := strings.TrimPrefix(strings.TrimSpace(field), "#")
+		if tag != "" && !containsKey(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// showTagDialog lets the user edit the tags of the item with the given ID
+func (cm *ClipboardManager) showTagDialog(id string) {
+	item, ok := cm.itemByID(id)
+	if !ok {
+		return
+	}
+
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("work, snippet, todo")
+	entry.SetText(strings.Join(item.Tags, ", "))
+
+	dialog.ShowForm("Edit Tags", "Save", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Tags", entry)},
+		func(ok bool) {
+			// The item is looked up by ID as the history may have changed while the dialog was open
+			if ok {
+				cm.setItemTags(item.ID, parseTags(entry.Text))
+			}
+		}, cm.window)
+}
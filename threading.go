@@ -0,0 +1,68 @@
+package main
+
+import (
+	"maps"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+// NoteBoard's state is shared between the Fyne event loop, the clipboard monitor,
+// the control socket, the HTTP API and the sync and LAN goroutines:
+//
+//   - cm.mu guards the history (items, pins, filter, lock state, sync state) and the
+//     settings read by background goroutines. Methods documented with "Callers hold
+//     cm.mu" expect the lock to be held, all other methods take it themselves.
+//   - Widgets are only changed on Fyne's main thread. Fyne callbacks already run
+//     there, other goroutines hand their widget changes to runUI. Neither happens
+//     while holding cm.mu, as refreshing the list calls back into the list's data
+//     functions.
+//   - State only widgets use, such as the row selection for merging, stays on the
+//     main thread and needs no lock.
+
+// runUI runs f on Fyne's main thread. It doesn't wait for f, so it is safe to call
+// while holding cm.mu.
+func (cm *ClipboardManager) runUI(f func()) {
+	cm.ui(f)
+}
+
+// refreshUI schedules an update of the list, tag bar, locked view and preview.
+// Refreshes requested while one is pending are merged into it.
+func (cm *ClipboardManager) refreshUI() {
+	if cm.refreshPending.CompareAndSwap(false, true) {
+		cm.runUI(func() {
+			cm.refreshPending.Store(false)
+			cm.refreshView()
+		})
+	}
+}
+
+// refreshView updates the widgets showing the history. It runs on the main thread.
+func (cm *ClipboardManager) refreshView() {
+	cm.mu.Lock()
+	tags := cm.history.Tags()
+	tagFilter := maps.Clone(cm.tagFilter)
+	locked := cm.locked
+	cm.mu.Unlock()
+
+	cm.updateTagBar(tags, tagFilter)
+	cm.updateLockedView(locked)
+	if cm.list != nil {
+		cm.list.Refresh()
+	}
+	cm.updatePreview()
+}
+
+// showError shows an error dialog from any goroutine
+func (cm *ClipboardManager) showError(err error, parent fyne.Window) {
+	cm.runUI(func() {
+		dialog.ShowError(err, parent)
+	})
+}
+
+// showInformation shows an information dialog from any goroutine
+func (cm *ClipboardManager) showInformation(title, message string, parent fyne.Window) {
+	cm.runUI(func() {
+		dialog.ShowInformation(title, message, parent)
+	})
+}
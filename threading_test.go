@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"NoteBoard/core"
+)
+
+// newTestManager returns a manager without a window or saved history
+func newTestManager(t *testing.T) *ClipboardManager {
+	t.Setenv("NOTEBOARD_CONFIG_DIR", t.TempDir())
+	return &ClipboardManager{
+		history:   core.NewHistory(maxClipboardItems),
+		clipboard: &core.MemoryClipboard{},
+		tagFilter: make(map[string]bool),
+		ui:        new(testMainThread).do,
+	}
+}
+
+// testMainThread stands in for Fyne's main thread, which tests don't run: it runs
+// the functions handed to runUI one at a time, without blocking the caller
+type testMainThread struct {
+	mu  sync.Mutex // Held while a function runs
+	ran atomic.Int64
+}
+
+func (m *testMainThread) do(f func()) {
+	go func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		f()
+		m.ran.Add(1)
+	}()
+}
+
+// checkConsistent fails if the filter points past the history
+func checkConsistent(t *testing.T, cm *ClipboardManager) {
+	t.Helper()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	for _, index := range cm.filtered {
+		if index < 0 || index >= cm.history.Len() {
+			t.Errorf("filtered index %d, but there are %d items", index, cm.history.Len())
+		}
+	}
+	unpinned := 0
+	for _, item := range cm.history.Items() {
+		if !item.Pinned {
+			unpinned++
+		}
+	}
+	if unpinned > maxClipboardItems {
+		t.Errorf("%d unpinned items exceed the limit of %d", unpinned, maxClipboardItems)
+	}
+}
+
+func TestConcurrentHistoryChanges(t *testing.T) {
+	cm := newTestManager(t)
+
+	var wg sync.WaitGroup
+	for worker := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				cm.addItem(fmt.Sprintf("item %d-%d", worker, i%60))
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 200 {
+			cm.removeItem(i % 10)
+			cm.setPinned(i%5, i%2 == 0)
+		}
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 20 {
+			cm.clearItems()
+			cm.setSearchText(fmt.Sprint(i % 3))
+			time.Sleep(time.Millisecond)
+		}
+		cm.setSearchText("")
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			cm.mu.Lock()
+			for row := range cm.filtered {
+				_ = cm.history.At(cm.itemIndex(row)).Content
+			}
+			cm.mu.Unlock()
+		}
+	}()
+	wg.Wait()
+
+	checkConsistent(t, cm)
+}
+
+func TestClearKeepsPinnedItems(t *testing.T) {
+	cm := newTestManager(t)
+	cm.addItem("first")
+	cm.addItem("second")
+	cm.addItem("third")
+	cm.setPinned(1, true)
+
+	cm.clearItems()
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.history.Len() != 1 || cm.history.At(0).Content != "second" || !cm.history.At(0).Pinned {
+		t.Fatalf("expected only the pinned item to remain, got %d items", cm.history.Len())
+	}
+}
+
+func TestHistoryChangesRefreshThroughRunUI(t *testing.T) {
+	cm := newTestManager(t)
+	main := new(testMainThread)
+	cm.ui = main.do
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cm.addItem(fmt.Sprint("item ", i))
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for cm.refreshPending.Load() || main.ran.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the list was not refreshed through runUI")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if ran := main.ran.Load(); ran > 50 {
+		t.Errorf("%d refreshes ran for 50 items", ran)
+	}
+}
@@ -0,0 +1,158 @@
+package wayland
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Most file descriptors a single read takes along
+const maxFDs = 28
+
+// conn is a connection to the compositor for clients creating objects of their
+// own. Its reads keep the file descriptors sent along with events, in order, for
+// the events that carry one to take.
+type conn struct {
+	*net.UnixConn
+	fds    []int
+	nextID uint32
+}
+
+// dial connects to the compositor
+func dial() (*conn, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return nil, err
+	}
+	c, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return newConn(c.(*net.UnixConn)), nil
+}
+
+// newConn wraps a connection on which no objects were created yet. Globals uses
+// the first IDs.
+func newConn(c *net.UnixConn) *conn {
+	return &conn{UnixConn: c, nextID: callbackID + 1}
+}
+
+// Read reads event data, keeping the file descriptors that come with it
+func (c *conn) Read(b []byte) (int, error) {
+	oob := make([]byte, syscall.CmsgSpace(4*maxFDs))
+	n, oobn, _, _, err := c.ReadMsgUnix(b, oob)
+	if oobn > 0 {
+		messages, parseErr := syscall.ParseSocketControlMessage(oob[:oobn])
+		if parseErr != nil {
+			return n, parseErr
+		}
+		for _, message := range messages {
+			fds, parseErr := syscall.ParseUnixRights(&message)
+			if parseErr != nil {
+				return n, parseErr
+			}
+			c.fds = append(c.fds, fds...)
+		}
+	}
+	if n == 0 && err == nil && len(b) > 0 {
+		// recvmsg reports the end of the stream as an empty read
+		return 0, io.EOF
+	}
+	return n, err
+}
+
+// takeFD returns the oldest file descriptor received and not taken yet
+func (c *conn) takeFD() (int, error) {
+	if len(c.fds) == 0 {
+		return -1, errors.New("event is missing its file descriptor")
+	}
+	fd := c.fds[0]
+	c.fds = c.fds[1:]
+	return fd, nil
+}
+
+// newID allocates an object ID
+func (c *conn) newID() uint32 {
+	id := c.nextID
+	c.nextID++
+	return id
+}
+
+// send sends a request, passing fds along with it
+func (c *conn) send(object uint32, opcode uint16, args []byte, fds ...int) error {
+	var oob []byte
+	if len(fds) > 0 {
+		oob = syscall.UnixRights(fds...)
+	}
+	_, _, err := c.WriteMsgUnix(message(object, opcode, args), oob, nil)
+	return err
+}
+
+// args encodes request arguments. Integers and object IDs are passed as uint32
+// or int32, strings as string.
+func args(values ...any) []byte {
+	var b []byte
+	for _, value := range values {
+		switch v := value.(type) {
+		case uint32:
+			b = binary.LittleEndian.AppendUint32(b, v)
+		case int32:
+			b = binary.LittleEndian.AppendUint32(b, uint32(v))
+		case string:
+			b = append(b, stringArg(v)...)
+		default:
+			panic(fmt.Sprintf("wayland: unsupported argument type %T", value))
+		}
+	}
+	return b
+}
+
+// stringArg encodes a string: its length including the NUL terminator, then the
+// bytes, padded to 32 bits
+func stringArg(s string) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(s)+1))
+	b = append(b, s...)
+	b = append(b, 0)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// argReader decodes event arguments. The first decoding error sticks, and later
+// reads return zero values.
+type argReader struct {
+	b   []byte
+	err error
+}
+
+func (r *argReader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.b) < 4 {
+		r.err = errors.New("short event")
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *argReader) string() string {
+	length := int(r.uint32())
+	padded := (length + 3) &^ 3
+	if r.err != nil || length == 0 || len(r.b) < padded {
+		if r.err == nil {
+			r.err = errors.New("malformed string in event")
+		}
+		return ""
+	}
+	s := string(r.b[:length-1])
+	r.b = r.b[padded:]
+	return s
+}
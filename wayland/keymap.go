@@ -0,0 +1,157 @@
+package wayland
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Keymap holds the characters the keys of an XKB keymap type, by Linux evdev key
+// code and shift level. Only the first group (layout) and the first two levels
+// are kept, which is enough to type a search; keys typing keysyms outside
+// Latin-1 and Unicode keysyms type nothing.
+type Keymap map[uint32][]rune
+
+// XKB key codes are evdev codes plus 8
+const xkbKeycodeOffset = 8
+
+var (
+	keycodePattern = regexp.MustCompile(`<([^>]+)>\s*=\s*(\d+)\s*;`)
+	aliasPattern   = regexp.MustCompile(`alias\s*<([^>]+)>\s*=\s*<([^>]+)>\s*;`)
+	keyPattern     = regexp.MustCompile(`key\s*<([^>]+)>\s*\{([^}]*)\}`)
+
+	// The first group of a key is either its "symbols[...]= [...]" entry or, in the
+	// short form, the list opening its body
+	groupPattern      = regexp.MustCompile(`symbols\[[^\]]*\]\s*=\s*\[([^\]]*)\]`)
+	shortGroupPattern = regexp.MustCompile(`^\s*\[([^\]]*)\]`)
+)
+
+// ParseKeymap reads the keymap a compositor sends in the XKB text format
+func ParseKeymap(text string) Keymap {
+	codes := make(map[string]uint32)
+	for _, match := range keycodePattern.FindAllStringSubmatch(text, -1) {
+		if code, err := strconv.ParseUint(match[2], 10, 32); err == nil && code >= xkbKeycodeOffset {
+			codes[match[1]] = uint32(code) - xkbKeycodeOffset
+		}
+	}
+	for _, match := range aliasPattern.FindAllStringSubmatch(text, -1) {
+		if code, ok := codes[match[2]]; ok {
+			codes[match[1]] = code
+		}
+	}
+
+	keymap := make(Keymap)
+	for _, match := range keyPattern.FindAllStringSubmatch(text, -1) {
+		code, ok := codes[match[1]]
+		if !ok {
+			continue
+		}
+		group := groupPattern.FindStringSubmatch(match[2])
+		if group == nil {
+			group = shortGroupPattern.FindStringSubmatch(match[2])
+		}
+		if group == nil {
+			continue
+		}
+
+		var levels []rune
+		for i, name := range strings.Split(group[1], ",") {
+			if i == 2 {
+				break
+			}
+			levels = append(levels, keysymRune(strings.TrimSpace(name)))
+		}
+		if levels[0] != 0 || len(levels) > 1 && levels[1] != 0 {
+			keymap[code] = levels
+		}
+	}
+	return keymap
+}
+
+// Rune returns the character a key types, or 0 if it types none. Caps Lock
+// inverts the case of letters.
+func (k Keymap) Rune(code uint32, shift, capsLock bool) rune {
+	levels := k[code]
+	if len(levels) == 0 {
+		return 0
+	}
+	r := levels[0]
+	if shift && len(levels) > 1 && levels[1] != 0 {
+		r = levels[1]
+	}
+	if capsLock && unicode.IsLetter(r) {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}
+	return r
+}
+
+// keysymRune returns the character of a keysym name as XKB keymaps write them
+func keysymRune(name string) rune {
+	if r, ok := keysymNames[name]; ok {
+		return r
+	}
+	if runes := []rune(name); len(runes) == 1 && unicode.IsPrint(runes[0]) {
+		return runes[0]
+	}
+	if hex, ok := strings.CutPrefix(name, "U"); ok && len(hex) >= 4 {
+		if v, err := strconv.ParseUint(hex, 16, 32); err == nil && unicode.IsPrint(rune(v)) {
+			return rune(v)
+		}
+	}
+	if hex, ok := strings.CutPrefix(name, "0x"); ok {
+		// Unicode keysyms are the code point plus 0x1000000
+		if v, err := strconv.ParseUint(hex, 16, 32); err == nil && v > 0x1000000 && unicode.IsPrint(rune(v-0x1000000)) {
+			return rune(v - 0x1000000)
+		}
+	}
+	return 0
+}
+
+// keysymNames maps the names of the printable ASCII and Latin-1 keysyms other than
+// letters and digits, whose keysyms equal their code points
+var keysymNames = func() map[string]rune {
+	ranges := []struct {
+		first rune
+		names string
+	}{
+		{' ', `space exclam quotedbl numbersign dollar percent ampersand apostrophe
+			parenleft parenright asterisk plus comma minus period slash`},
+		{':', `colon semicolon less equal greater question at`},
+		{'[', `bracketleft backslash bracketright asciicircum underscore grave`},
+		{'{', `braceleft bar braceright asciitilde`},
+		{' ', `nobreakspace exclamdown cent sterling currency yen brokenbar
+			section diaeresis copyright ordfeminine guillemotleft notsign hyphen
+			registered macron degree plusminus twosuperior threesuperior acute mu
+			paragraph periodcentered cedilla onesuperior masculine guillemotright
+			onequarter onehalf threequarters questiondown Agrave Aacute Acircumflex
+			Atilde Adiaeresis Aring AE Ccedilla Egrave Eacute Ecircumflex Ediaeresis
+			Igrave Iacute Icircumflex Idiaeresis ETH Ntilde Ograve Oacute Ocircumflex
+			Otilde Odiaeresis multiply Oslash Ugrave Uacute Ucircumflex Udiaeresis
+			Yacute THORN ssharp agrave aacute acircumflex atilde adiaeresis aring ae
+			ccedilla egrave eacute ecircumflex ediaeresis igrave iacute icircumflex
+			idiaeresis eth ntilde ograve oacute ocircumflex otilde odiaeresis
+			division oslash ugrave uacute ucircumflex udiaeresis yacute thorn
+			ydiaeresis`},
+	}
+
+	names := map[string]rune{
+		"EuroSign":       '€',
+		"guillemetleft":  '«',
+		"guillemetright": '»',
+		"ordmasculine":   'º',
+		"Ooblique":       'Ø',
+		"ooblique":       'ø',
+		"Eth":            'Ð',
+		"Thorn":          'Þ',
+	}
+	for _, r := range ranges {
+		for i, name := range strings.Fields(r.names) {
+			names[name] = r.first + rune(i)
+		}
+	}
+	return names
+}()
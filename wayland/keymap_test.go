@@ -0,0 +1,68 @@
+package wayland
+
+import "testing"
+
+// testKeymap is an excerpt of a keymap as xkbcommon writes it
+const testKeymap = `xkb_keymap {
+xkb_keycodes "evdev+aliases(qwertz)" {
+	minimum = 8;
+	maximum = 255;
+	<ESC>                = 9;
+	<AE01>               = 10;
+	<AD06>               = 29;
+	<AC01>               = 38;
+	<AC10>               = 47;
+	<SPCE>               = 65;
+	<I120>               = 120;
+	alias <AZ01>         = <AD06>;
+	indicator 1 = "Caps Lock";
+};
+xkb_symbols "pc+de+inet(evdev)" {
+	name[Group1]="German";
+
+	key <ESC>                {	[          Escape ] };
+	key <AE01>               {	[               1,          exclam,     onesuperior ] };
+	key <AZ01>               {
+		type= "FOUR_LEVEL_SEMIALPHABETIC",
+		symbols[1]= [               z,               Z,       leftarrow ]
+	};
+	key <AC01>               {	[               a,               A ] };
+	key <AC10>               {
+		type[1]= "ALPHABETIC",
+		symbols[1]= [      odiaeresis,      Odiaeresis ],
+		symbols[2]= [       semicolon,           colon ]
+	};
+	key <SPCE>               {	[           space ] };
+	key <I120>               {	[      0x1000430,       U0410 ] };
+	modifier_map Shift { <LFSH>, <RTSH> };
+};
+};
+`
+
+func TestParseKeymap(t *testing.T) {
+	keymap := ParseKeymap(testKeymap)
+
+	for _, tc := range []struct {
+		code            uint32
+		shift, capsLock bool
+		want            rune
+	}{
+		{KeyEscape, false, false, 0},
+		{2, false, false, '1'},
+		{2, true, false, '!'},
+		{21, false, false, 'z'}, // Through the alias
+		{30, true, false, 'A'},
+		{30, false, true, 'A'},
+		{30, true, true, 'a'},
+		{39, false, false, 'ö'}, // Only the first group
+		{39, true, false, 'Ö'},
+		{57, true, false, ' '},
+		{112, false, false, 'а'},
+		{112, true, false, 'А'},
+		{200, false, false, 0},
+	} {
+		if got := keymap.Rune(tc.code, tc.shift, tc.capsLock); got != tc.want {
+			t.Errorf("key %d, shift %v, caps lock %v: got %q, want %q", tc.code, tc.shift, tc.capsLock, got, tc.want)
+		}
+	}
+}
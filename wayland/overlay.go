@@ -0,0 +1,450 @@
+package wayland
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"strings"
+	"time"
+)
+
+// Interfaces the overlay binds besides LayerShell, and the versions it uses
+const (
+	compositorInterface = "wl_compositor"
+	shmInterface        = "wl_shm"
+	seatInterface       = "wl_seat"
+
+	compositorVersion = 4
+	shmVersion        = 1
+	seatVersion       = 5
+	layerShellVersion = 4
+)
+
+// overlayVersions are the versions of the interfaces the overlay binds
+var overlayVersions = map[string]uint32{
+	compositorInterface: compositorVersion,
+	shmInterface:        shmVersion,
+	seatInterface:       seatVersion,
+	LayerShell:          layerShellVersion,
+}
+
+// Request opcodes
+const (
+	registryBind              = 0
+	compositorCreateSurface   = 0
+	surfaceAttach             = 1
+	surfaceDamage             = 2
+	surfaceCommit             = 6
+	shmCreatePool             = 0
+	shmPoolCreateBuffer       = 0
+	seatGetKeyboard           = 1
+	layerShellGetLayerSurface = 0
+	layerSurfaceSetSize       = 0
+	layerSurfaceSetAnchor     = 1
+	layerSurfaceSetMargin     = 3
+	layerSurfaceSetKeyboard   = 4
+	layerSurfaceAckConfigure  = 6
+)
+
+// Event opcodes
+const (
+	bufferRelease         = 0
+	seatCapabilities      = 0
+	keyboardKeymap        = 0
+	keyboardKey           = 3
+	keyboardModifiers     = 4
+	layerSurfaceConfigure = 0
+	layerSurfaceClosed    = 1
+)
+
+// Enum values
+const (
+	layerOverlay      = 3
+	anchorTop         = 1
+	anchorLeft        = 4
+	keyboardExclusive = 1
+	seatKeyboard      = 2
+	keymapFormatXKB   = 1
+	keyStatePressed   = 1
+	shmFormatARGB8888 = 0
+)
+
+// Modifier bits of wl_keyboard.modifiers, in the order XKB keymaps list the real
+// modifiers
+const (
+	modifierShift    = 1 << 0
+	modifierCapsLock = 1 << 1
+	modifierControl  = 1 << 2
+)
+
+const (
+	// One buffer is drawn while the compositor shows the other
+	overlayBuffers = 2
+
+	overlayBytesPerPixel = 4
+)
+
+// Linux evdev codes of the keys an overlay handles itself
+const (
+	KeyEscape    = 1
+	KeyBackspace = 14
+	KeyTab       = 15
+	KeyEnter     = 28
+	KeyKPEnter   = 96
+	KeyUp        = 103
+	KeyPageUp    = 104
+	KeyDown      = 108
+	KeyPageDown  = 109
+)
+
+// ErrClosed is returned when the compositor closed the overlay, e.g. because its
+// output went away
+var ErrClosed = errors.New("the compositor closed the overlay")
+
+// OverlayOptions configures an overlay
+type OverlayOptions struct {
+	Width, Height int
+	// Position of the top left corner within the output, nil to centre the overlay
+	Position  *image.Point
+	Namespace string // Tells compositors which surface this is, e.g. for rules
+}
+
+// Key is a key press on an overlay
+type Key struct {
+	Code uint32 // Linux evdev code, see the Key constants
+	Rune rune   // Character the key types in the keyboard layout, 0 if none
+	Ctrl bool
+}
+
+// shmBuffer is a wl_buffer in the overlay's shared memory
+type shmBuffer struct {
+	id     uint32
+	offset int64
+	busy   bool // Attached and not released by the compositor yet
+}
+
+// Overlay is a layer-shell surface above all windows that takes the keyboard
+// while it is open. The compositor picks its output, usually the focused one.
+// An overlay must only be used from one goroutine.
+type Overlay struct {
+	conn          *conn
+	width, height int
+
+	seat, keyboard, surface, layerSurface uint32
+	pool                                  *os.File
+	buffers                               []shmBuffer
+
+	hasKeyboard bool
+	configured  bool
+	callback    uint32 // Pending sync, 0 if none
+
+	keymap    Keymap
+	modifiers uint32 // Depressed and latched
+	locked    uint32
+	keys      []Key // Pressed and not read yet
+}
+
+// OpenOverlay shows an overlay on the compositor. It is transparent until the
+// first Draw.
+func OpenOverlay(options OverlayOptions) (*Overlay, error) {
+	c, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	// Setting up takes a few round trips, a compositor that doesn't answer them fails it
+	c.SetDeadline(time.Now().Add(2 * time.Second))
+	o, err := openOverlay(c, options)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.SetDeadline(time.Time{})
+	return o, nil
+}
+
+// openOverlay creates the overlay's objects on a fresh connection
+func openOverlay(c *conn, options OverlayOptions) (*Overlay, error) {
+	if options.Width <= 0 || options.Height <= 0 {
+		return nil, fmt.Errorf("invalid overlay size %dx%d", options.Width, options.Height)
+	}
+	o := &Overlay{conn: c, width: options.Width, height: options.Height}
+
+	globals, err := Globals(c)
+	if err != nil {
+		return nil, err
+	}
+	bound := make(map[string]uint32)
+	for _, global := range globals {
+		version, ok := overlayVersions[global.Interface]
+		if !ok || bound[global.Interface] != 0 {
+			continue
+		}
+		id := c.newID()
+		if err := c.send(registryID, registryBind, args(global.Name, global.Interface, min(version, global.Version), id)); err != nil {
+			return nil, err
+		}
+		bound[global.Interface] = id
+	}
+	for _, iface := range []string{compositorInterface, shmInterface, seatInterface, LayerShell} {
+		if bound[iface] == 0 {
+			return nil, fmt.Errorf("the compositor doesn't support %s", iface)
+		}
+	}
+	o.seat = bound[seatInterface]
+
+	// The seat tells its capabilities once bound
+	if err := o.roundtrip(); err != nil {
+		return nil, err
+	}
+	if !o.hasKeyboard {
+		return nil, errors.New("the seat has no keyboard")
+	}
+	o.keyboard = c.newID()
+	if err := c.send(o.seat, seatGetKeyboard, args(o.keyboard)); err != nil {
+		return nil, err
+	}
+
+	o.surface = c.newID()
+	o.layerSurface = c.newID()
+	var anchor uint32
+	var top, left int32
+	if options.Position != nil {
+		anchor = anchorTop | anchorLeft
+		top, left = int32(options.Position.Y), int32(options.Position.X)
+	}
+	requests := []struct {
+		object uint32
+		opcode uint16
+		args   []byte
+	}{
+		{bound[compositorInterface], compositorCreateSurface, args(o.surface)},
+		// A null output lets the compositor choose
+		{bound[LayerShell], layerShellGetLayerSurface, args(o.layerSurface, o.surface, uint32(0), uint32(layerOverlay), options.Namespace)},
+		{o.layerSurface, layerSurfaceSetSize, args(uint32(o.width), uint32(o.height))},
+		{o.layerSurface, layerSurfaceSetAnchor, args(anchor)},
+		{o.layerSurface, layerSurfaceSetMargin, args(top, int32(0), int32(0), left)},
+		{o.layerSurface, layerSurfaceSetKeyboard, args(uint32(keyboardExclusive))},
+		// The initial commit without a buffer asks for the first configure
+		{o.surface, surfaceCommit, nil},
+	}
+	for _, r := range requests {
+		if err := c.send(r.object, r.opcode, r.args); err != nil {
+			return nil, err
+		}
+	}
+	for !o.configured {
+		if err := o.dispatch(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := o.createBuffers(bound[shmInterface]); err != nil {
+		if o.pool != nil {
+			o.pool.Close()
+		}
+		return nil, err
+	}
+	return o, nil
+}
+
+// createBuffers shares a file holding the overlay's buffers with the compositor
+func (o *Overlay) createBuffers(shm uint32) error {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	file, err := os.CreateTemp(dir, "noteboard-overlay-*")
+	if err != nil {
+		return err
+	}
+	os.Remove(file.Name())
+	o.pool = file
+
+	stride := o.width * overlayBytesPerPixel
+	size := stride * o.height
+	if err := file.Truncate(int64(size * overlayBuffers)); err != nil {
+		return err
+	}
+	pool := o.conn.newID()
+	if err := o.conn.send(shm, shmCreatePool, args(pool, int32(size*overlayBuffers)), int(file.Fd())); err != nil {
+		return err
+	}
+	for i := range overlayBuffers {
+		buffer := shmBuffer{id: o.conn.newID(), offset: int64(i * size)}
+		request := args(buffer.id, int32(buffer.offset), int32(o.width), int32(o.height), int32(stride), uint32(shmFormatARGB8888))
+		if err := o.conn.send(pool, shmPoolCreateBuffer, request); err != nil {
+			return err
+		}
+		o.buffers = append(o.buffers, buffer)
+	}
+	return nil
+}
+
+// Size returns the size of the images to draw
+func (o *Overlay) Size() (width, height int) {
+	return o.width, o.height
+}
+
+// Draw shows an image of the overlay's size
+func (o *Overlay) Draw(img *image.RGBA) error {
+	if size := img.Bounds().Size(); size.X != o.width || size.Y != o.height {
+		return fmt.Errorf("image is %dx%d, the overlay %dx%d", size.X, size.Y, o.width, o.height)
+	}
+
+	var buffer *shmBuffer
+	for buffer == nil {
+		for i := range o.buffers {
+			if !o.buffers[i].busy {
+				buffer = &o.buffers[i]
+				break
+			}
+		}
+		if buffer == nil {
+			if err := o.dispatch(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// ARGB8888 is stored little-endian, so the bytes are blue, green, red, alpha.
+	// Both formats are premultiplied.
+	pixels := make([]byte, 0, o.width*o.height*overlayBytesPerPixel)
+	for y := range o.height {
+		row := img.Pix[y*img.Stride : y*img.Stride+o.width*overlayBytesPerPixel]
+		for x := 0; x < len(row); x += overlayBytesPerPixel {
+			pixels = append(pixels, row[x+2], row[x+1], row[x], row[x+3])
+		}
+	}
+	if _, err := o.pool.WriteAt(pixels, buffer.offset); err != nil {
+		return err
+	}
+
+	if err := o.conn.send(o.surface, surfaceAttach, args(buffer.id, int32(0), int32(0))); err != nil {
+		return err
+	}
+	if err := o.conn.send(o.surface, surfaceDamage, args(int32(0), int32(0), int32(o.width), int32(o.height))); err != nil {
+		return err
+	}
+	if err := o.conn.send(o.surface, surfaceCommit, nil); err != nil {
+		return err
+	}
+	buffer.busy = true
+	return nil
+}
+
+// ReadKey waits for a key press
+func (o *Overlay) ReadKey() (Key, error) {
+	for len(o.keys) == 0 {
+		if err := o.dispatch(); err != nil {
+			return Key{}, err
+		}
+	}
+	key := o.keys[0]
+	o.keys = o.keys[1:]
+	return key, nil
+}
+
+// SetDeadline makes ReadKey and Draw fail once t has passed
+func (o *Overlay) SetDeadline(t time.Time) error {
+	return o.conn.SetDeadline(t)
+}
+
+// Close removes the overlay
+func (o *Overlay) Close() error {
+	if o.pool != nil {
+		o.pool.Close()
+	}
+	// Disconnecting destroys all of the connection's objects
+	return o.conn.Close()
+}
+
+// roundtrip waits until the compositor has handled all requests sent so far
+func (o *Overlay) roundtrip() error {
+	o.callback = o.conn.newID()
+	if err := o.conn.send(displayID, displaySync, args(o.callback)); err != nil {
+		return err
+	}
+	for o.callback != 0 {
+		if err := o.dispatch(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatch reads and handles one event
+func (o *Overlay) dispatch() error {
+	object, opcode, data, err := readMessage(o.conn)
+	if err != nil {
+		return err
+	}
+	r := argReader{b: data}
+
+	switch {
+	case object == displayID && opcode == displayError:
+		r.uint32()
+		code := r.uint32()
+		return fmt.Errorf("compositor reported protocol error %d: %s", code, r.string())
+	case object == o.callback && opcode == callbackDone:
+		o.callback = 0
+	case object == o.seat && opcode == seatCapabilities:
+		o.hasKeyboard = r.uint32()&seatKeyboard != 0
+	case object == o.layerSurface && opcode == layerSurfaceConfigure:
+		// The size is the one asked for, as the overlay isn't stretched between edges
+		serial := r.uint32()
+		if err := o.conn.send(o.layerSurface, layerSurfaceAckConfigure, args(serial)); err != nil {
+			return err
+		}
+		o.configured = true
+	case object == o.layerSurface && opcode == layerSurfaceClosed:
+		return ErrClosed
+	case object == o.keyboard && opcode == keyboardKeymap:
+		return o.readKeymap(&r)
+	case object == o.keyboard && opcode == keyboardKey:
+		r.uint32() // Serial
+		r.uint32() // Time
+		code, state := r.uint32(), r.uint32()
+		if r.err == nil && state == keyStatePressed {
+			o.keys = append(o.keys, Key{
+				Code: code,
+				Rune: o.keymap.Rune(code, o.modifiers&modifierShift != 0, o.locked&modifierCapsLock != 0),
+				Ctrl: o.modifiers&modifierControl != 0,
+			})
+		}
+	case object == o.keyboard && opcode == keyboardModifiers:
+		r.uint32() // Serial
+		depressed, latched, locked := r.uint32(), r.uint32(), r.uint32()
+		o.modifiers, o.locked = depressed|latched, locked
+	case opcode == bufferRelease:
+		for i := range o.buffers {
+			if o.buffers[i].id == object {
+				o.buffers[i].busy = false
+			}
+		}
+	}
+	return r.err
+}
+
+// readKeymap reads the keymap of a wl_keyboard.keymap event from the file it came with
+func (o *Overlay) readKeymap(r *argReader) error {
+	format := r.uint32()
+	fd, err := o.conn.takeFD()
+	if err != nil {
+		return err
+	}
+	file := os.NewFile(uintptr(fd), "keymap")
+	defer file.Close()
+	size := r.uint32()
+	if r.err != nil || format != keymapFormatXKB {
+		return r.err
+	}
+
+	data := make([]byte, size)
+	n, err := file.ReadAt(data, 0)
+	if err != nil && n < len(data) {
+		return fmt.Errorf("could not read the keymap: %w", err)
+	}
+	o.keymap = ParseKeymap(strings.TrimRight(string(data), "\x00"))
+	return nil
+}
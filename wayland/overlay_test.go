@@ -0,0 +1,227 @@
+package wayland
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"net"
+	"os"
+	"slices"
+	"syscall"
+	"testing"
+)
+
+// socketPair returns the two ends of a connected Unix socket
+func socketPair(t *testing.T) (client, server *conn) {
+	t.Helper()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ends [2]*conn
+	for i, fd := range fds {
+		file := os.NewFile(uintptr(fd), "socket")
+		c, err := net.FileConn(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ends[i] = newConn(c.(*net.UnixConn))
+		t.Cleanup(func() { c.Close() })
+	}
+	return ends[0], ends[1]
+}
+
+// fakeLayerShell answers the requests of an overlay like a compositor supporting
+// wlr-layer-shell would, recording what it was asked for
+type fakeLayerShell struct {
+	t       *testing.T
+	c       *conn
+	objects map[uint32]string // Interface by object ID
+
+	keyboard, layerSurface uint32
+	layerSurfaceArgs       [][]uint32 // Requests setting up the layer surface
+	namespace              string
+	pool                   *os.File
+	buffers                map[uint32]int64 // Offset by buffer ID
+	committed              chan uint32      // Buffers committed
+}
+
+func newFakeLayerShell(t *testing.T, c *conn) *fakeLayerShell {
+	return &fakeLayerShell{
+		t:         t,
+		c:         c,
+		objects:   map[uint32]string{displayID: "wl_display"},
+		buffers:   make(map[uint32]int64),
+		committed: make(chan uint32, 1),
+	}
+}
+
+func (f *fakeLayerShell) send(object uint32, opcode uint16, data []byte, fds ...int) {
+	if err := f.c.send(object, opcode, data, fds...); err != nil {
+		f.t.Error(err)
+	}
+}
+
+// serve handles requests until the overlay disconnects
+func (f *fakeLayerShell) serve(keymap string) {
+	var attached uint32
+	for {
+		object, opcode, data, err := readMessage(f.c)
+		if err != nil {
+			return
+		}
+		r := argReader{b: data}
+
+		switch request := [2]any{f.objects[object], opcode}; request {
+		case [2]any{"wl_display", uint16(displayGetRegistry)}:
+			f.objects[r.uint32()] = "wl_registry"
+			for i, iface := range []string{"wl_output", compositorInterface, shmInterface, seatInterface, LayerShell} {
+				f.send(registryID, registryEvent, args(uint32(i+1), iface, uint32(4)))
+			}
+		case [2]any{"wl_display", uint16(displaySync)}:
+			f.send(r.uint32(), callbackDone, args(uint32(0)))
+		case [2]any{"wl_registry", uint16(registryBind)}:
+			r.uint32()
+			iface := r.string()
+			r.uint32()
+			id := r.uint32()
+			f.objects[id] = iface
+			if iface == seatInterface {
+				f.send(id, seatCapabilities, args(uint32(seatKeyboard)))
+			}
+		case [2]any{seatInterface, uint16(seatGetKeyboard)}:
+			f.keyboard = r.uint32()
+			f.objects[f.keyboard] = "wl_keyboard"
+			file := tempFile(f.t, keymap)
+			f.send(f.keyboard, keyboardKeymap, args(uint32(keymapFormatXKB), uint32(len(keymap))), int(file.Fd()))
+			file.Close()
+		case [2]any{compositorInterface, uint16(compositorCreateSurface)}:
+			f.objects[r.uint32()] = "wl_surface"
+		case [2]any{LayerShell, uint16(layerShellGetLayerSurface)}:
+			f.layerSurface = r.uint32()
+			f.objects[f.layerSurface] = "zwlr_layer_surface_v1"
+			r.uint32()
+			output, layer := r.uint32(), r.uint32()
+			f.namespace = r.string()
+			if output != 0 || layer != layerOverlay {
+				f.t.Errorf("got output %d and layer %d", output, layer)
+			}
+		case [2]any{"zwlr_layer_surface_v1", uint16(layerSurfaceSetSize)},
+			[2]any{"zwlr_layer_surface_v1", uint16(layerSurfaceSetAnchor)},
+			[2]any{"zwlr_layer_surface_v1", uint16(layerSurfaceSetMargin)},
+			[2]any{"zwlr_layer_surface_v1", uint16(layerSurfaceSetKeyboard)}:
+			request := []uint32{uint32(opcode)}
+			for len(r.b) > 0 {
+				request = append(request, r.uint32())
+			}
+			f.layerSurfaceArgs = append(f.layerSurfaceArgs, request)
+		case [2]any{"zwlr_layer_surface_v1", uint16(layerSurfaceAckConfigure)}:
+			if serial := r.uint32(); serial != 7 {
+				f.t.Errorf("acknowledged configure %d", serial)
+			}
+		case [2]any{shmInterface, uint16(shmCreatePool)}:
+			f.objects[r.uint32()] = "wl_shm_pool"
+			fd, err := f.c.takeFD()
+			if err != nil {
+				f.t.Error(err)
+				return
+			}
+			f.pool = os.NewFile(uintptr(fd), "pool")
+		case [2]any{"wl_shm_pool", uint16(shmPoolCreateBuffer)}:
+			id, offset := r.uint32(), r.uint32()
+			f.objects[id] = "wl_buffer"
+			f.buffers[id] = int64(offset)
+		case [2]any{"wl_surface", uint16(surfaceAttach)}:
+			attached = r.uint32()
+		case [2]any{"wl_surface", uint16(surfaceCommit)}:
+			if attached == 0 {
+				// Initial commit
+				f.send(f.layerSurface, layerSurfaceConfigure, args(uint32(7), uint32(4), uint32(2)))
+			} else {
+				f.committed <- attached
+			}
+		}
+	}
+}
+
+// tempFile returns an unlinked file holding content
+func tempFile(t *testing.T, content string) *os.File {
+	file, err := os.CreateTemp(t.TempDir(), "keymap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestOverlay(t *testing.T) {
+	client, server := socketPair(t)
+	fake := newFakeLayerShell(t, server)
+	go fake.serve(testKeymap)
+
+	o, err := openOverlay(client, OverlayOptions{Width: 4, Height: 2, Position: &image.Point{X: 10, Y: 20}, Namespace: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(1, 1, color.RGBA{R: 1, G: 2, B: 3, A: 255})
+	if err := o.Draw(img); err != nil {
+		t.Fatal(err)
+	}
+	// The fake's fields are safe to read once it committed
+	buffer := <-fake.committed
+	if fake.namespace != "test" {
+		t.Errorf("got namespace %q", fake.namespace)
+	}
+	want := [][]uint32{
+		{layerSurfaceSetSize, 4, 2},
+		{layerSurfaceSetAnchor, anchorTop | anchorLeft},
+		{layerSurfaceSetMargin, 20, 0, 0, 10},
+		{layerSurfaceSetKeyboard, keyboardExclusive},
+	}
+	if !slices.EqualFunc(fake.layerSurfaceArgs, want, slices.Equal) {
+		t.Errorf("layer surface set up with %v, want %v", fake.layerSurfaceArgs, want)
+	}
+
+	pixel := make([]byte, 4)
+	if _, err := fake.pool.ReadAt(pixel, fake.buffers[buffer]+(1*4+1)*4); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pixel, []byte{3, 2, 1, 255}) {
+		t.Errorf("got pixel %v, want blue, green, red, alpha", pixel)
+	}
+	if err := o.Draw(image.NewRGBA(image.Rect(0, 0, 3, 2))); err == nil {
+		t.Error("drew an image of the wrong size")
+	}
+
+	// Shift-A, released, then Escape with Control held
+	fake.send(fake.keyboard, keyboardModifiers, args(uint32(0), uint32(modifierShift), uint32(0), uint32(0), uint32(0)))
+	fake.send(fake.keyboard, keyboardKey, args(uint32(0), uint32(0), uint32(30), uint32(keyStatePressed)))
+	fake.send(fake.keyboard, keyboardKey, args(uint32(0), uint32(0), uint32(30), uint32(0)))
+	fake.send(fake.keyboard, keyboardModifiers, args(uint32(0), uint32(modifierControl), uint32(0), uint32(0), uint32(0)))
+	fake.send(fake.keyboard, keyboardKey, args(uint32(0), uint32(0), uint32(KeyEscape), uint32(keyStatePressed)))
+	fake.send(fake.layerSurface, layerSurfaceClosed, nil)
+
+	for _, want := range []Key{{Code: 30, Rune: 'A'}, {Code: KeyEscape, Ctrl: true}} {
+		if key, err := o.ReadKey(); err != nil || key != want {
+			t.Errorf("read %+v, %v, want %+v", key, err, want)
+		}
+	}
+	if _, err := o.ReadKey(); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v after the overlay was closed", err)
+	}
+}
+
+func TestOverlayNeedsLayerShell(t *testing.T) {
+	client, server := socketPair(t)
+	go fakeCompositor(t, server, []string{compositorInterface, shmInterface, seatInterface})
+
+	if _, err := openOverlay(client, OverlayOptions{Width: 4, Height: 2}); err == nil {
+		t.Error("opened an overlay without wlr-layer-shell")
+	}
+}
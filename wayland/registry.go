@@ -0,0 +1,165 @@
+// Package wayland is a minimal Wayland wire protocol client. It lists the globals
+// a compositor advertises, e.g. to find out whether it supports the
+// wlr-layer-shell protocol, which the toolkit can't tell us, and shows the
+// keyboard-interactive layer-shell overlays the toolkit can't create.
+package wayland
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LayerShell is the interface name of the wlr-layer-shell protocol
+const LayerShell = "zwlr_layer_shell_v1"
+
+// Object IDs and opcodes used by Globals. The display is always object 1.
+const (
+	displayID  = 1
+	registryID = 2
+	callbackID = 3
+
+	displaySync        = 0
+	displayGetRegistry = 1
+
+	displayError  = 0
+	registryEvent = 0 // wl_registry.global
+	callbackDone  = 0
+)
+
+// Global is an interface the compositor advertises
+type Global struct {
+	Name      uint32
+	Interface string
+	Version   uint32
+}
+
+// SocketPath returns the compositor's socket from WAYLAND_DISPLAY and XDG_RUNTIME_DIR
+func SocketPath() (string, error) {
+	display := os.Getenv("WAYLAND_DISPLAY")
+	if display == "" {
+		display = "wayland-0"
+	}
+	if filepath.IsAbs(display) {
+		return display, nil
+	}
+
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return "", errors.New("XDG_RUNTIME_DIR is not set")
+	}
+	return filepath.Join(runtimeDir, display), nil
+}
+
+// HasGlobal connects to the compositor and reports whether it advertises an interface
+func HasGlobal(iface string) (bool, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return false, err
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	globals, err := Globals(conn)
+	if err != nil {
+		return false, err
+	}
+	for _, global := range globals {
+		if global.Interface == iface {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Globals requests the registry on a fresh connection and returns the globals
+// announced before the compositor answers a sync request
+func Globals(conn io.ReadWriter) ([]Global, error) {
+	// wl_display.get_registry(new_id), then wl_display.sync(new_id) to learn
+	// when all globals have been sent
+	request := append(message(displayID, displayGetRegistry, uint32Arg(registryID)),
+		message(displayID, displaySync, uint32Arg(callbackID))...)
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	var globals []Global
+	for {
+		object, opcode, args, err := readMessage(conn)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case object == callbackID && opcode == callbackDone:
+			return globals, nil
+		case object == displayID && opcode == displayError:
+			return nil, fmt.Errorf("compositor reported a protocol error")
+		case object == registryID && opcode == registryEvent:
+			global, err := parseGlobal(args)
+			if err != nil {
+				return nil, err
+			}
+			globals = append(globals, global)
+		}
+	}
+}
+
+// message encodes a request: the object ID, then the size and opcode, then the arguments
+func message(object uint32, opcode uint16, args []byte) []byte {
+	b := make([]byte, 8, 8+len(args))
+	binary.LittleEndian.PutUint32(b[0:4], object)
+	binary.LittleEndian.PutUint32(b[4:8], uint32(8+len(args))<<16|uint32(opcode))
+	return append(b, args...)
+}
+
+func uint32Arg(v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, v)
+}
+
+// readMessage reads one event
+func readMessage(r io.Reader) (object uint32, opcode uint16, args []byte, err error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	object = binary.LittleEndian.Uint32(header[0:4])
+	sizeOpcode := binary.LittleEndian.Uint32(header[4:8])
+	size, opcode := sizeOpcode>>16, uint16(sizeOpcode)
+	if size < 8 {
+		return 0, 0, nil, fmt.Errorf("invalid message size %d", size)
+	}
+
+	args = make([]byte, size-8)
+	if _, err := io.ReadFull(r, args); err != nil {
+		return 0, 0, nil, err
+	}
+	return object, opcode, args, nil
+}
+
+// parseGlobal decodes the arguments of wl_registry.global: name, interface and version.
+// Strings are length-prefixed, NUL-terminated and padded to 32 bits.
+func parseGlobal(args []byte) (Global, error) {
+	if len(args) < 8 {
+		return Global{}, errors.New("short wl_registry.global event")
+	}
+	name := binary.LittleEndian.Uint32(args[0:4])
+	length := int(binary.LittleEndian.Uint32(args[4:8]))
+	padded := (length + 3) &^ 3
+	if length == 0 || len(args) < 8+padded+4 {
+		return Global{}, errors.New("malformed wl_registry.global event")
+	}
+
+	iface := string(args[8 : 8+length-1])
+	version := binary.LittleEndian.Uint32(args[8+padded : 8+padded+4])
+	return Global{Name: name, Interface: iface, Version: version}, nil
+}
@@ -0,0 +1,69 @@
+package wayland
+
+import (
+	"encoding/binary"
+	"net"
+	"slices"
+	"testing"
+)
+
+// fakeCompositor answers the requests of Globals with the given interfaces
+func fakeCompositor(t *testing.T, conn net.Conn, interfaces []string) {
+	defer conn.Close()
+
+	var requests [][2]uint32
+	for range 2 {
+		object, opcode, args, err := readMessage(conn)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		requests = append(requests, [2]uint32{object, uint32(opcode)})
+		if len(args) != 4 {
+			t.Errorf("request %d/%d has %d bytes of arguments", object, opcode, len(args))
+		}
+	}
+	if want := [][2]uint32{{displayID, displayGetRegistry}, {displayID, displaySync}}; !slices.Equal(requests, want) {
+		t.Errorf("got requests %v, want %v", requests, want)
+	}
+
+	for i, iface := range interfaces {
+		args := binary.LittleEndian.AppendUint32(nil, uint32(i+1))
+		args = append(args, stringArg(iface)...)
+		args = binary.LittleEndian.AppendUint32(args, 4)
+		conn.Write(message(registryID, registryEvent, args))
+	}
+	conn.Write(message(callbackID, callbackDone, uint32Arg(0)))
+}
+
+func TestGlobals(t *testing.T) {
+	// Interface names of every length modulo 4 exercise the padding
+	interfaces := []string{"wl_compositor", "wl_shm", "xdg_wm_base", LayerShell, "wl_seat"}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go fakeCompositor(t, server, interfaces)
+
+	globals, err := Globals(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for i, global := range globals {
+		got = append(got, global.Interface)
+		if global.Name != uint32(i+1) || global.Version != 4 {
+			t.Errorf("got %+v", global)
+		}
+	}
+	if !slices.Equal(got, interfaces) {
+		t.Errorf("got %q, want %q", got, interfaces)
+	}
+}
+
+func TestParseGlobalRejectsShortEvents(t *testing.T) {
+	args := append(binary.LittleEndian.AppendUint32(nil, 1), stringArg("wl_seat")...)
+	if _, err := parseGlobal(args); err == nil {
+		t.Error("parsed an event without version")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"NoteBoard/core"
+	"github.com/robotn/xgb"
+	"github.com/robotn/xgb/xproto"
+)
+
+// X11 selection targets that aren't MIME types
+const (
+	x11TargetsTarget = "TARGETS"
+	x11UTF8Target    = "UTF8_STRING"
+)
+
+// serveX11Clipboard takes the CLIPBOARD selection and serves data in each of the
+// given targets, which xclip and xsel can't: they offer a single one. Text is also
+// offered as UTF8_STRING. The selection is served until another client takes it
+// or NoteBoard exits. Data must fit a single X request, which file lists do.
+func serveX11Clipboard(targets map[string][]byte) error {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the X server: %w", err)
+	}
+
+	owner, atoms, err := takeX11Clipboard(conn, targets)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	go serveX11Requests(conn, owner, atoms)
+	return nil
+}
+
+// x11Atoms maps the atoms of the served targets to their data
+type x11Atoms struct {
+	clipboard xproto.Atom
+	targets   xproto.Atom
+	data      map[xproto.Atom][]byte
+}
+
+// takeX11Clipboard creates a window owning the CLIPBOARD selection
+func takeX11Clipboard(conn *xgb.Conn, targets map[string][]byte) (xproto.Window, x11Atoms, error) {
+	atoms := x11Atoms{data: make(map[xproto.Atom][]byte)}
+
+	intern := func(name string) (xproto.Atom, error) {
+		reply, err := xproto.InternAtom(conn, false, uint16(len(name)), name).Reply()
+		if err != nil {
+			return 0, fmt.Errorf("failed to intern %s: %w", name, err)
+		}
+		return reply.Atom, nil
+	}
+
+	var err error
+	if atoms.clipboard, err = intern("CLIPBOARD"); err != nil {
+		return 0, atoms, err
+	}
+	if atoms.targets, err = intern(x11TargetsTarget); err != nil {
+		return 0, atoms, err
+	}
+	for target, data := range targets {
+		names := []string{target}
+		if target == core.TextTarget {
+			names = append(names, x11UTF8Target)
+		}
+		for _, name := range names {
+			atom, err := intern(name)
+			if err != nil {
+				return 0, atoms, err
+			}
+			atoms.data[atom] = data
+		}
+	}
+
+	screen := xproto.Setup(conn).DefaultScreen(conn)
+	window, err := xproto.NewWindowId(conn)
+	if err != nil {
+		return 0, atoms, fmt.Errorf("failed to create the selection window: %w", err)
+	}
+	err = xproto.CreateWindowChecked(conn, 0, window, screen.Root, 0, 0, 1, 1, 0,
+		xproto.WindowClassInputOnly, screen.RootVisual, 0, nil).Check()
+	if err != nil {
+		return 0, atoms, fmt.Errorf("failed to create the selection window: %w", err)
+	}
+
+	xproto.SetSelectionOwner(conn, window, atoms.clipboard, xproto.TimeCurrentTime)
+	reply, err := xproto.GetSelectionOwner(conn, atoms.clipboard).Reply()
+	if err != nil {
+		return 0, atoms, fmt.Errorf("failed to take the clipboard: %w", err)
+	}
+	if reply.Owner != window {
+		return 0, atoms, errors.New("failed to take the clipboard: another client owns it")
+	}
+	return window, atoms, nil
+}
+
+// serveX11Requests answers requests for the selection until it is taken by
+// another client, then closes the connection
+func serveX11Requests(conn *xgb.Conn, owner xproto.Window, atoms x11Atoms) {
+	defer conn.Close()
+
+	for {
+		event, xerr := conn.WaitForEvent()
+		switch {
+		case event == nil && xerr == nil:
+			return // Connection closed
+		case xerr != nil:
+			slog.Debug("X11 clipboard error", "err", xerr)
+			continue
+		}
+
+		switch e := event.(type) {
+		case xproto.SelectionClearEvent:
+			if e.Owner == owner && e.Selection == atoms.clipboard {
+				return
+			}
+		case xproto.SelectionRequestEvent:
+			answerX11Request(conn, e, atoms)
+		}
+	}
+}
+
+// answerX11Request stores the requested target on the requestor's property and
+// notifies it, refusing targets that aren't served
+func answerX11Request(conn *xgb.Conn, request xproto.SelectionRequestEvent, atoms x11Atoms) {
+	// Obsolete clients don't name a property and expect the target to be used
+	property := request.Property
+	if property == xproto.AtomNone {
+		property = request.Target
+	}
+
+	data, served := atoms.data[request.Target]
+	switch {
+	case request.Selection != atoms.clipboard:
+		property = xproto.AtomNone
+	case request.Target == atoms.targets:
+		list := make([]byte, 0, 4*(len(atoms.data)+1))
+		list = appendX11Atom(list, atoms.targets)
+		for atom := range atoms.data {
+			list = appendX11Atom(list, atom)
+		}
+		xproto.ChangeProperty(conn, xproto.PropModeReplace, request.Requestor, property,
+			xproto.AtomAtom, 32, uint32(len(list)/4), list)
+	case served:
+		xproto.ChangeProperty(conn, xproto.PropModeReplace, request.Requestor, property,
+			request.Target, 8, uint32(len(data)), data)
+	default:
+		property = xproto.AtomNone
+	}
+
+	notify := xproto.SelectionNotifyEvent{
+		Time:      request.Time,
+		Requestor: request.Requestor,
+		Selection: request.Selection,
+		Target:    request.Target,
+		Property:  property,
+	}
+	xproto.SendEvent(conn, false, request.Requestor, xproto.EventMaskNoEvent, string(notify.Bytes()))
+}
+
+// appendX11Atom appends an atom in the connection's byte order, which xgb sets to little-endian
+func appendX11Atom(list []byte, atom xproto.Atom) []byte {
+	buf := make([]byte, 4)
+	xgb.Put32(buf, uint32(atom))
+	return append(list, buf...)
+}